@@ -0,0 +1,650 @@
+package localtunnel
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LetsEncryptURL is the directory of the production ACME server of Let's Encrypt.
+const LetsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+const (
+	// acmeALPN is the protocol negotiated by the TLS-ALPN-01 challenges, see RFC 8737.
+	acmeALPN = "acme-tls/1"
+	// acmeTimeout bounds the issuance of a certificate.
+	acmeTimeout = 2 * time.Minute
+	// acmeRenewBefore is how long before their expiry certificates are renewed.
+	acmeRenewBefore = 30 * 24 * time.Hour
+	// acmeRetryAfter is how long a failed issuance is not tried again, sparing the
+	// rate limits of the CA.
+	acmeRetryAfter = time.Minute
+	// acmePollInterval is how often pending authorizations and orders are checked.
+	acmePollInterval = time.Second
+)
+
+// idPeACMEIdentifier is the extension of the TLS-ALPN-01 challenge certificates.
+var idPeACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+var (
+	// ErrACMEDomain is returned for the TLS connections to a domain not in ACME.Domains.
+	ErrACMEDomain = errors.New("localtunnel: acme: unknown domain")
+	// ErrACMEChallenge is returned for a TLS-ALPN-01 challenge not requested by ACME.
+	ErrACMEChallenge = errors.New("localtunnel: acme: no pending challenge")
+)
+
+// An ACME issues the certificate of custom domains pointed at a server passing TLS
+// through, from an ACME CA such as Let's Encrypt. Its TLS-ALPN-01 challenges are
+// answered through the tunnel, so the domains only need to resolve to the server.
+// The certificate is requested on the first connection and renewed in the
+// background 30 days before it expires.
+type ACME struct {
+	// DirectoryURL is the directory of the CA, LetsEncryptURL when empty.
+	DirectoryURL string
+
+	// Email is the contact of the account, optional.
+	Email string
+
+	// Domains are the names of the certificate, the first one being its subject.
+	Domains []string
+
+	// CacheDir keeps the account key and the certificate across restarts, unless empty.
+	CacheDir string
+
+	// Cache keeps them in a Store instead of CacheDir, under "acme/".
+	Cache Store
+
+	// HTTPClient talks to the CA, http.DefaultClient when nil.
+	HTTPClient *http.Client
+
+	issueMu sync.Mutex // held while issuing a certificate
+
+	mu         sync.Mutex
+	cert       *tls.Certificate
+	renewing   bool
+	failed     time.Time
+	err        error
+	challenges map[string]*tls.Certificate
+
+	// state of the ACME session, guarded by issueMu
+	dir        acmeDirectory
+	key        *ecdsa.PrivateKey
+	kid        string
+	thumbprint string
+	nonce      string
+}
+
+// WithACME terminates the TLS of the connections arriving through the tunnel with
+// the certificate issued by a. It implies WithHTTPProxy.
+func WithACME(a *ACME) Option {
+	return WithTLS(a.TLSConfig())
+}
+
+// TLSConfig returns a TLS config serving the certificate of a and answering its
+// TLS-ALPN-01 challenges.
+func (a *ACME) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: a.GetCertificate,
+		NextProtos:     []string{"http/1.1", acmeALPN},
+	}
+}
+
+// GetCertificate returns the certificate of the domain of hello, issuing it when
+// needed, or the certificate of a pending challenge, for tls.Config.GetCertificate.
+func (a *ACME) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+
+	if len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acmeALPN {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if cert, ok := a.challenges[name]; ok {
+			return cert, nil
+		}
+		return nil, ErrACMEChallenge
+	}
+
+	if name != "" && !a.hasDomain(name) {
+		return nil, ErrACMEDomain
+	}
+	return a.certificate()
+}
+
+func (a *ACME) hasDomain(name string) bool {
+	for _, d := range a.Domains {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificate returns the current certificate, renewing it in the background when
+// close to expiry, or issues it.
+func (a *ACME) certificate() (*tls.Certificate, error) {
+	a.mu.Lock()
+	cert := a.cert
+	if cert != nil && !a.renewing && time.Until(cert.Leaf.NotAfter) < acmeRenewBefore && time.Since(a.failed) > acmeRetryAfter {
+		a.renewing = true
+		go a.renew()
+	}
+	a.mu.Unlock()
+	if cert != nil {
+		return cert, nil
+	}
+
+	a.issueMu.Lock()
+	defer a.issueMu.Unlock()
+
+	// issued or failed while waiting
+	a.mu.Lock()
+	cert, failed, err := a.cert, a.failed, a.err
+	a.mu.Unlock()
+	if cert != nil {
+		return cert, nil
+	}
+	if time.Since(failed) < acmeRetryAfter {
+		return nil, err
+	}
+
+	cert, err = a.load()
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), acmeTimeout)
+		cert, err = a.issue(ctx)
+		cancel()
+	}
+	a.setCertificate(cert, err)
+	return cert, err
+}
+
+func (a *ACME) renew() {
+	a.issueMu.Lock()
+	defer a.issueMu.Unlock()
+	defer func() {
+		if v := recover(); v != nil {
+			a.setCertificate(nil, newPanicError(v))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), acmeTimeout)
+	defer cancel()
+	cert, err := a.issue(ctx)
+	a.setCertificate(cert, err)
+}
+
+func (a *ACME) setCertificate(cert *tls.Certificate, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.renewing = false
+	if err != nil {
+		a.failed, a.err = time.Now(), err
+		return
+	}
+	a.cert, a.failed, a.err = cert, time.Time{}, nil
+}
+
+// load returns the cached certificate, unless missing, not covering the domains or
+// close to expiry.
+func (a *ACME) load() (*tls.Certificate, error) {
+	s, prefix := a.cache()
+	if s == nil || len(a.Domains) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	b, err := s.Get(prefix + a.Domains[0] + ".pem")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(b, b)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range a.Domains {
+		if cert.Leaf.VerifyHostname(d) != nil {
+			return nil, ErrACMEDomain
+		}
+	}
+	if time.Until(cert.Leaf.NotAfter) < acmeRenewBefore {
+		return nil, os.ErrNotExist
+	}
+	return &cert, nil
+}
+
+// cache returns the store caching the account key and the certificate, and the
+// prefix of their keys, or nil.
+func (a *ACME) cache() (Store, string) {
+	if a.Cache != nil {
+		return a.Cache, "acme/"
+	}
+	if a.CacheDir != "" {
+		return NewDirStore(a.CacheDir), ""
+	}
+	return nil, ""
+}
+
+// save writes b to the named entry of the cache, when there is one.
+func (a *ACME) save(name string, b []byte) error {
+	s, prefix := a.cache()
+	if s == nil {
+		return nil
+	}
+	return s.Put(prefix+name, b)
+}
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeOrder struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+}
+
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []struct {
+		Type  string `json:"type"`
+		URL   string `json:"url"`
+		Token string `json:"token"`
+	} `json:"challenges"`
+}
+
+// An ACMEError is a problem reported by the CA, see RFC 8555, section 6.7.
+type ACMEError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Detail     string `json:"detail"`
+}
+
+func (e *ACMEError) Error() string {
+	return fmt.Sprintf("localtunnel: acme: %d %s: %s", e.StatusCode, e.Type, e.Detail)
+}
+
+// issue orders a certificate for the domains and answers its challenges.
+func (a *ACME) issue(ctx context.Context) (*tls.Certificate, error) {
+	if len(a.Domains) == 0 {
+		return nil, ErrACMEDomain
+	}
+
+	err := a.register(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]map[string]string, len(a.Domains))
+	for i, d := range a.Domains {
+		ids[i] = map[string]string{"type": "dns", "value": d}
+	}
+	var order acmeOrder
+	resp, err := a.post(ctx, a.dir.NewOrder, map[string]interface{}{"identifiers": ids}, &order)
+	if err != nil {
+		return nil, err
+	}
+	orderURL := resp.Header.Get("Location")
+
+	for _, authz := range order.Authorizations {
+		err = a.authorize(ctx, authz)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: a.Domains[0]},
+		DNSNames: a.Domains,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = a.post(ctx, order.Finalize, map[string]string{"csr": b64(csr)}, &order)
+	if err != nil {
+		return nil, err
+	}
+	err = a.poll(ctx, orderURL, &order, &order.Status)
+	if err != nil {
+		return nil, fmt.Errorf("localtunnel: acme: order of %s: %s", a.Domains[0], err)
+	}
+
+	var chain bytes.Buffer
+	_, err = a.post(ctx, order.Certificate, nil, &chain)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	cert, err := tls.X509KeyPair(chain.Bytes(), pemKey)
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.save(a.Domains[0]+".pem", append(chain.Bytes(), pemKey...))
+	return &cert, err
+}
+
+// register creates the account of the key, loaded from the cache or generated, or
+// finds the one it already has.
+func (a *ACME) register(ctx context.Context) error {
+	if a.kid != "" {
+		return nil
+	}
+
+	dirURL := a.DirectoryURL
+	if dirURL == "" {
+		dirURL = LetsEncryptURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dirURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&a.dir)
+	if err != nil {
+		return fmt.Errorf("localtunnel: acme: invalid directory: %s", err)
+	}
+
+	a.key, err = a.accountKey()
+	if err != nil {
+		return err
+	}
+	a.thumbprint = thumbprint(&a.key.PublicKey)
+
+	account := map[string]interface{}{"termsOfServiceAgreed": true}
+	if a.Email != "" {
+		account["contact"] = []string{"mailto:" + a.Email}
+	}
+	resp, err = a.post(ctx, a.dir.NewAccount, account, nil)
+	if err != nil {
+		return err
+	}
+	a.kid = resp.Header.Get("Location")
+	return nil
+}
+
+func (a *ACME) accountKey() (*ecdsa.PrivateKey, error) {
+	const name = "acme_account.key"
+	if s, prefix := a.cache(); s != nil {
+		if b, err := s.Get(prefix + name); err == nil {
+			if block, _ := pem.Decode(b); block != nil {
+				return x509.ParseECPrivateKey(block.Bytes)
+			}
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return key, a.save(name, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+}
+
+// authorize answers the TLS-ALPN-01 challenge of a pending authorization.
+func (a *ACME) authorize(ctx context.Context, url string) error {
+	var authz acmeAuthorization
+	_, err := a.post(ctx, url, nil, &authz)
+	if err != nil || authz.Status == "valid" {
+		return err
+	}
+
+	domain := authz.Identifier.Value
+	i := 0
+	for i < len(authz.Challenges) && authz.Challenges[i].Type != "tls-alpn-01" {
+		i++
+	}
+	if i == len(authz.Challenges) {
+		return fmt.Errorf("localtunnel: acme: no tls-alpn-01 challenge for %s", domain)
+	}
+	challenge := authz.Challenges[i]
+
+	cert, err := challengeCertificate(domain, challenge.Token+"."+a.thumbprint)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	if a.challenges == nil {
+		a.challenges = map[string]*tls.Certificate{}
+	}
+	a.challenges[domain] = cert
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.challenges, domain)
+		a.mu.Unlock()
+	}()
+
+	_, err = a.post(ctx, challenge.URL, struct{}{}, nil)
+	if err != nil {
+		return err
+	}
+	err = a.poll(ctx, url, &authz, &authz.Status)
+	if err != nil {
+		return fmt.Errorf("localtunnel: acme: authorization of %s: %s", domain, err)
+	}
+	return nil
+}
+
+// challengeCertificate returns the self-signed certificate answering the TLS-ALPN-01
+// challenge of domain, carrying the digest of its key authorization.
+func challengeCertificate(domain, keyAuth string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(keyAuth))
+	value, err := asn1.Marshal(digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: domain},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.Add(24 * time.Hour),
+		DNSNames:        []string{domain},
+		ExtraExtensions: []pkix.Extension{{Id: idPeACMEIdentifier, Critical: true, Value: value}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// poll fetches the object at url into v until its status is valid.
+func (a *ACME) poll(ctx context.Context, url string, v interface{}, status *string) error {
+	for {
+		_, err := a.post(ctx, url, nil, v)
+		if err != nil {
+			return err
+		}
+
+		switch *status {
+		case "valid":
+			return nil
+		case "invalid", "revoked", "expired", "deactivated":
+			return errors.New(*status)
+		}
+
+		timer := time.NewTimer(acmePollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// post sends payload to url signed by the account key, or a POST-as-GET when nil, and
+// reads the answer into v: decoded when JSON, or copied into a *bytes.Buffer. The
+// request is sent again with a fresh nonce when the CA rejects the previous one.
+func (a *ACME) post(ctx context.Context, url string, payload, v interface{}) (*http.Response, error) {
+	body := ""
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b64(b)
+	}
+
+	for retried := false; ; retried = true {
+		msg, err := a.sign(ctx, url, body)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(msg))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/jose+json")
+		resp, err := a.do(req)
+		if err != nil {
+			return nil, err
+		}
+		a.nonce = resp.Header.Get("Replay-Nonce")
+
+		if resp.StatusCode >= 400 {
+			problem := &ACMEError{StatusCode: resp.StatusCode}
+			json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(problem)
+			resp.Body.Close()
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && !retried {
+				continue
+			}
+			return nil, problem
+		}
+
+		switch v := v.(type) {
+		case nil:
+		case *bytes.Buffer:
+			_, err = io.Copy(v, io.LimitReader(resp.Body, 1<<20))
+		default:
+			err = json.NewDecoder(resp.Body).Decode(v)
+		}
+		resp.Body.Close()
+		return resp, err
+	}
+}
+
+// sign returns the JWS of payload for url, see RFC 8555, section 6.2.
+func (a *ACME) sign(ctx context.Context, url, payload string) ([]byte, error) {
+	if a.nonce == "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.dir.NewNonce, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := a.do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		a.nonce = resp.Header.Get("Replay-Nonce")
+	}
+
+	header := map[string]interface{}{"alg": "ES256", "nonce": a.nonce, "url": url}
+	if a.kid == "" {
+		header["jwk"] = jwk(&a.key.PublicKey)
+	} else {
+		header["kid"] = a.kid
+	}
+	a.nonce = ""
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	protected := b64(h)
+
+	digest := sha256.Sum256([]byte(protected + "." + payload))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return json.Marshal(map[string]string{"protected": protected, "payload": payload, "signature": b64(sig)})
+}
+
+func (a *ACME) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", userAgent())
+	c := a.HTTPClient
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return c.Do(req)
+}
+
+// jwk returns the JSON Web Key of a P-256 public key, its members sorted as required
+// by its thumbprint, see RFC 7638.
+func jwk(key *ecdsa.PublicKey) map[string]string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return map[string]string{"crv": "P-256", "kty": "EC", "x": b64(x), "y": b64(y)}
+}
+
+func thumbprint(key *ecdsa.PublicKey) string {
+	b, _ := json.Marshal(jwk(key))
+	digest := sha256.Sum256(b)
+	return b64(digest[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
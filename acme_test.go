@@ -0,0 +1,310 @@
+package localtunnel
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCA is an ACME server issuing certificates once the TLS-ALPN-01 challenge of
+// each domain is answered by validate.
+type fakeCA struct {
+	*httptest.Server
+	t        *testing.T
+	validate func(domain string) *tls.Certificate
+
+	mu      sync.Mutex
+	nonce   int
+	nonces  map[string]bool
+	account *ecdsa.PublicKey
+	orders  int
+	domains []string
+	valid   map[string]bool
+	chain   []byte
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+}
+
+func newFakeCA(t *testing.T, validate func(domain string) *tls.Certificate) *fakeCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	ca, _ := x509.ParseCertificate(der)
+
+	s := &fakeCA{t: t, validate: validate, nonces: map[string]bool{}, valid: map[string]bool{}, ca: ca, caKey: key}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeCA) newNonce() string {
+	s.nonce++
+	n := fmt.Sprintf("nonce-%d", s.nonce)
+	s.nonces[n] = true
+	return n
+}
+
+func (s *fakeCA) problem(w http.ResponseWriter, status int, typ, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"type": "urn:ietf:params:acme:error:" + typ, "detail": detail})
+}
+
+func (s *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Replay-Nonce", s.newNonce())
+
+	switch r.URL.Path {
+	case "/directory":
+		json.NewEncoder(w).Encode(acmeDirectory{NewNonce: s.URL + "/nonce", NewAccount: s.URL + "/account", NewOrder: s.URL + "/order"})
+		return
+	case "/nonce":
+		return
+	}
+
+	var msg struct{ Protected, Payload, Signature string }
+	json.NewDecoder(r.Body).Decode(&msg)
+	var header struct {
+		Alg, Nonce, URL, Kid string
+		JWK                  map[string]string
+	}
+	h, _ := base64.RawURLEncoding.DecodeString(msg.Protected)
+	json.Unmarshal(h, &header)
+
+	if !s.nonces[header.Nonce] {
+		s.problem(w, http.StatusBadRequest, "badNonce", "unknown nonce "+header.Nonce)
+		return
+	}
+	delete(s.nonces, header.Nonce)
+	if header.URL != s.URL+r.URL.Path {
+		s.problem(w, http.StatusUnauthorized, "unauthorized", "url mismatch")
+		return
+	}
+
+	key := s.account
+	if r.URL.Path == "/account" {
+		x, _ := base64.RawURLEncoding.DecodeString(header.JWK["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(header.JWK["y"])
+		key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if header.Kid != s.URL+"/account/1" {
+		s.problem(w, http.StatusUnauthorized, "unauthorized", "unknown account")
+		return
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(msg.Signature)
+	digest := sha256.Sum256([]byte(msg.Protected + "." + msg.Payload))
+	if header.Alg != "ES256" || len(sig) != 64 ||
+		!ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		s.problem(w, http.StatusUnauthorized, "unauthorized", "invalid signature")
+		return
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(msg.Payload)
+
+	switch path := r.URL.Path; {
+	case path == "/account":
+		s.account = key
+		w.Header().Set("Location", s.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+
+	case path == "/order":
+		var req struct{ Identifiers []struct{ Value string } }
+		json.Unmarshal(payload, &req)
+		s.orders++
+		s.domains = nil
+		for _, id := range req.Identifiers {
+			s.domains = append(s.domains, id.Value)
+		}
+		w.Header().Set("Location", s.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		s.writeOrder(w)
+
+	case path == "/order/1":
+		s.writeOrder(w)
+
+	case strings.HasPrefix(path, "/authz/"):
+		s.writeAuthz(w, strings.TrimPrefix(path, "/authz/"))
+
+	case strings.HasPrefix(path, "/challenge/"):
+		domain := strings.TrimPrefix(path, "/challenge/")
+		s.valid[domain] = s.check(domain, thumbprint(s.account))
+		s.writeAuthz(w, domain)
+
+	case path == "/finalize":
+		var req struct{ CSR string }
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			s.problem(w, http.StatusBadRequest, "badCSR", err.Error())
+			return
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		cert, _ := x509.CreateCertificate(rand.Reader, tmpl, s.ca, csr.PublicKey, s.caKey)
+		s.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Raw})...)
+		s.writeOrder(w)
+
+	case path == "/certificate":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.chain)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// check answers whether the challenge certificate of domain carries the digest of
+// its key authorization.
+func (s *fakeCA) check(domain, thumbprint string) bool {
+	cert := s.validate(domain)
+	if cert == nil {
+		return false
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || leaf.VerifyHostname(domain) != nil {
+		return false
+	}
+
+	expected := sha256.Sum256([]byte("token-" + domain + "." + thumbprint))
+	for _, ext := range leaf.Extensions {
+		var digest []byte
+		if ext.Id.Equal(idPeACMEIdentifier) && ext.Critical {
+			asn1.Unmarshal(ext.Value, &digest)
+			return bytes.Equal(digest, expected[:])
+		}
+	}
+	return false
+}
+
+func (s *fakeCA) writeOrder(w http.ResponseWriter) {
+	order := acmeOrder{Status: "pending", Finalize: s.URL + "/finalize"}
+	ready := true
+	for _, d := range s.domains {
+		order.Authorizations = append(order.Authorizations, s.URL+"/authz/"+d)
+		ready = ready && s.valid[d]
+	}
+	if ready {
+		order.Status = "ready"
+	}
+	if s.chain != nil {
+		order.Status, order.Certificate = "valid", s.URL+"/certificate"
+	}
+	json.NewEncoder(w).Encode(order)
+}
+
+func (s *fakeCA) writeAuthz(w http.ResponseWriter, domain string) {
+	status := "pending"
+	if v, ok := s.valid[domain]; ok && !v {
+		status = "invalid"
+	} else if v {
+		status = "valid"
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"identifier": map[string]string{"type": "dns", "value": domain},
+		"challenges": []map[string]string{
+			{"type": "http-01", "url": s.URL + "/unused", "token": "unused"},
+			{"type": "tls-alpn-01", "url": s.URL + "/challenge/" + domain, "token": "token-" + domain},
+		},
+	})
+}
+
+func TestACME(t *testing.T) {
+	var a *ACME
+	ca := newFakeCA(t, func(domain string) *tls.Certificate {
+		cert, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: domain, SupportedProtos: []string{acmeALPN}})
+		if err != nil {
+			t.Errorf("Cannot answer the challenge of %s: %s", domain, err)
+		}
+		return cert
+	})
+	dir := t.TempDir()
+	a = &ACME{DirectoryURL: ca.URL + "/directory", Domains: []string{"example.com", "www.example.com"}, CacheDir: dir}
+
+	cert, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "www.example.com"})
+	if err != nil {
+		t.Fatalf("Cannot issue the certificate: %s", err)
+	}
+	if cert.Leaf.Subject.CommonName != "example.com" || cert.Leaf.VerifyHostname("www.example.com") != nil {
+		t.Fatalf("Unexpected certificate. Actual: %s %v", cert.Leaf.Subject.CommonName, cert.Leaf.DNSNames)
+	}
+	if err := cert.Leaf.CheckSignatureFrom(ca.ca); err != nil {
+		t.Fatalf("Certificate should be issued by the CA: %s", err)
+	}
+	if len(a.challenges) != 0 {
+		t.Fatalf("Answered challenges should be forgotten. Actual: %d", len(a.challenges))
+	}
+
+	if again, _ := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); again != cert {
+		t.Fatal("Certificate should be issued once")
+	}
+	if _, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"}); err != ErrACMEDomain {
+		t.Fatalf("Unexpected error for another domain. Expected: %v, Actual: %v", ErrACMEDomain, err)
+	}
+	if _, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com", SupportedProtos: []string{acmeALPN}}); err != ErrACMEChallenge {
+		t.Fatalf("Unexpected error for a challenge not requested. Expected: %v, Actual: %v", ErrACMEChallenge, err)
+	}
+
+	// restarted with the same cache
+	a = &ACME{DirectoryURL: ca.URL + "/directory", Domains: []string{"example.com", "www.example.com"}, CacheDir: dir}
+	cached, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil || !bytes.Equal(cached.Certificate[0], cert.Certificate[0]) {
+		t.Fatalf("Cached certificate should be served. Actual error: %v", err)
+	}
+	if ca.orders != 1 {
+		t.Fatalf("Unexpected orders. Expected: 1, Actual: %d", ca.orders)
+	}
+}
+
+func TestACMEFailedChallenge(t *testing.T) {
+	ca := newFakeCA(t, func(domain string) *tls.Certificate {
+		cert, _ := challengeCertificate(domain, "wrong key authorization")
+		return cert
+	})
+	a := &ACME{DirectoryURL: ca.URL + "/directory", Domains: []string{"example.com"}}
+
+	_, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "authorization of example.com") {
+		t.Fatalf("Unexpected error. Expected: authorization of example.com failed, Actual: %v", err)
+	}
+
+	// not ordered again right away
+	_, again := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if again != err || ca.orders != 1 {
+		t.Fatalf("Failed issuance should not be retried right away. Actual: %d orders (%v)", ca.orders, again)
+	}
+}
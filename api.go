@@ -0,0 +1,107 @@
+package localtunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrTunnelNotFound is returned when the server does not know the requested tunnel.
+var ErrTunnelNotFound = errors.New("localtunnel: tunnel not found")
+
+// ServerStatus describes the overall state of a localtunnel server.
+type ServerStatus struct {
+	Tunnels int         `json:"tunnels"`
+	Mem     MemoryUsage `json:"mem"`
+}
+
+// MemoryUsage is the memory usage reported by the server process, in bytes.
+type MemoryUsage struct {
+	RSS       int64 `json:"rss"`
+	HeapTotal int64 `json:"heapTotal"`
+	HeapUsed  int64 `json:"heapUsed"`
+	External  int64 `json:"external"`
+}
+
+// TunnelStatus describes the state of a tunnel registered on a localtunnel server.
+type TunnelStatus struct {
+	ConnectedSockets int `json:"connected_sockets"`
+}
+
+// ServerStatus returns the status of the server, such as the number of open tunnels.
+func (c *Client) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+	var s ServerStatus
+	err := c.getJSON(ctx, "/api/status", &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// TunnelStatus returns the status of the tunnel registered with the given subdomain.
+// ErrTunnelNotFound is returned when there is no such tunnel.
+func (c *Client) TunnelStatus(ctx context.Context, subdomain string) (*TunnelStatus, error) {
+	var s TunnelStatus
+	err := c.getJSON(ctx, "/api/tunnels/"+url.PathEscape(subdomain)+"/status", &s)
+	if e, ok := err.(*statusError); ok && e.code == http.StatusNotFound {
+		return nil, ErrTunnelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v interface{}) error {
+	u, err := joinEndpoint(c.endPoint, path, "")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	setToken(req, c.token)
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{path: path, status: resp.Status, code: resp.StatusCode}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// statusError reports an unexpected HTTP response from the server.
+type statusError struct {
+	path   string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("localtunnel: unexpected response from %s: %s", e.path, e.status)
+}
+
+// SubdomainAvailable reports whether the subdomain is free to be requested, i.e. no
+// tunnel is currently registered with it.
+func (c *Client) SubdomainAvailable(ctx context.Context, subdomain string) (bool, error) {
+	_, err := c.TunnelStatus(ctx, subdomain)
+	if err == ErrTunnelNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+package localtunnel
+
+import (
+	"context"
+	"testing"
+)
+
+func TestServerStatus(t *testing.T) {
+	s := newFakeServer(t, 2)
+	c := NewClient(s.URL)
+
+	status, err := c.ServerStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Cannot get server status: %s", err)
+	}
+	if status.Tunnels != 0 {
+		t.Fatalf("Unexpected number of tunnels. Expected: 0, Actual: %d", status.Tunnels)
+	}
+	if status.Mem.RSS != 1024 {
+		t.Fatalf("Unexpected RSS. Expected: 1024, Actual: %d", status.Mem.RSS)
+	}
+
+	tunnel := c.NewStreamTunnel()
+	if err := tunnel.OpenAs("ltdemo"); err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	status, err = c.ServerStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Cannot get server status: %s", err)
+	}
+	if status.Tunnels != 1 {
+		t.Fatalf("Unexpected number of tunnels. Expected: 1, Actual: %d", status.Tunnels)
+	}
+}
+
+func TestTunnelStatus(t *testing.T) {
+	s := newFakeServer(t, 2)
+	c := NewClient(s.URL)
+
+	if _, err := c.TunnelStatus(context.Background(), "ltdemo"); err != ErrTunnelNotFound {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", ErrTunnelNotFound, err)
+	}
+
+	tunnel := c.NewStreamTunnel()
+	if err := tunnel.OpenAs("ltdemo"); err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	status, err := c.TunnelStatus(context.Background(), "ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot get tunnel status: %s", err)
+	}
+	if status.ConnectedSockets != 2 {
+		t.Fatalf("Unexpected connected sockets. Expected: 2, Actual: %d", status.ConnectedSockets)
+	}
+}
+
+func TestSubdomainAvailable(t *testing.T) {
+	s := newFakeServer(t, 2)
+	c := NewClient(s.URL)
+
+	available, err := c.SubdomainAvailable(context.Background(), "ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot check subdomain: %s", err)
+	}
+	if !available {
+		t.Fatal("Subdomain should be available")
+	}
+
+	tunnel := c.NewStreamTunnel()
+	if err := tunnel.OpenAs("ltdemo"); err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	available, err = c.SubdomainAvailable(context.Background(), "ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot check subdomain: %s", err)
+	}
+	if available {
+		t.Fatal("Subdomain should be taken")
+	}
+}
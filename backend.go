@@ -0,0 +1,14 @@
+package localtunnel
+
+import "net/http"
+
+// WithLocalBackend forwards the requests to the local server with rt, using scheme in
+// their URL, instead of the default transport over plain HTTP. Requests still arrive
+// through the tunnel as HTTP/1.1 and are re-issued by rt. It implies WithHTTPProxy.
+func WithLocalBackend(scheme string, rt http.RoundTripper) Option {
+	return func(t *Tunnel) {
+		t.proxy = true
+		t.backendScheme = scheme
+		t.backend = rt
+	}
+}
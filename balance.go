@@ -0,0 +1,80 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// StickyCookie is the cookie remembering the backend of a visitor by default.
+const StickyCookie = "lt_backend"
+
+// WithLocalBackends spreads the requests over the local server and the servers at
+// addrs, given as host:port, in turn. It implies WithHTTPProxy.
+func WithLocalBackends(addrs ...string) Option {
+	return func(t *Tunnel) {
+		t.proxy = true
+		t.backends = append(t.backends, addrs...)
+	}
+}
+
+// WithStickySessions sends all the requests of a visitor to the backend which got its
+// first request, remembered in the cookie name, or StickyCookie when empty. Local
+// servers keeping their sessions in memory then work behind WithLocalBackends.
+func WithStickySessions(name string) Option {
+	return func(t *Tunnel) {
+		if name == "" {
+			name = StickyCookie
+		}
+		t.stickyCookie = name
+	}
+}
+
+// balancer picks the local server of each request among addrs.
+type balancer struct {
+	next   uint64 // accessed atomically
+	addrs  []string
+	cookie string
+}
+
+type backendKey struct{}
+
+// balancer returns the balancer of the tunnel's local servers, or nil when there is
+// a single one.
+func (t *Tunnel) balancer() *balancer {
+	if len(t.backends) == 0 {
+		return nil
+	}
+
+	addrs := append([]string{net.JoinHostPort(t.localHost, strconv.Itoa(t.localPort))}, t.backends...)
+	return &balancer{addrs: addrs, cookie: t.stickyCookie}
+}
+
+// pick stores the local server chosen for r in its context, setting the sticky cookie
+// on w when the visitor had none or a stale one. The sticky cookie is removed from r.
+func (b *balancer) pick(w http.ResponseWriter, r *http.Request) *http.Request {
+	if b.cookie != "" {
+		c, err := r.Cookie(b.cookie)
+		removeCookie(r, b.cookie)
+		if err == nil {
+			if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(b.addrs) {
+				return r.WithContext(context.WithValue(r.Context(), backendKey{}, b.addrs[i]))
+			}
+		}
+	}
+
+	i := int((atomic.AddUint64(&b.next, 1) - 1) % uint64(len(b.addrs)))
+	if b.cookie != "" {
+		http.SetCookie(w, &http.Cookie{Name: b.cookie, Value: strconv.Itoa(i), Path: "/", HttpOnly: true})
+	}
+	return r.WithContext(context.WithValue(r.Context(), backendKey{}, b.addrs[i]))
+}
+
+// routeBackend points the URL of r to the local server picked for it, if any.
+func routeBackend(r *http.Request) {
+	if addr, ok := r.Context().Value(backendKey{}).(string); ok {
+		r.URL.Host = addr
+	}
+}
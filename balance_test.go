@@ -0,0 +1,78 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func namedServer(t *testing.T, name string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLocalBackends(t *testing.T) {
+	b1, b2 := namedServer(t, "b1"), namedServer(t, "b2")
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b0"))
+	}), WithLocalBackends(b1.Listener.Addr().String(), b2.Listener.Addr().String()))
+
+	for _, expected := range []string{"b0", "b1", "b2", "b0"} {
+		w := serve(h, httptest.NewRequest("GET", "/", nil))
+		if w.Body.String() != expected {
+			t.Fatalf("Unexpected backend. Expected: %s, Actual: %s", expected, w.Body)
+		}
+		if c := w.Header().Get("Set-Cookie"); c != "" {
+			t.Fatalf("Unexpected cookie without sticky sessions: %s", c)
+		}
+	}
+}
+
+func TestStickySessions(t *testing.T) {
+	b1 := namedServer(t, "b1")
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b0"))
+	}), WithLocalBackends(b1.Listener.Addr().String()), WithStickySessions(""))
+
+	first := serve(h, httptest.NewRequest("GET", "/", nil))
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != StickyCookie {
+		t.Fatalf("Unexpected cookies. Expected: %s, Actual: %v", StickyCookie, cookies)
+	}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		w := serve(h, req)
+		if w.Body.String() != first.Body.String() {
+			t.Fatalf("Unexpected backend of a sticky visitor. Expected: %s, Actual: %s", first.Body, w.Body)
+		}
+		if c := w.Header().Get("Set-Cookie"); c != "" {
+			t.Fatalf("Unexpected cookie for a sticky visitor: %s", c)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: StickyCookie, Value: "7"})
+	if w := serve(h, req); len(w.Result().Cookies()) != 1 {
+		t.Fatalf("A stale sticky cookie should be replaced")
+	}
+}
+
+func TestStickyCookieNotForwarded(t *testing.T) {
+	b1 := httptest.NewServer(http.HandlerFunc(cookieHandler))
+	t.Cleanup(b1.Close)
+	h := tunnelHandler(t, http.HandlerFunc(cookieHandler), WithLocalBackends(b1.Listener.Addr().String()), WithStickySessions(""))
+
+	for _, backend := range []string{"0", "1", "7"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: StickyCookie, Value: backend})
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+		if w := serve(h, req); w.Body.String() != "theme=dark" {
+			t.Fatalf("Unexpected cookies for backend %s. Expected: theme=dark, Actual: %s", backend, w.Body)
+		}
+	}
+}
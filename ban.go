@@ -0,0 +1,149 @@
+package localtunnel
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventBanned is emitted when WithBanning bans a visitor, with Client its IP address
+// and Retry how long the ban lasts.
+const EventBanned EventType = "banned"
+
+// maxVisitors bounds the visitors accounted by WithBanning. Once reached, the
+// requests of new visitors are accounted under OtherVisitors, and never banned,
+// until the visitors idle for a window are forgotten.
+const maxVisitors = 1000
+
+// OtherVisitors accounts the requests of the visitors which did not fit in the
+// accounting of WithBanning, or whose IP address is unknown.
+const OtherVisitors = "*"
+
+// A Visitor is the accounting of the requests of a visitor by WithBanning.
+type Visitor struct {
+	Requests    int64      `json:"requests"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"` // while banned
+}
+
+// WithBanning counts the requests of each visitor, by IP address, and bans the ones
+// making more than limit requests within window for the duration of ban: their
+// requests are refused with 429 Too Many Requests, as the scanners and bots
+// common on public URLs would be. The accounting is read with Visitors, and
+// Stats tells how many bans were made. It implies WithHTTPProxy.
+func WithBanning(limit int, window, ban time.Duration) Option {
+	return func(t *Tunnel) {
+		t.visitors = &visitors{t: t, limit: limit, window: window, ban: ban, m: map[string]*visitor{}}
+		t.use(t.visitors.middleware)
+	}
+}
+
+// Visitors returns a snapshot of the requests of the visitors of a tunnel created
+// with WithBanning, by IP address, or nil.
+func (t *Tunnel) Visitors() map[string]Visitor {
+	if t.visitors == nil {
+		return nil
+	}
+	return t.visitors.snapshot()
+}
+
+type visitors struct {
+	t      *Tunnel
+	limit  int
+	window time.Duration
+	ban    time.Duration
+
+	mu sync.Mutex
+	m  map[string]*visitor
+}
+
+type visitor struct {
+	requests    int64
+	windowStart time.Time
+	inWindow    int
+	bannedUntil time.Time
+}
+
+func (vs *visitors) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := vs.count(r); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// count accounts r, returning how long its visitor remains banned.
+func (vs *visitors) count(r *http.Request) time.Duration {
+	now := vs.t.now()
+	key := OtherVisitors
+	if ip := clientIP(r); ip != nil {
+		key = ip.String()
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	v, ok := vs.m[key]
+	if !ok {
+		if len(vs.m) >= maxVisitors {
+			vs.forget(now)
+		}
+		if len(vs.m) >= maxVisitors {
+			key = OtherVisitors
+			v = vs.m[key]
+		}
+		if v == nil {
+			v = &visitor{}
+			vs.m[key] = v
+		}
+	}
+
+	v.requests++
+	if now.Before(v.bannedUntil) {
+		return v.bannedUntil.Sub(now)
+	}
+
+	if now.Sub(v.windowStart) >= vs.window {
+		v.windowStart, v.inWindow = now, 0
+	}
+	v.inWindow++
+	if key == OtherVisitors || vs.limit <= 0 || v.inWindow <= vs.limit {
+		return 0
+	}
+
+	v.bannedUntil = now.Add(vs.ban)
+	vs.t.stats.addBans(1)
+	vs.t.emit(Event{Type: EventBanned, Client: key, Retry: vs.ban})
+	return vs.ban
+}
+
+// forget drops the visitors neither banned nor seen within the current window. It is
+// called with mu held.
+func (vs *visitors) forget(now time.Time) {
+	for key, v := range vs.m {
+		if key != OtherVisitors && !now.Before(v.bannedUntil) && now.Sub(v.windowStart) >= vs.window {
+			delete(vs.m, key)
+		}
+	}
+}
+
+func (vs *visitors) snapshot() map[string]Visitor {
+	now := vs.t.now()
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	m := make(map[string]Visitor, len(vs.m))
+	for key, v := range vs.m {
+		s := Visitor{Requests: v.requests}
+		if now.Before(v.bannedUntil) {
+			until := v.bannedUntil
+			s.BannedUntil = &until
+		}
+		m[key] = s
+	}
+	return m
+}
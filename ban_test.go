@@ -0,0 +1,93 @@
+package localtunnel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBanning(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	clock := newFakeClock()
+	events := make(chan Event, 16)
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithBanning(2, time.Minute, time.Hour), WithClock(clock), WithEvents(events))
+	h := tunnel.httpHandler()
+
+	get := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		return serve(h, req)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get("198.51.100.1"); w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status below the limit. Expected: %d, Actual: %d", http.StatusOK, w.Code)
+		}
+	}
+	w := get("198.51.100.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Fatalf("Unexpected response above the limit. Expected: 429 retrying after 3600, Actual: %d %s", w.Code, w.Header().Get("Retry-After"))
+	}
+	if e := <-events; e.Type != EventBanned || e.Client != "198.51.100.1" || e.Retry != time.Hour {
+		t.Fatalf("Unexpected event. Actual: %+v", e)
+	}
+	if w := get("198.51.100.2"); w.Code != http.StatusOK {
+		t.Fatalf("Other visitors should not be banned. Actual: %d", w.Code)
+	}
+
+	visitors := tunnel.Visitors()
+	banned, other := visitors["198.51.100.1"], visitors["198.51.100.2"]
+	if banned.Requests != 3 || banned.BannedUntil == nil || !banned.BannedUntil.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("Unexpected banned visitor. Actual: %+v", banned)
+	}
+	if other.Requests != 1 || other.BannedUntil != nil {
+		t.Fatalf("Unexpected visitor. Actual: %+v", other)
+	}
+	if n := tunnel.Stats().Bans; n != 1 {
+		t.Fatalf("Unexpected bans. Expected: 1, Actual: %d", n)
+	}
+
+	clock.Advance(time.Hour)
+	if w := get("198.51.100.1"); w.Code != http.StatusOK {
+		t.Fatalf("The ban should expire. Actual: %d", w.Code)
+	}
+	if tunnel.Visitors()["198.51.100.1"].BannedUntil != nil {
+		t.Fatal("The ban should expire")
+	}
+}
+
+func TestBanningForgetsVisitors(t *testing.T) {
+	clock := newFakeClock()
+	vs := &visitors{t: &Tunnel{clock: clock}, limit: 1, window: time.Minute, ban: time.Hour, m: map[string]*visitor{}}
+
+	count := func(ip string) time.Duration {
+		req := httptest.NewRequest("GET", "/", nil)
+		return vs.count(req.WithContext(context.WithValue(req.Context(), clientIPKey{}, ip)))
+	}
+
+	count("198.51.100.1")
+	count("198.51.100.1") // banned
+	for i := 1; len(vs.m) < maxVisitors; i++ {
+		count(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+
+	count("203.0.113.1")
+	count("203.0.113.1")
+	if _, ok := vs.m["203.0.113.1"]; ok {
+		t.Fatal("Visitors beyond the limit should not be accounted on their own")
+	}
+	if v := vs.m[OtherVisitors]; v == nil || v.requests != 2 || !v.bannedUntil.IsZero() {
+		t.Fatalf("Unexpected other visitors. Actual: %+v", v)
+	}
+
+	clock.Advance(time.Minute)
+	count("203.0.113.1")
+	if _, ok := vs.m["203.0.113.1"]; !ok || vs.m["198.51.100.1"] == nil || len(vs.m) != 3 {
+		t.Fatalf("The idle visitors should be forgotten, but not the banned ones. Actual: %d visitors", len(vs.m))
+	}
+}
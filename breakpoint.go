@@ -0,0 +1,247 @@
+package localtunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotHeld is returned when releasing or rejecting a request which is not held.
+var ErrNotHeld = errors.New("localtunnel: request not held")
+
+// DefaultBreakpointTimeout is how long a request is held by default.
+const DefaultBreakpointTimeout = 5 * time.Minute
+
+// Breakpoints hold the requests under some paths until they are released to the local
+// server, possibly edited, or rejected, e.g. to inspect webhooks while developing them.
+type Breakpoints struct {
+	// Timeout is how long a request is held before being rejected with 504 Gateway
+	// Timeout, DefaultBreakpointTimeout when zero.
+	Timeout time.Duration
+
+	// MaxBodySize is the size of the largest body held, larger requests being
+	// rejected with 413 Request Entity Too Large. Defaults to 1 MiB.
+	MaxBodySize int64
+
+	paths []string
+
+	m       sync.Mutex
+	next    int64
+	held    map[int64]*heldRequest
+	changed chan struct{} // closed when a request is held
+}
+
+// A HeldRequest is a request held by Breakpoints.
+type HeldRequest struct {
+	ID     int64       `json:"id"`
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	URL    string      `json:"url"` // path and query
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// A RequestEdit changes a held request before it is released. Empty fields leave
+// the request unchanged.
+type RequestEdit struct {
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url,omitempty"`    // path and query
+	Header http.Header `json:"header,omitempty"` // replaces the whole header
+	Body   *string     `json:"body,omitempty"`
+}
+
+type heldRequest struct {
+	HeldRequest
+	decision chan decision
+}
+
+type decision struct {
+	edit   *RequestEdit
+	status int // rejects the request unless zero
+}
+
+// NewBreakpoints returns breakpoints holding the requests whose path is one of paths
+// or lies under it, e.g. "/hooks" holds "/hooks/github". "/" holds every request.
+func NewBreakpoints(paths ...string) *Breakpoints {
+	return &Breakpoints{paths: paths, held: map[int64]*heldRequest{}, changed: make(chan struct{})}
+}
+
+// WithBreakpoints holds the requests matching b until they are released or rejected.
+// It implies WithHTTPProxy.
+func WithBreakpoints(b *Breakpoints) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !b.match(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				b.serve(w, r, next, t.now())
+			})
+		})
+	}
+}
+
+func (b *Breakpoints) match(r *http.Request) bool {
+	for _, p := range b.paths {
+		if hasPathPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Breakpoints) serve(w http.ResponseWriter, r *http.Request, next http.Handler, now time.Time) {
+	limit := b.MaxBodySize
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	h := b.hold(r, body, now)
+	defer b.drop(h.ID)
+
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = DefaultBreakpointTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var d decision
+	select {
+	case d = <-h.decision:
+	case <-timer.C:
+		d.status = http.StatusGatewayTimeout
+	case <-r.Context().Done():
+		return
+	}
+
+	if d.status != 0 {
+		http.Error(w, http.StatusText(d.status), d.status)
+		return
+	}
+
+	if e := d.edit; e != nil {
+		if e.Method != "" {
+			r.Method = e.Method
+		}
+		if e.URL != "" {
+			if u, err := url.ParseRequestURI(e.URL); err == nil {
+				r.URL.Path, r.URL.RawPath, r.URL.RawQuery = u.Path, u.RawPath, u.RawQuery
+				r.RequestURI = ""
+			}
+		}
+		if e.Header != nil {
+			r.Header = e.Header
+		}
+		if e.Body != nil {
+			body = []byte(*e.Body)
+		}
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Del("Content-Length")
+	r.TransferEncoding = nil
+
+	next.ServeHTTP(w, r)
+}
+
+func (b *Breakpoints) hold(r *http.Request, body []byte, now time.Time) *heldRequest {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.next++
+	h := &heldRequest{
+		HeldRequest: HeldRequest{
+			ID:     b.next,
+			Time:   now,
+			Method: r.Method,
+			URL:    r.URL.RequestURI(),
+			Header: r.Header.Clone(),
+			Body:   string(body),
+		},
+		decision: make(chan decision, 1),
+	}
+	b.held[h.ID] = h
+
+	close(b.changed)
+	b.changed = make(chan struct{})
+	return h
+}
+
+func (b *Breakpoints) drop(id int64) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	delete(b.held, id)
+}
+
+// Held returns the requests being held, oldest first.
+func (b *Breakpoints) Held() []HeldRequest {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	held := make([]HeldRequest, 0, len(b.held))
+	for _, h := range b.held {
+		held = append(held, h.HeldRequest)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].ID < held[j].ID })
+	return held
+}
+
+// Wait returns the requests being held, waiting for one when there is none until ctx
+// is done.
+func (b *Breakpoints) Wait(ctx context.Context) ([]HeldRequest, error) {
+	for {
+		b.m.Lock()
+		changed := b.changed
+		b.m.Unlock()
+
+		if held := b.Held(); len(held) > 0 {
+			return held, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release lets the held request go to the local server, changed by edit unless nil.
+func (b *Breakpoints) Release(id int64, edit *RequestEdit) error {
+	return b.decide(id, decision{edit: edit})
+}
+
+// Reject answers the held request with status, instead of sending it to the local
+// server.
+func (b *Breakpoints) Reject(id int64, status int) error {
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	return b.decide(id, decision{status: status})
+}
+
+func (b *Breakpoints) decide(id int64, d decision) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	h, ok := b.held[id]
+	if !ok {
+		return ErrNotHeld
+	}
+	delete(b.held, id)
+	h.decision <- d
+	return nil
+}
@@ -0,0 +1,117 @@
+package localtunnel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveAsync serves req in the background, returning the recorded response once done.
+func serveAsync(h http.Handler, req *http.Request) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- serve(h, req) }()
+	return done
+}
+
+// waitHeld returns the first request held by b.
+func waitHeld(t *testing.T, b *Breakpoints) HeldRequest {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	held, err := b.Wait(ctx)
+	if err != nil {
+		t.Fatalf("No request held: %s", err)
+	}
+	return held[0]
+}
+
+func TestBreakpointRelease(t *testing.T) {
+	b := NewBreakpoints("/hooks")
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithBreakpoints(b))
+
+	if w := serve(h, httptest.NewRequest("POST", "/api", strings.NewReader("free"))); w.Body.String() != "POST /api free" {
+		t.Fatalf("Unexpected response to a request not held. Actual: %q", w.Body.String())
+	}
+
+	done := serveAsync(h, httptest.NewRequest("POST", "/hooks/github?x=1", strings.NewReader("push")))
+	held := waitHeld(t, b)
+	if held.Method != "POST" || held.URL != "/hooks/github?x=1" || held.Body != "push" {
+		t.Fatalf("Unexpected held request. Actual: %+v", held)
+	}
+	select {
+	case <-done:
+		t.Fatalf("Held request should not be answered before being released")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err := b.Release(held.ID, nil)
+	if err != nil {
+		t.Fatalf("Cannot release the request: %s", err)
+	}
+	if w := <-done; w.Body.String() != "POST /hooks/github push" {
+		t.Fatalf("Unexpected response to the released request. Actual: %q", w.Body.String())
+	}
+
+	if held := b.Held(); len(held) != 0 {
+		t.Fatalf("Released request should no longer be held. Actual: %+v", held)
+	}
+	if err := b.Release(held.ID, nil); err != ErrNotHeld {
+		t.Fatalf("Unexpected error releasing twice. Expected: %v, Actual: %v", ErrNotHeld, err)
+	}
+}
+
+func TestBreakpointEdit(t *testing.T) {
+	b := NewBreakpoints("/")
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithBreakpoints(b))
+
+	done := serveAsync(h, httptest.NewRequest("POST", "/hooks", strings.NewReader("push")))
+	body := "edited body"
+	err := b.Release(waitHeld(t, b).ID, &RequestEdit{Method: "PUT", URL: "/other", Body: &body})
+	if err != nil {
+		t.Fatalf("Cannot release the request: %s", err)
+	}
+	if w := <-done; w.Body.String() != "PUT /other edited body" {
+		t.Fatalf("Unexpected response to the edited request. Actual: %q", w.Body.String())
+	}
+}
+
+func TestBreakpointReject(t *testing.T) {
+	b := NewBreakpoints("/hooks")
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithBreakpoints(b))
+
+	done := serveAsync(h, httptest.NewRequest("GET", "/hooks", nil))
+	err := b.Reject(waitHeld(t, b).ID, http.StatusTeapot)
+	if err != nil {
+		t.Fatalf("Cannot reject the request: %s", err)
+	}
+	if w := <-done; w.Code != http.StatusTeapot {
+		t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusTeapot, w.Code)
+	}
+}
+
+func TestBreakpointTimeout(t *testing.T) {
+	b := NewBreakpoints("/hooks")
+	b.Timeout = 10 * time.Millisecond
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithBreakpoints(b))
+
+	if w := serve(h, httptest.NewRequest("GET", "/hooks", nil)); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusGatewayTimeout, w.Code)
+	}
+	if held := b.Held(); len(held) != 0 {
+		t.Fatalf("Timed out request should no longer be held. Actual: %+v", held)
+	}
+}
+
+func TestBreakpointBodyTooLarge(t *testing.T) {
+	b := NewBreakpoints("/hooks")
+	b.MaxBodySize = 4
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithBreakpoints(b))
+
+	w := serve(h, httptest.NewRequest("POST", "/hooks", strings.NewReader("too large")))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
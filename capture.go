@@ -0,0 +1,272 @@
+package localtunnel
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the redacted parts of captured requests.
+const Redacted = "[REDACTED]"
+
+var (
+	// DefaultRedactedHeaders are the headers redacted by NewCapture.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+	// CardNumbers matches payment card numbers, optionally grouped by spaces or dashes.
+	CardNumbers = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// A RequestRecord is an HTTP exchange captured by a tunnel.
+type RequestRecord struct {
+	ID       int64         `json:"id"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+
+	Method        string      `json:"method"`
+	Host          string      `json:"host"`
+	URL           string      `json:"url"`
+	Proto         string      `json:"proto"`
+	RequestHeader http.Header `json:"request_header"`
+	RequestBody   []byte      `json:"request_body,omitempty"`
+
+	// RequestBodyFile is the file holding the captured request body when it was
+	// spooled to disk, in which case RequestBody is empty.
+	RequestBodyFile string `json:"request_body_file,omitempty"`
+
+	// RequestSize is the size of the whole request body, which may be larger than
+	// the captured RequestBody.
+	RequestSize int64 `json:"request_size"`
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header"`
+	ResponseBody   []byte      `json:"response_body,omitempty"`
+	ResponseSize   int64       `json:"response_size"`
+
+	// ResponseBodyFile is the file holding the captured response body when it was
+	// spooled to disk, in which case ResponseBody is empty.
+	ResponseBodyFile string `json:"response_body_file,omitempty"`
+}
+
+// A Capture records the HTTP exchanges of the tunnels using it.
+// Its fields must not be changed once a tunnel is open.
+type Capture struct {
+	// MaxBodySize is the number of bytes kept from each body. Bodies are not
+	// captured when zero, and fully captured when negative.
+	MaxBodySize int64
+
+	// RedactHeaders are the headers whose values are replaced by Redacted.
+	RedactHeaders []string
+
+	// RedactBody are the patterns replaced by Redacted in the captured bodies.
+	RedactBody []*regexp.Regexp
+
+	// Store keeps the captured requests.
+	Store CaptureStore
+
+	// SpoolThreshold is the size above which a captured body is written to a temporary
+	// file instead of being kept in memory. Bodies are never spooled when zero.
+	SpoolThreshold int64
+
+	// SpoolDir is the directory of the spooled bodies, os.TempDir when empty.
+	SpoolDir string
+}
+
+// NewCapture returns a Capture keeping the last 100 requests in memory, with bodies of
+// up to 64KiB and redacting DefaultRedactedHeaders.
+func NewCapture() *Capture {
+	return &Capture{
+		MaxBodySize:   64 << 10,
+		RedactHeaders: DefaultRedactedHeaders,
+		Store:         NewMemoryStore(Retention{MaxRecords: 100}),
+	}
+}
+
+// WithCapture records the requests served by the tunnel in c. It implies WithHTTPProxy.
+func WithCapture(c *Capture) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler { return c.middleware(next, t.now) })
+	}
+}
+
+// Records returns the captured requests, oldest first.
+func (c *Capture) Records() ([]RequestRecord, error) {
+	return c.Store.Records()
+}
+
+// Reset drops all captured requests.
+func (c *Capture) Reset() error {
+	return c.Store.Reset()
+}
+
+// middleware captures the requests served by next, timed by now.
+func (c *Capture) middleware(next http.Handler, now func() time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := now()
+		reqBody := c.newBodyCapture()
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &teeReadCloser{r: io.TeeReader(r.Body, reqBody), c: r.Body}
+		}
+
+		record := RequestRecord{
+			Time:          start,
+			Method:        r.Method,
+			Host:          r.Host,
+			URL:           r.URL.RequestURI(),
+			Proto:         r.Proto,
+			RequestHeader: c.redactHeader(r.Header),
+		}
+
+		cw := &captureWriter{ResponseWriter: w, body: c.newBodyCapture()}
+		next.ServeHTTP(cw, r)
+
+		record.Duration = now().Sub(start)
+		record.RequestBody, record.RequestBodyFile = c.finish(reqBody)
+		record.RequestSize = reqBody.size
+		record.Status = cw.status
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+		record.ResponseHeader = c.redactHeader(w.Header())
+		record.ResponseBody, record.ResponseBodyFile = c.finish(cw.body)
+		record.ResponseSize = cw.body.size
+		c.Store.Add(&record)
+	})
+}
+
+func (c *Capture) redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range c.RedactHeaders {
+		values := h[http.CanonicalHeaderKey(name)]
+		for i := range values {
+			values[i] = Redacted
+		}
+	}
+	return h
+}
+
+func (c *Capture) redactBody(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+
+	b = append([]byte(nil), b...)
+	for _, re := range c.RedactBody {
+		b = re.ReplaceAll(b, []byte(Redacted))
+	}
+	return b
+}
+
+// bodyCapture keeps up to limit bytes written to it while counting them all.
+// Once more than spool bytes are kept, they are moved to a temporary file.
+type bodyCapture struct {
+	limit int64
+	size  int64
+	buf   bytes.Buffer
+
+	kept  int64
+	spool int64
+	dir   string
+	f     *os.File
+}
+
+func (c *Capture) newBodyCapture() *bodyCapture {
+	return &bodyCapture{limit: c.MaxBodySize, spool: c.SpoolThreshold, dir: c.SpoolDir}
+}
+
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	n := int64(len(p))
+	if b.limit >= 0 {
+		if room := b.limit - b.kept; n > room {
+			n = room
+		}
+	}
+	if n > 0 {
+		b.keep(p[:n])
+	}
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+func (b *bodyCapture) keep(p []byte) {
+	b.kept += int64(len(p))
+	if b.f == nil && b.spool > 0 && b.kept > b.spool {
+		f, err := ioutil.TempFile(b.dir, "lt-body-")
+		if err == nil {
+			b.f = f
+			b.buf.WriteTo(f)
+		}
+	}
+
+	if b.f != nil {
+		b.f.Write(p)
+	} else {
+		b.buf.Write(p)
+	}
+}
+
+type teeReadCloser struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) { return t.r.Read(p) }
+func (t *teeReadCloser) Close() error               { return t.c.Close() }
+
+// captureWriter records the response written by the local server.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   *bodyCapture
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		if w.status == 0 {
+			w.status = http.StatusSwitchingProtocols
+		}
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// contentType returns the media type of a header, without parameters.
+func contentType(h http.Header) string {
+	t := h.Get("Content-Type")
+	if i := strings.Index(t, ";"); i >= 0 {
+		t = t[:i]
+	}
+	return strings.TrimSpace(t)
+}
@@ -0,0 +1,95 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapture(t *testing.T) {
+	c := NewCapture()
+	c.RedactBody = []*regexp.Regexp{CardNumbers}
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t"})
+		w.WriteHeader(http.StatusCreated)
+		echoHandler(w, r)
+	}), WithCapture(c))
+
+	req := httptest.NewRequest("POST", "/pay?amount=10", strings.NewReader("card=4111 1111 1111 1111&name=jane"))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Request-Id", "42")
+	serve(h, req)
+
+	records, _ := c.Records()
+	if len(records) != 1 {
+		t.Fatalf("Unexpected number of records. Expected: 1, Actual: %d", len(records))
+	}
+
+	r := records[0]
+	if r.ID != 1 || r.Method != "POST" || r.URL != "/pay?amount=10" || r.Status != http.StatusCreated {
+		t.Fatalf("Unexpected record: %d %s %s %d", r.ID, r.Method, r.URL, r.Status)
+	}
+	if r.RequestHeader.Get("Authorization") != Redacted {
+		t.Fatalf("Authorization should be redacted. Actual: %s", r.RequestHeader.Get("Authorization"))
+	}
+	if r.RequestHeader.Get("X-Request-Id") != "42" {
+		t.Fatalf("Unexpected header. Expected: 42, Actual: %s", r.RequestHeader.Get("X-Request-Id"))
+	}
+	if r.ResponseHeader.Get("Set-Cookie") != Redacted {
+		t.Fatalf("Set-Cookie should be redacted. Actual: %s", r.ResponseHeader.Get("Set-Cookie"))
+	}
+	if string(r.RequestBody) != "card=[REDACTED]&name=jane" {
+		t.Fatalf("Unexpected request body: %s", r.RequestBody)
+	}
+	if string(r.ResponseBody) != "POST /pay card=[REDACTED]&name=jane" {
+		t.Fatalf("Unexpected response body: %s", r.ResponseBody)
+	}
+}
+
+func TestCaptureClock(t *testing.T) {
+	c := NewCapture()
+	clock := newFakeClock()
+	start := clock.Now()
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(time.Second)
+	}), WithClock(clock), WithCapture(c))
+	serve(h, httptest.NewRequest("GET", "/", nil))
+
+	records, _ := c.Records()
+	if len(records) != 1 || !records[0].Time.Equal(start) || records[0].Duration != time.Second {
+		t.Fatalf("Unexpected record timed by the clock. Expected: %s for 1s, Actual: %+v", start, records)
+	}
+}
+
+func TestCaptureLimits(t *testing.T) {
+	c := &Capture{MaxBodySize: 4, Store: NewMemoryStore(Retention{MaxRecords: 2})}
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithCapture(c))
+
+	for _, body := range []string{"first", "second", "third"} {
+		w := serve(h, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if w.Body.String() != "POST / "+body {
+			t.Fatalf("Capture should not change the response. Actual: %s", w.Body)
+		}
+	}
+
+	records, _ := c.Records()
+	if len(records) != 2 || records[0].ID != 2 || records[1].ID != 3 {
+		t.Fatalf("Only the last 2 records should be kept. Actual: %d records", len(records))
+	}
+
+	r := records[1]
+	if string(r.RequestBody) != "thir" || r.RequestSize != 5 {
+		t.Fatalf("Unexpected request body. Expected: 'thir' of 5 bytes, Actual: '%s' of %d bytes", r.RequestBody, r.RequestSize)
+	}
+	if string(r.ResponseBody) != "POST" || r.ResponseSize != 12 {
+		t.Fatalf("Unexpected response body. Expected: 'POST' of 12 bytes, Actual: '%s' of %d bytes", r.ResponseBody, r.ResponseSize)
+	}
+
+	c.Reset()
+	if records, _ := c.Records(); len(records) != 0 {
+		t.Fatal("Records should be empty after reset")
+	}
+}
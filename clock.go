@@ -0,0 +1,72 @@
+package localtunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"time"
+)
+
+// A Clock tells the time and waits for durations to elapse. The tunnel uses it for
+// the rate limit backoff, the pool supervisor and the trickle of its connections,
+// the expiry of share links, the time to first connection, the time of the captured
+// requests and the time of its events.
+type Clock interface {
+	Now() time.Time
+
+	// After sends the current time on the returned channel once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// WithClock replaces the real clock of the tunnel, so reconnect scenarios can be
+// simulated without sleeping. Connection deadlines still follow the real clock.
+func WithClock(c Clock) Option {
+	return func(t *Tunnel) { t.clock = c }
+}
+
+// WithRand replaces crypto/rand as the source of the tunnel's random secrets.
+func WithRand(r io.Reader) Option {
+	return func(t *Tunnel) { t.rand = r }
+}
+
+func (t *Tunnel) now() time.Time {
+	if t.clock == nil {
+		return time.Now()
+	}
+	return t.clock.Now()
+}
+
+func (t *Tunnel) after(d time.Duration) <-chan time.Time {
+	if t.clock == nil {
+		return time.After(d)
+	}
+	return t.clock.After(d)
+}
+
+type clockKey struct{}
+
+// withClock passes the clock of the tunnel, if any, to the providers registering
+// with ctx, e.g. to compute the wait asked by a Retry-After date.
+func (t *Tunnel) withClock(ctx context.Context) context.Context {
+	if t.clock == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clockKey{}, t.clock)
+}
+
+// clockNow returns the time of the clock carried by ctx, or the real time.
+func clockNow(ctx context.Context) time.Time {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// randomBytes reads n bytes from the tunnel's source of randomness.
+func (t *Tunnel) randomBytes(n int) []byte {
+	r := t.rand
+	if r == nil {
+		r = rand.Reader
+	}
+	return readRandom(r, n)
+}
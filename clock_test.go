@@ -0,0 +1,176 @@
+package localtunnel
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves forward when advanced.
+type fakeClock struct {
+	m       sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward, firing the waits which elapsed.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// pending returns the number of waits which did not elapse.
+func (c *fakeClock) pending() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return len(c.waiters)
+}
+
+func TestClockDrivesRateLimitBackoff(t *testing.T) {
+	s := rateLimitedServer(t, 1, "60")
+	clock := newFakeClock()
+	events := make(chan Event, 10)
+
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithClock(clock), WithEvents(events))
+	errCh := make(chan error, 1)
+	go func() { errCh <- tunnel.Open() }()
+	defer tunnel.Close()
+
+	e := <-events
+	if e.Type != EventRateLimited || !e.Time.Equal(clock.Now()) {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("Cannot open tunnel: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the tunnel to open")
+	}
+}
+
+func TestClockExpiresShareURL(t *testing.T) {
+	s := newFakeServer(t, 1)
+	clock := newFakeClock()
+	secret := bytes.Repeat([]byte{1}, 32)
+
+	tunnel := NewClient(s.URL).NewLocalTunnel(8000, WithSignedAccess(nil), WithClock(clock), WithRand(bytes.NewReader(secret)))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	share, err := tunnel.ShareURL(time.Hour)
+	if err != nil {
+		t.Fatalf("Cannot create share URL: %s", err)
+	}
+
+	u, err := url.Parse(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := verifyValue(secret, sharePurpose, u.Query().Get(TokenParam), clock.Now()); !ok {
+		t.Fatalf("Share URL should be signed with the secret read from WithRand: %s", share)
+	}
+
+	h := tunnel.httpHandler()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(TokenHeader, signValue(secret, sharePurpose, "share", clock.Now().Add(time.Hour)))
+
+	clock.Advance(59 * time.Minute)
+	if w := serve(h, req); w.Code == http.StatusForbidden {
+		t.Fatal("Token should be valid before its expiry")
+	}
+
+	clock.Advance(2 * time.Minute)
+	if w := serve(h, req); w.Code != http.StatusForbidden {
+		t.Fatalf("Token should expire with the clock. Actual: %d", w.Code)
+	}
+}
+
+func TestClockDrivesRetryAfterDate(t *testing.T) {
+	clock := newFakeClock()
+	s := rateLimitedServer(t, 1, clock.Now().Add(time.Minute).Format(http.TimeFormat))
+	events := make(chan Event, 10)
+
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithClock(clock), WithEvents(events))
+	errCh := make(chan error, 1)
+	go func() { errCh <- tunnel.Open() }()
+	defer tunnel.Close()
+
+	e := <-events
+	if e.Type != EventRateLimited || e.Retry != time.Minute {
+		t.Fatalf("Unexpected wait for a Retry-After date. Expected: %s, Actual: %+v", time.Minute, e)
+	}
+
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	if err := <-errCh; err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+}
+
+func TestRandDrivesOAuthSecrets(t *testing.T) {
+	clock := newFakeClock()
+	random := bytes.Repeat([]byte{1}, 48)
+
+	tunnel := NewTunnel("127.0.0.1", 8000, WithOAuth(OAuthConfig{Provider: GitHubOAuth}), WithClock(clock), WithRand(bytes.NewReader(random)))
+	tunnel.url = "https://example.com"
+	w := serve(tunnel.httpHandler(), httptest.NewRequest("GET", "/", nil))
+
+	state := hex.EncodeToString(random[32:])
+	expected := signValue(random[:32], statePurpose, state+"|/", clock.Now().Add(10*time.Minute))
+	if cookie := w.Result().Cookies()[0]; cookie.Value != expected {
+		t.Fatalf("Unexpected state cookie. Expected: %s, Actual: %s", expected, cookie.Value)
+	}
+}
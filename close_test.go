@@ -0,0 +1,243 @@
+package localtunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tunnelGoroutines returns the stacks of the running goroutines executing the
+// package's code, outside tests.
+func tunnelGoroutines() []string {
+	b := make([]byte, 1<<20)
+	b = b[:runtime.Stack(b, true)]
+
+	var stacks []string
+	for _, g := range strings.Split(string(b), "\n\n") {
+		if strings.Contains(g, "jweslley/localtunnel.") && !strings.Contains(g, "_test.go") {
+			stacks = append(stacks, g)
+		}
+	}
+	return stacks
+}
+
+// checkNoGoroutines fails if goroutines of the package are still running shortly after.
+func checkNoGoroutines(t *testing.T) {
+	var stacks []string
+	for i := 0; i < 50; i++ {
+		if stacks = tunnelGoroutines(); len(stacks) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Leaked goroutines:\n\n%s", strings.Join(stacks, "\n\n"))
+}
+
+func TestCloseWaitsForGoroutines(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	tests := map[string][]Option{
+		"raw":   nil,
+		"proxy": {WithHTTPProxy()},
+	}
+
+	for name, opts := range tests {
+		s := newFakeServer(t, 3)
+		tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", getServerPort(t, local), opts...)
+		if tunnel.Done() != nil {
+			t.Fatalf("%s: Done should be nil before open", name)
+		}
+
+		err := tunnel.Open()
+		if err != nil {
+			t.Fatalf("%s: cannot open tunnel: %s", name, err)
+		}
+
+		// one connection busy with a request, the others idle
+		remote := s.conn(t)
+		s.conn(t)
+		s.conn(t)
+		fmt.Fprint(remote, "GET / HTTP/1.1\r\nHost: demo.loca.lt\r\n\r\n")
+		remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+		remote.Read(make([]byte, 1))
+
+		tunnel.Close()
+		select {
+		case <-tunnel.Done():
+		default:
+			t.Fatalf("%s: Done should be closed once Close returns", name)
+		}
+		checkNoGoroutines(t)
+	}
+}
+
+func TestCloseWaitsForHandlers(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	slow := func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(entered)
+				<-release
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+
+	s := newFakeServer(t, 1)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", getServerPort(t, local), slow)
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	fmt.Fprint(s.conn(t), "GET / HTTP/1.1\r\nHost: demo.loca.lt\r\n\r\n")
+	<-entered
+
+	done := make(chan struct{})
+	go func() {
+		tunnel.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Close should wait for the handlers in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should return once the handlers in flight are done")
+	}
+	checkNoGoroutines(t)
+}
+
+func TestCloseStreamTunnel(t *testing.T) {
+	s := newFakeServer(t, 2)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+
+	remote := s.conn(t)
+	s.conn(t)
+	remote.Write([]byte("ping"))
+
+	st, err := tunnel.AcceptStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		st.Close()
+	}()
+	tunnel.Close()
+	checkNoGoroutines(t)
+}
+
+func TestCloseWithUnresponsiveLocalServer(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	// a local server which never accepts its connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", ln.Addr().(*net.TCPAddr).Port)
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	s.conn(t)
+
+	done := make(chan struct{})
+	go func() {
+		tunnel.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should not hang")
+	}
+	checkNoGoroutines(t)
+}
+
+func TestCloseReason(t *testing.T) {
+	s := newFakeServer(t, 1)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+
+	if tunnel.Err() != nil {
+		t.Fatalf("Unexpected error before open: %v", tunnel.Err())
+	}
+
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	if tunnel.Err() != nil {
+		t.Fatalf("Unexpected error while open: %v", tunnel.Err())
+	}
+
+	tunnel.Close()
+	if tunnel.Err() != ErrClosed {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrClosed, tunnel.Err())
+	}
+
+	// the server goes away while the tunnel is reopened
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot reopen tunnel: %s", err)
+	}
+	c := s.conn(t)
+	s.ln.Close()
+	c.Close()
+
+	select {
+	case <-tunnel.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the tunnel to close")
+	}
+	if !errors.Is(tunnel.Err(), ErrServerLost) {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrServerLost, tunnel.Err())
+	}
+}
+
+func TestCloseReasonLocalUnreachable(t *testing.T) {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(http.NotFoundHandler())
+	port := getServerPort(t, local)
+	local.Close()
+
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", port)
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	s.conn(t)
+
+	select {
+	case <-tunnel.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the tunnel to close")
+	}
+	if !errors.Is(tunnel.Err(), ErrLocalUnreachable) {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrLocalUnreachable, tunnel.Err())
+	}
+}
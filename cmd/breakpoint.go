@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// handleBreakpoints serves the requests held by b on the control socket: GET
+// /breakpoints lists them, waiting for one up to the duration given by wait, and
+// POST /breakpoints/release or /breakpoints/reject decides the fate of the one given
+// by id.
+func handleBreakpoints(mux *http.ServeMux, b *lt.Breakpoints) {
+	notHeld := func(w http.ResponseWriter) {
+		http.Error(w, "Breakpoints are not set, run lt with -break", http.StatusNotFound)
+	}
+
+	mux.HandleFunc("/breakpoints", func(w http.ResponseWriter, r *http.Request) {
+		if b == nil {
+			notHeld(w)
+			return
+		}
+
+		held := b.Held()
+		if wait, err := time.ParseDuration(r.URL.Query().Get("wait")); err == nil && len(held) == 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			held, _ = b.Wait(ctx)
+			cancel()
+		}
+		if held == nil {
+			held = []lt.HeldRequest{}
+		}
+		json.NewEncoder(w).Encode(held)
+	})
+
+	decide := func(w http.ResponseWriter, r *http.Request, decide func(id int64) error) {
+		if b == nil {
+			notHeld(w)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+
+		err = decide(id)
+		if err == lt.ErrNotHeld {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	mux.HandleFunc("/breakpoints/release", func(w http.ResponseWriter, r *http.Request) {
+		decide(w, r, func(id int64) error {
+			var edit *lt.RequestEdit
+			if err := json.NewDecoder(r.Body).Decode(&edit); err != nil && err != io.EOF {
+				return err
+			}
+			return b.Release(id, edit)
+		})
+	})
+	mux.HandleFunc("/breakpoints/reject", func(w http.ResponseWriter, r *http.Request) {
+		decide(w, r, func(id int64) error {
+			status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+			return b.Reject(id, status)
+		})
+	})
+}
+
+func breakCommand(args []string) error {
+	fs := flag.NewFlagSet("break", flag.ExitOnError)
+	status := fs.Int("status", http.StatusForbidden, "Status answered to the rejected requests")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt break [-status N] <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Inspects the requests held by a tunnel running with -break, one at a time,\n")
+		fmt.Fprintf(os.Stderr, "releasing them to the local server, after editing them in $EDITOR if asked, or\n")
+		fmt.Fprintf(os.Stderr, "rejecting them.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errNameRequired
+	}
+
+	name := tunnelName(fs.Arg(0))
+	c := controlClient(name)
+	in := bufio.NewReader(os.Stdin)
+	fmt.Println(message("break_waiting", fields{"Name": name}))
+	for {
+		resp, err := c.Get("http://lt/breakpoints?wait=30s")
+		if err != nil {
+			return err
+		}
+
+		var held []lt.HeldRequest
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&held)
+		} else {
+			err = fmt.Errorf("%s: %s", name, resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, h := range held {
+			if err := inspect(c, in, h, *status); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// inspect prints a held request and asks what to do with it.
+func inspect(c *http.Client, in *bufio.Reader, h lt.HeldRequest, status int) error {
+	fmt.Println(message("break_request", fields{"ID": h.ID, "Time": h.Time.Local(), "Method": h.Method, "URL": h.URL}))
+	names := make([]string, 0, len(h.Header))
+	for name := range h.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h.Header[name] {
+			fmt.Printf("%s: %s\n", name, v)
+		}
+	}
+	if h.Body != "" {
+		fmt.Printf("\n%s\n", h.Body)
+	}
+
+	for {
+		fmt.Print(message("break_prompt", nil))
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+
+		path := ""
+		var body []byte
+		switch strings.TrimSpace(line) {
+		case "", "r":
+			path = fmt.Sprintf("/breakpoints/release?id=%d", h.ID)
+		case "e":
+			edit, err := editRequest(h)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, message("break_edit_failed", fields{"Err": err}))
+				continue
+			}
+			path = fmt.Sprintf("/breakpoints/release?id=%d", h.ID)
+			body, _ = json.Marshal(edit)
+		case "x":
+			path = fmt.Sprintf("/breakpoints/reject?id=%d&status=%d", h.ID, status)
+		default:
+			continue
+		}
+
+		resp, err := c.Post("http://lt"+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusNoContent:
+		case http.StatusNotFound:
+			fmt.Println(message("break_gone", fields{"ID": h.ID}))
+		default:
+			return fmt.Errorf("#%d: %s", h.ID, resp.Status)
+		}
+		return nil
+	}
+}
+
+// editRequest opens the held request in $EDITOR and returns it as edited.
+func editRequest(h lt.HeldRequest) (*lt.RequestEdit, error) {
+	body := h.Body
+	edit := &lt.RequestEdit{Method: h.Method, URL: h.URL, Header: h.Header, Body: &body}
+	data, err := json.MarshalIndent(edit, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile("", "lt-request-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(append(data, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command(editor, f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	data, err = ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	edit = &lt.RequestEdit{}
+	if err := json.Unmarshal(data, edit); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+	return edit, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+var errSubdomainRequired = errors.New("Missing required argument: subdomain")
+
+func check(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	host := fs.String("h", defaultHost, "Upstream server providing forwarding")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt check [-h HOST] <SUBDOMAIN>\n")
+		fmt.Fprintf(os.Stderr, "Reports whether a subdomain is available without opening a tunnel.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errSubdomainRequired
+	}
+
+	subdomain := tunnelName(fs.Arg(0))
+	available, err := lt.NewClient(*host).SubdomainAvailable(context.Background(), subdomain)
+	if err != nil {
+		return err
+	}
+
+	if !available {
+		return errors.New(message("taken", fields{"Subdomain": subdomain}))
+	}
+
+	fmt.Println(message("available", fields{"Subdomain": subdomain}))
+	return nil
+}
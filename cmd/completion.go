@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The completion scripts ask lt __complete for the candidates of the next word,
+// given the words before it, so the names of the tunnels are those of the config
+// file and of the running tunnels at the time.
+
+const bashCompletion = `_lt() {
+	local IFS=$'\n'
+	COMPREPLY=($(compgen -W "$(lt __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}")" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -o default -F _lt lt
+`
+
+const zshCompletion = `#compdef lt
+
+_lt() {
+	local -a candidates
+	candidates=(${(f)"$(lt __complete "${(@)words[2,CURRENT-1]}")"})
+	if (( ${#candidates} )); then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+
+if [ "$funcstack[1]" = "_lt" ]; then
+	_lt "$@"
+else
+	compdef _lt lt
+fi
+`
+
+const fishCompletion = `function __lt_complete
+	lt __complete (commandline -opc)[2..-1]
+end
+
+complete -c lt -n '__fish_use_subcommand' -f -a '(__lt_complete)'
+complete -c lt -n 'not __fish_use_subcommand' -a '(__lt_complete)'
+`
+
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// runningCommands are the commands taking the name of a running tunnel.
+var runningCommands = map[string]bool{
+	"break":    true,
+	"har":      true,
+	"pprof":    true,
+	"requests": true,
+	"stop":     true,
+	"traffic":  true,
+	"visitors": true,
+}
+
+// __complete is left out of the commands literal, which completions reads.
+func init() {
+	commands["__complete"] = complete
+}
+
+func completion(args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt completion bash|zsh|fish\n")
+		fmt.Fprintf(os.Stderr, "Prints the completion script of the shell, completing the commands and the names of the tunnels.\n\n")
+	}
+	fs.Parse(args)
+
+	script, ok := completionScripts[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		return errors.New("Missing or unknown shell")
+	}
+
+	fmt.Print(script)
+	return nil
+}
+
+// complete prints the candidates of the word following args, one per line.
+func complete(args []string) error {
+	for _, c := range completions(args) {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// completions returns the candidates of the word following args, the words after
+// lt on the command line.
+func completions(args []string) []string {
+	if len(args) == 0 {
+		var names []string
+		for name := range commands {
+			if !strings.HasPrefix(name, "__") {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	switch cmd := args[0]; {
+	case cmd == "auth":
+		return []string{"login", "logout"}
+	case cmd == "completion":
+		return sortedKeys(completionScripts)
+	case cmd == "config":
+		return sortedKeys(configCommands)
+	case cmd == "open":
+		// lt open only knows the tunnels of the config file, see findTarget
+		return configuredNames(configFile(args[1:]))
+	case runningCommands[cmd]:
+		return runningNames()
+	}
+	return nil
+}
+
+// configFile returns the config file given by -c in args, or the default one.
+func configFile(args []string) string {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		switch arg = strings.TrimLeft(arg, "-"); {
+		case arg == "c" && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "c="):
+			return arg[len("c="):]
+		}
+	}
+	path, _ := defaultConfigFile()
+	return path
+}
+
+// configuredNames returns the names of the tunnels of the config file at path.
+func configuredNames(path string) []string {
+	c, err := loadConfig(path, "")
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, tg := range c.Tunnels {
+		if tg.setDefaults("") == nil {
+			names = append(names, tg.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runningNames returns the names of the running tunnels.
+func runningNames() []string {
+	tunnels, _ := runningTunnels()
+	var names []string
+	for _, info := range tunnels {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
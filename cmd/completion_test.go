@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+func TestCompletions(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	path := writeConfig(t, `{"tunnels": [{"name": "web", "port": 3000}, {"port": 8080, "subdomain": "ltdemo-api"}]}`)
+
+	cmds := completions(nil)
+	if len(cmds) != len(commands)-1 || cmds[0] != "auth" {
+		t.Fatalf("Unexpected commands. Actual: %v", cmds)
+	}
+
+	for _, c := range []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"open", "-c", path}, []string{"ltdemo-api", "web"}},
+		{[]string{"open", "-c=" + path}, []string{"ltdemo-api", "web"}},
+		{[]string{"completion"}, []string{"bash", "fish", "zsh"}},
+		{[]string{"stop"}, nil},
+		{[]string{"status"}, nil},
+	} {
+		if actual := completions(c.args); !reflect.DeepEqual(actual, c.expected) {
+			t.Fatalf("Unexpected completions of %v. Expected: %v, Actual: %v", c.args, c.expected, actual)
+		}
+	}
+}
+
+func TestOpenCompletesConfiguredTunnels(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	path := writeConfig(t, `{"tunnels": [{"name": "web", "port": 3000}]}`)
+
+	stop, err := serveControl(lt.NewTunnel("127.0.0.1", 8000), "ltdemo-running", nil, nil, false)
+	if err != nil {
+		t.Fatalf("Cannot serve the control socket: %s", err)
+	}
+	defer stop()
+
+	if actual := completions([]string{"open", "-c", path}); !reflect.DeepEqual(actual, []string{"web"}) {
+		t.Fatalf("Unexpected completions of open. Expected: [web], Actual: %v", actual)
+	}
+	if actual := completions([]string{"stop"}); !reflect.DeepEqual(actual, []string{"ltdemo-running"}) {
+		t.Fatalf("Unexpected completions of stop. Expected: [ltdemo-running], Actual: %v", actual)
+	}
+	if _, err := findTarget([]target{{Name: "web"}}, "ltdemo-running"); err == nil {
+		t.Fatal("A running tunnel missing from the config file should not be opened")
+	}
+}
+
+func TestFindTarget(t *testing.T) {
+	targets := []target{{Name: "web", Port: 3000}, {Name: "api", Port: 8080}}
+
+	tg, err := findTarget(targets, "api")
+	if err != nil || tg.Port != 8080 {
+		t.Fatalf("Unexpected target. Expected: api, Actual: %+v (%v)", tg, err)
+	}
+
+	_, err = findTarget(targets, "db")
+	if err == nil || !strings.Contains(err.Error(), "expected one of: api, web") {
+		t.Fatalf("Unexpected error for an unknown tunnel. Actual: %v", err)
+	}
+
+	if _, err = findTarget(nil, "api"); err == nil {
+		t.Fatal("A config file without tunnels should be rejected")
+	}
+}
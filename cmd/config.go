@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// config holds the options read from the file given by -c.
+type config struct {
+	// Host, Token, Local, Port and Subdomain are used for the flags of the same
+	// name not given on the command line.
+	Host      string `json:"host,omitempty"`
+	Token     string `json:"token,omitempty"`
+	Local     string `json:"local,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Subdomain string `json:"subdomain,omitempty"`
+
+	// Tunnels are opened together instead of the one given by the flags above,
+	// unless -p is given.
+	Tunnels []target `json:"tunnels,omitempty"`
+
+	// Profiles are named settings applied over the others by -profile.
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+
+	Rules []lt.Rule      `json:"rules,omitempty"`
+	Mocks []lt.Mock      `json:"mocks,omitempty"`
+	OAuth *oauthSettings `json:"oauth,omitempty"`
+
+	Webhooks []webhookSettings `json:"webhooks,omitempty"`
+
+	// AllowedHosts restricts the Host of the requests. An empty list only allows
+	// the tunnel's hostname.
+	AllowedHosts *[]string `json:"allowed_hosts,omitempty"`
+
+	// Backends are local servers sharing the requests with the one given by -l and -p,
+	// as host:port. StickySessions keeps each visitor on the same server.
+	Backends       []string `json:"backends,omitempty"`
+	StickySessions bool     `json:"sticky_sessions,omitempty"`
+
+	// TrafficStats is the depth of the path prefixes the traffic is accounted by.
+	TrafficStats int `json:"traffic_stats,omitempty"`
+
+	// Supervise is how often the pool of connections is audited and refilled.
+	Supervise duration `json:"supervise,omitempty"`
+
+	// Coalesce batches the small writes of the local server, see lt.WithWriteCoalescing.
+	Coalesce *coalesceSettings `json:"coalesce,omitempty"`
+
+	// IdentityEncoding asks the local server for uncompressed responses, see
+	// lt.WithIdentityEncoding.
+	IdentityEncoding bool `json:"identity_encoding,omitempty"`
+
+	// Ban refuses the requests of the visitors making too many, see lt.WithBanning.
+	Ban *banSettings `json:"ban,omitempty"`
+
+	// Queue takes turns between the requests waiting for the local server, see
+	// lt.WithFairQueueing.
+	Queue *queueSettings `json:"queue,omitempty"`
+
+	// Messages override the templates of the messages printed while the tunnels
+	// run, by name, see defaultMessages.
+	Messages map[string]string `json:"messages,omitempty"`
+
+	// Capture and Playback apply to each tunnel on its own, see tunnelOptions.
+	Capture  *captureSettings  `json:"capture,omitempty"`
+	Playback *playbackSettings `json:"playback,omitempty"`
+}
+
+// A target is a local server exposed by one of the tunnels of the config file.
+type target struct {
+	// Name is shown before the output of the tunnel, its subdomain or port by default.
+	Name      string `json:"name,omitempty"`
+	Local     string `json:"local,omitempty"`
+	Port      int    `json:"port"`
+	Subdomain string `json:"subdomain,omitempty"`
+}
+
+func (t *target) setDefaults(local string) error {
+	if t.Port == 0 {
+		return fmt.Errorf("Missing port of tunnel %q", t.Name)
+	}
+	if t.Local == "" {
+		t.Local = local
+	}
+	if t.Name == "" {
+		t.Name = t.Subdomain
+	}
+	if t.Name == "" {
+		t.Name = strconv.Itoa(t.Port)
+	}
+	return nil
+}
+
+// playbackSettings tells where to find the responses replayed when the local
+// server is down: an exported HAR file, or the requests being captured.
+type playbackSettings struct {
+	HAR     string `json:"har,omitempty"`
+	Capture bool   `json:"capture,omitempty"`
+}
+
+type coalesceSettings struct {
+	Threshold int      `json:"threshold,omitempty"`
+	Interval  duration `json:"interval,omitempty"`
+}
+
+type banSettings struct {
+	Limit    int      `json:"limit"`
+	Window   duration `json:"window,omitempty"`
+	Duration duration `json:"duration,omitempty"`
+}
+
+type queueSettings struct {
+	Limit  int    `json:"limit,omitempty"`
+	Policy string `json:"policy,omitempty"`
+}
+
+var queuePolicies = map[string]lt.QueuePolicy{
+	"fifo":   lt.QueueFIFO,
+	"client": lt.QueueByClient,
+	"path":   lt.QueueByPath,
+}
+
+type captureSettings struct {
+	File          string   `json:"file,omitempty"`
+	MaxAge        duration `json:"max_age,omitempty"`
+	MaxBodySize   *int64   `json:"max_body_size,omitempty"`
+	Limit         *int     `json:"limit,omitempty"`
+	RedactHeaders []string `json:"redact_headers,omitempty"`
+	RedactBody    []string `json:"redact_body,omitempty"`
+	RedactCards   bool     `json:"redact_cards,omitempty"`
+	SpoolAbove    int64    `json:"spool_above,omitempty"`
+	SpoolDir      string   `json:"spool_dir,omitempty"`
+}
+
+type oauthSettings struct {
+	Provider     string   `json:"provider"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Allow        []string `json:"allow,omitempty"`
+}
+
+var oauthProviders = map[string]lt.OAuthProvider{
+	"github": lt.GitHubOAuth,
+	"google": lt.GoogleOAuth,
+}
+
+type webhookSettings struct {
+	Path      string   `json:"path"`
+	Provider  string   `json:"provider"`
+	Secret    string   `json:"secret"`
+	Tolerance duration `json:"tolerance,omitempty"`
+}
+
+var webhookVerifiers = map[string]func(secret string, tolerance time.Duration) lt.WebhookVerifier{
+	"github": func(secret string, _ time.Duration) lt.WebhookVerifier { return lt.GitHubSignature(secret) },
+	"stripe": lt.StripeSignature,
+	"slack":  lt.SlackSignature,
+}
+
+// loadConfig reads the config file at path, with the settings of the named profile
+// applied over the others unless empty.
+func loadConfig(path, profile string) (*config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c config
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	err = d.Decode(&c)
+	if err != nil {
+		return nil, decodeError(path, b, b, err)
+	}
+
+	if profile != "" {
+		raw, ok := c.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("Unknown profile %s in %s", profile, path)
+		}
+
+		// fields present in the profile replace those of the base settings
+		d = json.NewDecoder(bytes.NewReader(raw))
+		d.DisallowUnknownFields()
+		err = d.Decode(&c)
+		if err != nil {
+			return nil, decodeError(path, b, raw, err)
+		}
+	}
+
+	if problems := c.validate(); len(problems) > 0 {
+		return nil, &invalidConfig{path: path, profile: profile, problems: problems}
+	}
+	return &c, nil
+}
+
+// setFlags sets the flags not given on the command line from the config.
+func (c *config) setFlags() {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	for name, v := range map[string]string{"h": c.Host, "token": c.Token, "l": c.Local, "s": c.Subdomain} {
+		if !given[name] && v != "" {
+			flag.Set(name, v)
+		}
+	}
+	if !given["p"] && c.Port != 0 {
+		flag.Set("p", strconv.Itoa(c.Port))
+	}
+}
+
+// options returns the options set by the config shared by all the tunnels.
+func (c *config) options() ([]lt.Option, error) {
+	var opts []lt.Option
+	if len(c.Rules) > 0 {
+		opts = append(opts, lt.WithRules(c.Rules...))
+	}
+
+	if c.AllowedHosts != nil {
+		opts = append(opts, lt.WithAllowedHosts(*c.AllowedHosts...))
+	}
+
+	if len(c.Backends) > 0 {
+		opts = append(opts, lt.WithLocalBackends(c.Backends...))
+	}
+
+	if c.StickySessions {
+		opts = append(opts, lt.WithStickySessions(""))
+	}
+
+	if c.TrafficStats > 0 {
+		opts = append(opts, lt.WithTrafficStats(c.TrafficStats))
+	}
+
+	if c.Supervise > 0 {
+		opts = append(opts, lt.WithPoolSupervisor(time.Duration(c.Supervise)))
+	}
+
+	if c.Coalesce != nil {
+		opts = append(opts, lt.WithWriteCoalescing(c.Coalesce.Threshold, time.Duration(c.Coalesce.Interval)))
+	}
+
+	if c.IdentityEncoding {
+		opts = append(opts, lt.WithIdentityEncoding())
+	}
+
+	if c.Ban != nil {
+		window, ban := time.Duration(c.Ban.Window), time.Duration(c.Ban.Duration)
+		if window == 0 {
+			window = time.Minute
+		}
+		if ban == 0 {
+			ban = time.Hour
+		}
+		opts = append(opts, lt.WithBanning(c.Ban.Limit, window, ban))
+	}
+
+	if c.Queue != nil {
+		policy := lt.QueueByClient
+		if c.Queue.Policy != "" {
+			p, ok := queuePolicies[c.Queue.Policy]
+			if !ok {
+				return nil, fmt.Errorf("Unknown queue policy: %s", c.Queue.Policy)
+			}
+			policy = p
+		}
+		opts = append(opts, lt.WithFairQueueing(c.Queue.Limit, policy))
+	}
+
+	if len(c.Mocks) > 0 {
+		opts = append(opts, lt.WithMocks(c.Mocks...))
+	}
+
+	if c.OAuth != nil {
+		provider, ok := oauthProviders[c.OAuth.Provider]
+		if !ok {
+			return nil, fmt.Errorf("Unknown OAuth provider: %s", c.OAuth.Provider)
+		}
+
+		opts = append(opts, lt.WithOAuth(lt.OAuthConfig{
+			Provider:     provider,
+			ClientID:     c.OAuth.ClientID,
+			ClientSecret: c.OAuth.ClientSecret,
+			Allow:        c.OAuth.Allow,
+		}))
+	}
+
+	if len(c.Webhooks) > 0 {
+		hooks := make([]lt.Webhook, len(c.Webhooks))
+		for i, w := range c.Webhooks {
+			verifier, ok := webhookVerifiers[w.Provider]
+			if !ok {
+				return nil, fmt.Errorf("Unknown webhook provider: %s", w.Provider)
+			}
+			hooks[i] = lt.Webhook{Path: w.Path, Verifier: verifier(w.Secret, time.Duration(w.Tolerance))}
+		}
+		opts = append(opts, lt.WithWebhooks(hooks...))
+	}
+
+	return opts, nil
+}
+
+// tunnelOptions returns the options set by the config for the tunnel of the given
+// name, which has a capture of its own, also returned, so the requests of several
+// tunnels are not mixed up. Their captures are written to the file named after the
+// one given, suffixed with the tunnel's name, when shared is true.
+func (c *config) tunnelOptions(name string, shared bool) ([]lt.Option, *lt.Capture, error) {
+	var opts []lt.Option
+	var capture *lt.Capture
+	if c.Capture != nil {
+		capture = lt.NewCapture()
+		if c.Capture.MaxBodySize != nil {
+			capture.MaxBodySize = *c.Capture.MaxBodySize
+		}
+		retention := lt.Retention{MaxRecords: 100, MaxAge: time.Duration(c.Capture.MaxAge)}
+		if c.Capture.Limit != nil {
+			retention.MaxRecords = *c.Capture.Limit
+		}
+		capture.Store = lt.NewMemoryStore(retention)
+		if file := c.Capture.File; file != "" {
+			if shared {
+				file = tunnelFile(file, name)
+			}
+			store, err := lt.OpenFileStore(file, retention)
+			if err != nil {
+				return nil, nil, err
+			}
+			capture.Store = store
+		}
+		capture.SpoolThreshold = c.Capture.SpoolAbove
+		capture.SpoolDir = c.Capture.SpoolDir
+		capture.RedactHeaders = append(capture.RedactHeaders, c.Capture.RedactHeaders...)
+		for _, expr := range c.Capture.RedactBody {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Invalid redact_body pattern %q: %s", expr, err)
+			}
+			capture.RedactBody = append(capture.RedactBody, re)
+		}
+		if c.Capture.RedactCards {
+			capture.RedactBody = append(capture.RedactBody, lt.CardNumbers)
+		}
+
+		opts = append(opts, lt.WithCapture(capture))
+	}
+
+	if c.Playback != nil {
+		store, err := c.Playback.store(capture)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, lt.WithPlayback(store))
+	}
+
+	return opts, capture, nil
+}
+
+// tunnelFile returns the name of the file of the named tunnel, inserting its name
+// before the extension of file: captures.jsonl becomes captures.api.jsonl.
+func tunnelFile(file, name string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + name + ext
+}
+
+func (p *playbackSettings) store(capture *lt.Capture) (lt.CaptureStore, error) {
+	if p.Capture {
+		if capture == nil {
+			return nil, errors.New("Playback from capture requires the capture section")
+		}
+		return capture.Store, nil
+	}
+
+	f, err := os.Open(p.HAR)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	records, err := lt.ReadHAR(f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid HAR file %s: %s", p.HAR, err)
+	}
+
+	store := lt.NewMemoryStore(lt.Retention{})
+	for i := range records {
+		store.Add(&records[i])
+	}
+	return store, nil
+}
+
+// duration is a time.Duration written as a string such as "1h30m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	*d = duration(v)
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file with the given content and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "lt.json")
+	err := ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{
+  "host": "https://lt.example.com",
+  "port": 8000,
+  "supervise": "1m30s",
+  "tunnels": [{"name": "api", "port": 8080}, {"port": 3000, "subdomain": "webapp"}],
+  "profiles": {"staging": {"port": 9000, "subdomain": "staging"}}
+}`)
+
+	c, err := loadConfig(path, "")
+	if err != nil {
+		t.Fatalf("Cannot load the config: %s", err)
+	}
+	if c.Host != "https://lt.example.com" || c.Port != 8000 || c.Subdomain != "" {
+		t.Fatalf("Unexpected config. Actual: %+v", c)
+	}
+	if time.Duration(c.Supervise) != 90*time.Second {
+		t.Fatalf("Unexpected supervise. Expected: 1m30s, Actual: %s", time.Duration(c.Supervise))
+	}
+	if len(c.Tunnels) != 2 || c.Tunnels[0].Name != "api" || c.Tunnels[1].Subdomain != "webapp" {
+		t.Fatalf("Unexpected tunnels. Actual: %+v", c.Tunnels)
+	}
+
+	c, err = loadConfig(path, "staging")
+	if err != nil {
+		t.Fatalf("Cannot load the config with a profile: %s", err)
+	}
+	if c.Host != "https://lt.example.com" || c.Port != 9000 || c.Subdomain != "staging" {
+		t.Fatalf("Unexpected config with the staging profile. Actual: %+v", c)
+	}
+
+	if _, err = loadConfig(path, "production"); err == nil || !strings.Contains(err.Error(), "Unknown profile production") {
+		t.Fatalf("Unexpected error for an unknown profile. Actual: %v", err)
+	}
+}
+
+func TestLoadInvalidConfig(t *testing.T) {
+	for content, expected := range map[string]string{
+		"{\n  \"port\": \"8000\"\n}":                "port: expected int, found string",
+		"{\n  \"prot\": 8000\n}":                    ":2:3: unknown field \"prot\"",
+		"{\n  \"port\": 8000,\n}":                   ":3:1: invalid character",
+		`{"supervise": "often"}`:                    "invalid duration",
+		`{"subdomain": "No_Way"}`:                   "subdomain: \"No_Way\" is not a valid subdomain",
+		`{"oauth": {"provider": "gitlab"}}`:         "oauth.provider: unknown provider \"gitlab\"",
+		`{"playback": {"capture": true}}`:           "playback.capture: requires the capture section",
+		`{"ban": {"window": "1m"}}`:                 "ban.limit: expected a positive limit, found 0",
+		`{"queue": {"policy": "random"}}`:           "queue.policy: unknown policy \"random\"",
+		`{"messages": {"welcome": "hi"}}`:           "messages.welcome: unknown message",
+		`{"messages": {"url": "{{.URL"}}`:           "messages.url: template: url:1: unclosed action",
+		`{"tunnels": [{"port": 80}, {"port": 80}]}`: "tunnels[1].name: \"80\" is already the name of tunnels[0]",
+	} {
+		_, err := loadConfig(writeConfig(t, content), "")
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Unexpected error for %s. Expected: %s, Actual: %v", content, expected, err)
+		}
+	}
+}
+
+func TestCapturePerTunnel(t *testing.T) {
+	dir := t.TempDir()
+	c := &config{
+		Capture:  &captureSettings{File: filepath.Join(dir, "captures.jsonl")},
+		Playback: &playbackSettings{Capture: true},
+	}
+
+	_, api, err := c.tunnelOptions("api", true)
+	if err != nil {
+		t.Fatalf("Cannot create the options of api: %s", err)
+	}
+	_, web, err := c.tunnelOptions("web", true)
+	if err != nil {
+		t.Fatalf("Cannot create the options of web: %s", err)
+	}
+	if api == nil || web == nil || api == web || api.Store == web.Store {
+		t.Fatal("Each tunnel should have a capture of its own")
+	}
+
+	for _, name := range []string{"captures.api.jsonl", "captures.web.jsonl"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, name)); len(matches) != 1 {
+			t.Fatalf("Unexpected capture files. Expected: %s", name)
+		}
+	}
+}
+
+func TestTunnelFile(t *testing.T) {
+	for file, expected := range map[string]string{
+		"captures.jsonl":      "captures.api.jsonl",
+		"/var/lt/requests":    "/var/lt/requests.api",
+		"dir.d/captures.json": "dir.d/captures.api.json",
+	} {
+		if actual := tunnelFile(file, "api"); actual != expected {
+			t.Fatalf("Unexpected file of %s. Expected: %s, Actual: %s", file, expected, actual)
+		}
+	}
+}
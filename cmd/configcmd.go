@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// configCommands are the subcommands of lt config.
+var configCommands = map[string]func(args []string) error{
+	"init":     configInit,
+	"path":     configPath,
+	"validate": configValidate,
+}
+
+// sampleConfig is written by lt config init.
+const sampleConfig = `{
+  "host": "https://localtunnel.me",
+  "local": "localhost",
+  "profiles": {}
+}
+`
+
+func configCommand(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := configCommands[args[0]]; ok {
+			return cmd(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: lt config init [-f]\n")
+	fmt.Fprintf(os.Stderr, "       lt config path\n")
+	fmt.Fprintf(os.Stderr, "       lt config validate [-c FILE]\n")
+	fmt.Fprintf(os.Stderr, "Manages the config file read when -c is not given.\n\n")
+	return errors.New("Missing or unknown config command")
+}
+
+func configInit(args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ExitOnError)
+	force := fs.Bool("f", false, "Overwrite the existing config file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt config init [-f]\n")
+		fmt.Fprintf(os.Stderr, "Writes a sample config file where lt reads it by default.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	path, err := defaultConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -f to overwrite it", path)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, []byte(sampleConfig), 0600)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message("config_written", fields{"Path": path}))
+	return nil
+}
+
+func configPath(args []string) error {
+	fs := flag.NewFlagSet("config path", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt config path\n")
+		fmt.Fprintf(os.Stderr, "Shows where lt reads its config and keeps its data.\n\n")
+	}
+	fs.Parse(args)
+
+	path, err := defaultConfigFile()
+	if err != nil {
+		return err
+	}
+
+	state, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message("config_paths", fields{"Config": path, "State": state}))
+	return nil
+}
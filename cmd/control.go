@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// Every running lt process serves a control API on its own unix socket, named
+// after the tunnel's subdomain, which the status and stop commands talk to. The
+// socket is renamed when the tunnel rotates to a new subdomain.
+
+var (
+	errNoTunnels    = errors.New("No running tunnels")
+	errNameRequired = errors.New("Missing required argument: name")
+)
+
+type tunnelInfo struct {
+	Name     string                `json:"name"`
+	URL      string                `json:"url"`
+	Local    string                `json:"local"`
+	Stats    lt.Stats              `json:"stats"`
+	Traffic  *lt.TrafficStats      `json:"traffic,omitempty"`
+	Visitors map[string]lt.Visitor `json:"visitors,omitempty"`
+	Error    string                `json:"error,omitempty"` // why the tunnel closed
+}
+
+// controlDir returns the directory of the control sockets: lt under
+// $XDG_RUNTIME_DIR, private to the user, or lt-<uid> under the temporary directory.
+func controlDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "lt")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("lt-%d", os.Getuid()))
+}
+
+func controlSocket(name string) string {
+	return filepath.Join(controlDir(), name+".sock")
+}
+
+// controls holds the control of each tunnel served, by tunnel.
+var controls sync.Map
+
+// A control is the control socket of a tunnel, named after its subdomain.
+type control struct {
+	m    sync.Mutex
+	name string
+}
+
+func (c *control) Name() string {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.name
+}
+
+// rename moves the control socket to the given name.
+func (c *control) rename(name string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if name == c.name {
+		return nil
+	}
+	err := os.Rename(controlSocket(c.name), controlSocket(name))
+	if err == nil {
+		c.name = name
+	}
+	return err
+}
+
+// renameControl renames the control socket of t, if served, after t moved to the
+// subdomain name.
+func renameControl(t *lt.Tunnel, name string) error {
+	if c, ok := controls.Load(t); ok && name != "" {
+		return c.(*control).rename(name)
+	}
+	return nil
+}
+
+// serveControl exposes the tunnel through a control socket named name until the
+// returned function is called. Profiles are served under /debug/pprof/ when
+// profiling.
+func serveControl(t *lt.Tunnel, name string, capture *lt.Capture, breakpoints *lt.Breakpoints, profiling bool) (func(), error) {
+	err := os.MkdirAll(controlDir(), 0700)
+	if err != nil {
+		return nil, err
+	}
+	// another user may have created it first in the shared temporary directory
+	err = checkPrivateDir(controlDir())
+	if err != nil {
+		return nil, err
+	}
+
+	sock := controlSocket(name)
+	if _, err := controlRequest(name, http.MethodGet, "/status", nil); err == nil {
+		return nil, fmt.Errorf("Tunnel %s is already running", name)
+	}
+	os.Remove(sock)
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by its current name
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	c := &control{name: name}
+	controls.Store(t, c)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(tunnelInfo{
+			Name:     c.Name(),
+			URL:      t.URL(),
+			Local:    net.JoinHostPort(t.LocalHost(), fmt.Sprint(t.LocalPort())),
+			Stats:    t.Stats(),
+			Traffic:  t.TrafficStats(),
+			Visitors: t.Visitors(),
+		})
+	})
+	mux.Handle("/metrics", metricsHandler(t, name))
+	mux.HandleFunc("/har", func(w http.ResponseWriter, r *http.Request) {
+		if capture == nil {
+			http.Error(w, "Requests are not captured", http.StatusNotFound)
+			return
+		}
+		capture.WriteHAR(w)
+	})
+	mux.HandleFunc("/requests", func(w http.ResponseWriter, r *http.Request) {
+		if capture == nil {
+			http.Error(w, "Requests are not captured", http.StatusNotFound)
+			return
+		}
+
+		q, err := parseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		records, err := capture.Search(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(records)
+	})
+	mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		go t.Close()
+	})
+	handleBreakpoints(mux, breakpoints)
+	if profiling {
+		handlePprof(mux)
+	}
+
+	go http.Serve(ln, mux)
+	return func() {
+		controls.Delete(t)
+		ln.Close()
+		os.Remove(controlSocket(c.Name()))
+	}, nil
+}
+
+// controlClient returns a client sending its requests to the control socket of the
+// named tunnel.
+func controlClient(name string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				if err := checkPrivateDir(controlDir()); err != nil {
+					return nil, err
+				}
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", controlSocket(name))
+			},
+		},
+	}
+}
+
+// controlRequest sends a request to the control socket of the named tunnel.
+func controlRequest(name, method, path string, v interface{}) (*http.Response, error) {
+	c := controlClient(name)
+	c.Timeout = 5 * time.Second
+
+	req, err := http.NewRequest(method, "http://lt"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return resp, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+
+	if v != nil {
+		err = json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp, err
+}
+
+// tunnelName returns the name of a tunnel given by name or URL.
+func tunnelName(arg string) string {
+	if strings.Contains(arg, "://") {
+		if name, err := lt.SubdomainFromURL(arg); err == nil {
+			return name
+		}
+	}
+	return arg
+}
+
+// runningTunnels returns the tunnels answering on their control sockets.
+func runningTunnels() ([]tunnelInfo, error) {
+	socks, err := filepath.Glob(filepath.Join(controlDir(), "*.sock"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tunnels []tunnelInfo
+	for _, sock := range socks {
+		var info tunnelInfo
+		name := strings.TrimSuffix(filepath.Base(sock), ".sock")
+		_, err := controlRequest(name, http.MethodGet, "/status", &info)
+		if err != nil {
+			// the process owning this socket is gone
+			os.Remove(sock)
+			continue
+		}
+		tunnels = append(tunnels, info)
+	}
+	return tunnels, nil
+}
+
+func status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt status\n")
+		fmt.Fprintf(os.Stderr, "Lists the running tunnels.\n\n")
+	}
+	fs.Parse(args)
+
+	tunnels, err := runningTunnels()
+	if err != nil {
+		return err
+	}
+
+	if len(tunnels) == 0 {
+		return errNoTunnels
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, message("status_header", nil))
+	for _, info := range tunnels {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n", info.Name, info.URL, info.Local,
+			info.Stats.Conns, info.Stats.Queued, bannedVisitors(info.Visitors), info.Stats.BytesIn, info.Stats.BytesOut)
+	}
+	return w.Flush()
+}
+
+func stop(args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt stop [NAME]...\n")
+		fmt.Fprintf(os.Stderr, "Closes the named tunnels, or all running tunnels if no name is given.\n\n")
+	}
+	fs.Parse(args)
+
+	names := fs.Args()
+	if len(names) == 0 {
+		tunnels, err := runningTunnels()
+		if err != nil {
+			return err
+		}
+		if len(tunnels) == 0 {
+			return errNoTunnels
+		}
+		for _, info := range tunnels {
+			names = append(names, info.Name)
+		}
+	}
+
+	var failed bool
+	for _, name := range names {
+		name = tunnelName(name)
+		if _, err := os.Stat(controlSocket(name)); err != nil {
+			fmt.Fprintln(os.Stderr, message("not_running", fields{"Name": name}))
+			failed = true
+			continue
+		}
+
+		_, err := controlRequest(name, http.MethodPost, "/stop", nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, message("stop_failed", fields{"Name": name, "Err": err}))
+			failed = true
+			continue
+		}
+		fmt.Println(message("stopped", fields{"Name": name}))
+	}
+
+	if failed {
+		return errors.New("Some tunnels could not be stopped")
+	}
+	return nil
+}
+
+func traffic(args []string) error {
+	fs := flag.NewFlagSet("traffic", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt traffic <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Shows the requests and bytes of a running tunnel by path, status class, response content type and size, heaviest first.\n\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errNameRequired
+	}
+
+	var info tunnelInfo
+	_, err := controlRequest(tunnelName(fs.Arg(0)), http.MethodGet, "/status", &info)
+	if err != nil {
+		return err
+	}
+
+	if info.Traffic == nil {
+		return errors.New("Traffic stats are not enabled, see traffic_stats in the config file")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tREQUESTS\tIN\tOUT")
+	printTraffic(w, info.Traffic.Paths)
+	fmt.Fprintln(w, "\nSTATUS\tREQUESTS\tIN\tOUT")
+	printTraffic(w, info.Traffic.Statuses)
+	fmt.Fprintln(w, "\nCONTENT TYPE\tREQUESTS\tIN\tOUT")
+	printTraffic(w, info.Traffic.ContentTypes)
+	fmt.Fprintln(w, "\nSIZE\tREQUESTS\tIN\tOUT")
+	printTraffic(w, info.Traffic.Sizes)
+	return w.Flush()
+}
+
+func printTraffic(w io.Writer, traffic map[string]lt.Traffic) {
+	keys := make([]string, 0, len(traffic))
+	for k := range traffic {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := traffic[keys[i]], traffic[keys[j]]
+		if a.BytesIn+a.BytesOut != b.BytesIn+b.BytesOut {
+			return a.BytesIn+a.BytesOut > b.BytesIn+b.BytesOut
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		tr := traffic[k]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", k, tr.Requests, tr.BytesIn, tr.BytesOut)
+	}
+}
+
+func visitors(args []string) error {
+	fs := flag.NewFlagSet("visitors", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt visitors <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Shows the requests of a running tunnel by visitor, busiest first, and the ones banned.\n\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errNameRequired
+	}
+
+	var info tunnelInfo
+	_, err := controlRequest(tunnelName(fs.Arg(0)), http.MethodGet, "/status", &info)
+	if err != nil {
+		return err
+	}
+
+	if info.Visitors == nil {
+		return errors.New("Visitors are not accounted, see ban in the config file")
+	}
+
+	ips := make([]string, 0, len(info.Visitors))
+	for ip := range info.Visitors {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		a, b := info.Visitors[ips[i]], info.Visitors[ips[j]]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return ips[i] < ips[j]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VISITOR\tREQUESTS\tBANNED UNTIL")
+	for _, ip := range ips {
+		v := info.Visitors[ip]
+		until := "-"
+		if v.BannedUntil != nil {
+			until = v.BannedUntil.Local().Format("15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", ip, v.Requests, until)
+	}
+	return w.Flush()
+}
+
+// bannedVisitors counts the visitors currently banned.
+func bannedVisitors(visitors map[string]lt.Visitor) int {
+	n := 0
+	for _, v := range visitors {
+		if v.BannedUntil != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func har(args []string) error {
+	fs := flag.NewFlagSet("har", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt har <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Writes the requests captured by a running tunnel as HAR to the standard output.\n\n")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errNameRequired
+	}
+
+	var har json.RawMessage
+	_, err := controlRequest(tunnelName(fs.Arg(0)), http.MethodGet, "/har", &har)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(har)
+	return err
+}
+
+// parseQuery reads a capture query from the parameters of a /requests request:
+// path, method, status, since and until (RFC 3339), header (Name:Value) and limit.
+func parseQuery(v url.Values) (lt.Query, error) {
+	q := lt.Query{Path: v.Get("path"), Method: v.Get("method")}
+
+	var err error
+	if s := v.Get("status"); s != "" {
+		if q.Status, err = strconv.Atoi(s); err != nil {
+			return q, fmt.Errorf("Invalid status: %s", s)
+		}
+	}
+
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return q, fmt.Errorf("Invalid limit: %s", s)
+		}
+	}
+
+	if s := v.Get("since"); s != "" {
+		if q.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("Invalid since: %s", s)
+		}
+	}
+
+	if s := v.Get("until"); s != "" {
+		if q.Until, err = time.Parse(time.RFC3339, s); err != nil {
+			return q, fmt.Errorf("Invalid until: %s", s)
+		}
+	}
+
+	for _, h := range v["header"] {
+		if q.Header == nil {
+			q.Header = map[string]string{}
+		}
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) == 2 {
+			q.Header[kv[0]] = strings.TrimSpace(kv[1])
+		} else {
+			q.Header[kv[0]] = ""
+		}
+	}
+
+	return q, nil
+}
+
+func requests(args []string) error {
+	fs := flag.NewFlagSet("requests", flag.ExitOnError)
+	path := fs.String("path", "", "Only requests under this path")
+	method := fs.String("method", "", "Only requests with this method")
+	status := fs.Int("status", 0, "Only requests answered with this status")
+	since := fs.Duration("since", 0, "Only requests received within this duration, e.g. 1h")
+	header := fs.String("header", "", "Only requests with this header, as Name or Name:Value")
+	limit := fs.Int("n", 0, "Show at most this number of requests, the most recent ones")
+	curl := fs.Bool("curl", false, "Print the requests as curl commands reproducing them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt requests [OPTION]... <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Lists the requests captured by a running tunnel.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errNameRequired
+	}
+
+	v := url.Values{}
+	if *path != "" {
+		v.Set("path", *path)
+	}
+	if *method != "" {
+		v.Set("method", *method)
+	}
+	if *status != 0 {
+		v.Set("status", strconv.Itoa(*status))
+	}
+	if *since != 0 {
+		v.Set("since", time.Now().Add(-*since).Format(time.RFC3339))
+	}
+	if *header != "" {
+		v.Set("header", *header)
+	}
+	if *limit != 0 {
+		v.Set("limit", strconv.Itoa(*limit))
+	}
+
+	var records []lt.RequestRecord
+	_, err := controlRequest(tunnelName(fs.Arg(0)), http.MethodGet, "/requests?"+v.Encode(), &records)
+	if err != nil {
+		return err
+	}
+
+	if *curl {
+		for _, r := range records {
+			fmt.Println(r.AsCurl())
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tMETHOD\tSTATUS\tDURATION\tURL")
+	for _, r := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", r.ID, r.Time.Format("15:04:05"), r.Method, r.Status,
+			r.Duration.Round(time.Millisecond), r.URL)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivateDir fails unless dir is a directory, not a symlink, owned by the user
+// and only accessible by them, so no other user can serve or read its sockets.
+func checkPrivateDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() || fi.Mode().Perm() != 0700 {
+		return fmt.Errorf("Unsafe control directory %s, it must be yours and only accessible by you", dir)
+	}
+	return nil
+}
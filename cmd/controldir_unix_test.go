@@ -0,0 +1,73 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+func TestCheckPrivateDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "lt")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(dir); err != nil {
+		t.Fatalf("Private directory should be accepted: %s", err)
+	}
+
+	link := filepath.Join(filepath.Dir(dir), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(link); err == nil {
+		t.Fatal("Symlink should be rejected")
+	}
+
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateDir(dir); err == nil {
+		t.Fatal("Directory accessible by others should be rejected")
+	}
+}
+
+func TestControlDirUnderRuntimeDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	if d := controlDir(); d != filepath.Join(dir, "lt") {
+		t.Fatalf("Unexpected control directory. Expected: %s, Actual: %s", filepath.Join(dir, "lt"), d)
+	}
+}
+
+func TestControlRename(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	tunnel := lt.NewTunnel("127.0.0.1", 8000)
+	stop, err := serveControl(tunnel, "sub0", nil, nil, false)
+	if err != nil {
+		t.Fatalf("Cannot serve the control socket: %s", err)
+	}
+
+	if err := renameControl(tunnel, "sub1"); err != nil {
+		t.Fatalf("Cannot rename the control socket: %s", err)
+	}
+	if _, err := controlRequest("sub0", http.MethodGet, "/status", nil); err == nil {
+		t.Fatal("The control socket should not answer by its old name")
+	}
+	var info tunnelInfo
+	if _, err := controlRequest("sub1", http.MethodGet, "/status", &info); err != nil || info.Name != "sub1" {
+		t.Fatalf("Unexpected status by the new name. Expected: sub1, Actual: %q (%v)", info.Name, err)
+	}
+
+	stop()
+	if _, err := os.Stat(controlSocket("sub1")); !os.IsNotExist(err) {
+		t.Fatalf("The control socket should be removed once stopped: %v", err)
+	}
+}
@@ -0,0 +1,6 @@
+package main
+
+// checkPrivateDir does nothing on Windows, whose temporary directory is per user.
+func checkPrivateDir(dir string) error {
+	return nil
+}
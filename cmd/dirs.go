@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// configDir returns where lt looks for its config: $XDG_CONFIG_HOME/localtunnel on
+// Unix, ~/Library/Application Support/localtunnel on macOS and
+// %AppData%\localtunnel on Windows.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "localtunnel"), nil
+}
+
+// defaultConfigFile returns the config file read when -c is not given.
+func defaultConfigFile() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// stateDir returns where lt keeps its data: $XDG_STATE_HOME/localtunnel on Unix,
+// ~/Library/Application Support/localtunnel on macOS and %LocalAppData%\localtunnel
+// on Windows.
+func stateDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "localtunnel"), nil
+		}
+	case "darwin", "ios", "plan9":
+		return configDir()
+	default:
+		if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, "localtunnel"), nil
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "localtunnel"), nil
+}
+
+// openState returns the store keeping the state of lt across restarts, such as
+// the remembered subdomains and the ACME account and certificates, in stateDir.
+func openState() (lt.Store, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	return lt.NewDirStore(dir), nil
+}
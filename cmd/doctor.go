@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+func doctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	host := fs.String("h", defaultHost, "Upstream server providing forwarding")
+	local := fs.String("l", "localhost", "Tunnel traffic to this host instead of localhost")
+	port := fs.Int("p", 0, "Internal http server port")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt doctor -p <PORT> [-h HOST] [-l HOST]\n")
+		fmt.Fprintf(os.Stderr, "Opens a tunnel and tests every hop, from the local server to a request going through the tunnel.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if *port == 0 {
+		fs.Usage()
+		return errPortRequired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	t := lt.NewClient(*host).NewTunnel(*local, *port)
+	checks := t.Diagnose(ctx)
+
+	var failed bool
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range checks {
+		switch {
+		case c.Skipped:
+			fmt.Fprintln(w, message("doctor_skipped", fields{"Check": c.Name}))
+		case c.Err != nil:
+			failed = true
+			fmt.Fprintln(w, message("doctor_failed", fields{"Check": c.Name, "Err": c.Err}))
+		default:
+			fmt.Fprintln(w, message("doctor_ok", fields{"Check": c.Name, "Duration": c.Duration.Round(time.Millisecond)}))
+		}
+	}
+	w.Flush()
+
+	if failed {
+		return errors.New("Some checks failed")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// devPorts are the ports development servers listen on by default.
+var devPorts = []int{3000, 3001, 4000, 4200, 5000, 5173, 8000, 8080, 8081, 8888, 9000}
+
+var errNoLocalServer = errors.New("No local server found on the common development ports")
+
+// scanPorts returns the ports of host accepting connections, in the given order.
+func scanPorts(host string, ports []int) []int {
+	open := make([]bool, len(ports))
+
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			c, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), 300*time.Millisecond)
+			if err == nil {
+				c.Close()
+				open[i] = true
+			}
+		}(i, port)
+	}
+	wg.Wait()
+
+	var found []int
+	for i, port := range ports {
+		if open[i] {
+			found = append(found, port)
+		}
+	}
+	return found
+}
+
+// guessPort looks for a local server on the common development ports and asks which
+// one to tunnel, or picks the first one when yes is set.
+func guessPort(host string, yes bool) (int, error) {
+	found := scanPorts(host, devPorts)
+	if len(found) == 0 {
+		return 0, errNoLocalServer
+	}
+
+	if yes {
+		fmt.Println(message("guess_picked", fields{"Port": found[0]}))
+		return found[0], nil
+	}
+
+	fmt.Println(message("guess_found", nil))
+	for i, port := range found {
+		fmt.Println(message("guess_choice", fields{"N": i + 1, "Addr": net.JoinHostPort(host, strconv.Itoa(port))}))
+	}
+	fmt.Print(message("guess_prompt", nil))
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return 0, err
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return found[0], nil
+	}
+
+	i, err := strconv.Atoi(line)
+	if err != nil || i < 1 || i > len(found) {
+		return 0, fmt.Errorf("Invalid choice: %s", line)
+	}
+	return found[i-1], nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Tokens are kept in the OS keyring under this service, one per server.
+const keyringService = "localtunnel"
+
+var errNoToken = errors.New("No token stored for this server")
+
+// authCommands are the subcommands of lt auth.
+var authCommands = map[string]func(args []string) error{
+	"login":  authLogin,
+	"logout": authLogout,
+}
+
+func auth(args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := authCommands[args[0]]; ok {
+			return cmd(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: lt auth login [-h HOST]\n")
+	fmt.Fprintf(os.Stderr, "       lt auth logout [-h HOST]\n")
+	fmt.Fprintf(os.Stderr, "Manages the tokens kept in the OS keyring.\n\n")
+	return errors.New("Missing or unknown auth command")
+}
+
+func authLogin(args []string) error {
+	fs := flag.NewFlagSet("auth login", flag.ExitOnError)
+	host := fs.String("h", defaultHost, "Server the token authenticates with")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt auth login [-h HOST]\n")
+		fmt.Fprintf(os.Stderr, "Reads a token from the standard input and keeps it in the OS keyring.\n")
+		fmt.Fprintf(os.Stderr, "lt then uses it for this server unless -token is given.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	fmt.Fprint(os.Stderr, message("token_prompt", fields{"Host": *host}))
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return err
+	}
+
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return errors.New("Missing token")
+	}
+
+	err = keyringSet(*host, token)
+	if err != nil {
+		return fmt.Errorf("Cannot store the token: %s", err)
+	}
+
+	fmt.Println(message("token_stored", fields{"Host": *host}))
+	return nil
+}
+
+func authLogout(args []string) error {
+	fs := flag.NewFlagSet("auth logout", flag.ExitOnError)
+	host := fs.String("h", defaultHost, "Server the token authenticates with")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt auth logout [-h HOST]\n")
+		fmt.Fprintf(os.Stderr, "Removes the token of a server from the OS keyring.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	err := keyringDelete(*host)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message("token_removed", fields{"Host": *host}))
+	return nil
+}
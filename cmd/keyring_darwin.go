@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// The macOS Keychain is used through the security command.
+
+func keyringGet(host string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", host, "-w").Output()
+	if err != nil {
+		return "", errNoToken
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keyringSet gives the token on the standard input of security, which prompts for it
+// twice when -w comes last, as any user can read the arguments of a process.
+func keyringSet(host, token string) error {
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", host, "-w")
+	cmd.Stdin = strings.NewReader(token + "\n" + token + "\n")
+	return cmd.Run()
+}
+
+func keyringDelete(host string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", host).Run()
+	if err != nil {
+		return errNoToken
+	}
+	return nil
+}
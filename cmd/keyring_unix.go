@@ -0,0 +1,34 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// The Secret Service (GNOME Keyring, KWallet, etc.) is used through the secret-tool
+// command, from libsecret.
+
+func keyringGet(host string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keyringService, "account", host).Output()
+	token := strings.TrimSpace(string(out))
+	if err != nil || token == "" {
+		return "", errNoToken
+	}
+	return token, nil
+}
+
+func keyringSet(host, token string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=localtunnel "+host, "service", keyringService, "account", host)
+	cmd.Stdin = strings.NewReader(token)
+	return cmd.Run()
+}
+
+func keyringDelete(host string) error {
+	if _, err := keyringGet(host); err != nil {
+		return err
+	}
+	return exec.Command("secret-tool", "clear", "service", keyringService, "account", host).Run()
+}
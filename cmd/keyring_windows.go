@@ -0,0 +1,101 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// The Windows Credential Manager is used through advapi32.dll.
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential is a CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func credTarget(host string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(keyringService + ":" + host)
+}
+
+func keyringGet(host string) (string, error) {
+	target, err := credTarget(host)
+	if err != nil {
+		return "", err
+	}
+
+	var c *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&c)))
+	if r == 0 {
+		if err == errorNotFound {
+			return "", errNoToken
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(c)))
+
+	return string(unsafe.Slice(c.CredentialBlob, c.CredentialBlobSize)), nil
+}
+
+func keyringSet(host, token string) error {
+	target, err := credTarget(host)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(token)
+	c := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		c.CredentialBlob = &blob[0]
+	}
+
+	r, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&c)), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func keyringDelete(host string) error {
+	target, err := credTarget(host)
+	if err != nil {
+		return err
+	}
+
+	r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 {
+		if err == errorNotFound {
+			return errNoToken
+		}
+		return err
+	}
+	return nil
+}
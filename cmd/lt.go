@@ -1,33 +1,109 @@
 package main
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	lt "github.com/jweslley/localtunnel"
 )
 
+const defaultHost = "https://localtunnel.me"
+
+// commands are the subcommands accepted as the first argument.
+var commands = map[string]func(args []string) error{
+	"auth":       auth,
+	"break":      breakCommand,
+	"check":      check,
+	"completion": completion,
+	"config":     configCommand,
+	"doctor":     doctor,
+	"open":       open,
+	"status":     status,
+	"stop":       stop,
+	"test":       smokeTest,
+	"har":        har,
+	"pprof":      pprofCommand,
+	"requests":   requests,
+	"traffic":    traffic,
+	"update":     update,
+	"visitors":   visitors,
+	"version":    version,
+}
+
 var (
 	errPortRequired = errors.New("Missing required argument: port")
 
-	host      = flag.String("h", "https://localtunnel.me", "Upstream server providing forwarding")
+	host      = flag.String("h", defaultHost, "Upstream server providing forwarding, or several separated by commas to use the first answering")
 	local     = flag.String("l", "localhost", "Tunnel traffic to this host instead of localhost")
 	subdomain = flag.String("s", "", "Request this subdomain")
 	port      = flag.Int("p", 0, "Internal http server port")
+	guess     = flag.Bool("guess", false, "Look for a local server on the common development ports when -p is not given")
+	yes       = flag.Bool("yes", false, "Tunnel the first local server found by -guess without asking")
+	conf      = flag.String("c", "", "Read options from this JSON config file, instead of the one shown by lt config path")
+	profile   = flag.String("profile", "", "Use this profile of the config file")
+	token     = flag.String("token", "", "Authenticate with this token on servers requiring it, instead of the one stored by lt auth login")
+	proto     = flag.String("proto", "tcp", "Protocol of the local server: tcp or udp")
+	sshTarget = flag.String("ssh", "", "Fall back to a reverse SSH forward on this [user@]host when the server is unreachable")
+	sshKey    = flag.String("ssh-key", "", "Identity file used by the SSH fallback")
+	sshPort   = flag.Int("ssh-port", 8080, "Port exposed on the SSH host by the fallback")
+	metrics   = flag.String("metrics", "", "Serve Prometheus metrics on this address, e.g. :9100")
+	profiling = flag.Bool("pprof", false, "Serve pprof profiles on the control socket and the -metrics address")
+	only      = flag.String("only", "", "Only show the output of these tunnels of the config file, e.g. api,web")
+	share     = flag.Duration("share", 0, "Only allow access through a share link valid for this long, e.g. 2h")
+	countries = flag.String("allow-country", "", "Only allow the visitors of these countries, e.g. BR,US, looked up in the -geoip database")
+	geoip     = flag.String("geoip", "", "MaxMind DB file mapping IP addresses to countries, e.g. GeoLite2-Country.mmdb")
+	tlsCert   = flag.String("tls-cert", "", "Terminate TLS with this certificate file, for servers passing it through")
+	tlsKey    = flag.String("tls-key", "", "Private key file of the -tls-cert certificate")
+	acme      = flag.String("acme", "", "Terminate TLS with a certificate issued by Let's Encrypt for these domains, e.g. example.com,www.example.com")
+	acmeEmail = flag.String("acme-email", "", "Contact of the Let's Encrypt account used by -acme")
+	statsOut  = flag.String("stats-out", "", "Write the stats of the tunnels to this JSON file once they are closed")
+	window    = flag.Duration("window", 0, "Only allow access for this long, refusing requests afterwards, e.g. 2h")
+	breakAt   = flag.String("break", "", "Hold the requests under these paths until released by lt break, e.g. /hooks,/api")
+	proxies   = flag.Int("trusted-proxies", 0, "Proxies in front of the server's relay, whose X-Forwarded-For entries are skipped to find the visitor")
+	keep      = flag.Bool("keep-subdomain", false, "Request the subdomain of the previous run again, remembered by tunnel name in the state directory")
+	rotate    = flag.Duration("rotate", 0, "Move the tunnels to a new random subdomain this often, for short-lived URLs, e.g. 15m")
+	crashDir  = flag.String("crash-dir", "", "Write the panics recovered by the tunnels to crash dump files in this directory")
 )
 
 func fail(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, message("error", fields{"Err": err}))
 		os.Exit(1)
 	}
 }
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage: lt -p <PORT> [OPTION]...\n")
+	fmt.Fprintf(os.Stderr, "       lt -guess [-yes] [OPTION]...\n")
+	fmt.Fprintf(os.Stderr, "       lt auth login|logout [-h HOST]\n")
+	fmt.Fprintf(os.Stderr, "       lt break [-status N] <NAME>\n")
+	fmt.Fprintf(os.Stderr, "       lt check [-h HOST] <SUBDOMAIN>\n")
+	fmt.Fprintf(os.Stderr, "       lt completion bash|zsh|fish\n")
+	fmt.Fprintf(os.Stderr, "       lt config init [-f]\n")
+	fmt.Fprintf(os.Stderr, "       lt config path\n")
+	fmt.Fprintf(os.Stderr, "       lt config validate [-c FILE]\n")
+	fmt.Fprintf(os.Stderr, "       lt doctor -p <PORT> [-h HOST] [-l HOST]\n")
+	fmt.Fprintf(os.Stderr, "       lt open [OPTION]... <NAME>\n")
+	fmt.Fprintf(os.Stderr, "       lt status\n")
+	fmt.Fprintf(os.Stderr, "       lt stop [NAME]...\n")
+	fmt.Fprintf(os.Stderr, "       lt har <NAME>\n")
+	fmt.Fprintf(os.Stderr, "       lt pprof [-seconds N] [-o FILE] <NAME> <PROFILE>\n")
+	fmt.Fprintf(os.Stderr, "       lt requests [OPTION]... <NAME>\n")
+	fmt.Fprintf(os.Stderr, "       lt test -p <PORT> [-h HOST] [-l HOST] [-s SUBDOMAIN] [-path PATH]\n")
+	fmt.Fprintf(os.Stderr, "       lt traffic <NAME>\n")
+	fmt.Fprintf(os.Stderr, "       lt update [-check] [-f]\n")
+	fmt.Fprintf(os.Stderr, "       lt version [-json]\n")
+	fmt.Fprintf(os.Stderr, "       lt visitors <NAME>\n")
 	fmt.Fprintf(os.Stderr, "localtunnel exposes your localhost to the world for easy testing and sharing!\n\n")
 	fmt.Fprintf(os.Stderr, "Options:\n")
 	flag.PrintDefaults()
@@ -35,34 +111,361 @@ func usage() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if !strings.HasPrefix(os.Args[1], "__") {
+				setCommandMessages()
+			}
+			fail(cmd(os.Args[2:]))
+			return
+		}
+	}
+
 	flag.Usage = usage
 	flag.Parse()
+	run()
+}
+
+// run opens the tunnels given by the flags and the config file, or the one named
+// by opening, until they are closed.
+func run() {
+	if *conf == "" {
+		if path, err := defaultConfigFile(); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				*conf = path
+			}
+		}
+	}
+
+	cfg := &config{}
+	if *conf != "" {
+		var err error
+		cfg, err = loadConfig(*conf, *profile)
+		fail(err)
+		cfg.setFlags()
+	} else if *profile != "" {
+		fail(errors.New("Profiles require a config file, given by -c or created by lt config init"))
+	}
 
-	if *port == 0 {
+	fail(setMessages(cfg.Messages))
+
+	if *guess && *port == 0 {
+		p, err := guessPort(*local, *yes)
+		fail(err)
+		*port = p
+	}
+
+	targets := []target{{Local: *local, Port: *port, Subdomain: *subdomain}}
+	if opening != "" || len(cfg.Tunnels) > 0 && !flagGiven("p") {
+		targets = cfg.Tunnels
+	} else if *port == 0 {
 		usage()
 		fail(errPortRequired)
 	}
 
+	names := make([]string, len(targets))
+	for i := range targets {
+		fail(targets[i].setDefaults(*local))
+		names[i] = targets[i].Name
+	}
+
+	if opening != "" {
+		tg, err := findTarget(targets, opening)
+		fail(err)
+		if url := runningURL(tg); url != "" {
+			fmt.Println(message("already_open", fields{"Name": tg.Name, "URL": url}))
+			return
+		}
+		targets, names = []target{tg}, []string{tg.Name}
+	}
+
+	if *metrics != "" && len(targets) > 1 {
+		fail(errors.New("-metrics requires a single tunnel, scrape the /metrics of the control sockets instead"))
+	}
+
+	opts, err := cfg.options()
+	fail(err)
+
+	if *proxies > 0 {
+		opts = append(opts, lt.WithTrustedProxies(*proxies))
+	}
+
+	// the state of lt, kept across restarts
+	var state lt.Store
+	if *keep || *acme != "" {
+		state, err = openState()
+		fail(err)
+	}
+
+	var subdomains lt.Store
+	if *keep {
+		if *rotate > 0 {
+			fail(errors.New("-keep-subdomain and -rotate cannot be used together"))
+		}
+		subdomains = state
+	}
+
+	if *rotate > 0 {
+		opts = append(opts, lt.WithRotation(*rotate))
+	}
+
+	if *crashDir != "" {
+		opts = append(opts, lt.WithCrashDumps(*crashDir))
+	}
+
+	if *share > 0 {
+		opts = append(opts, lt.WithSignedAccess(nil))
+	}
+
+	if *tlsCert != "" || *tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		fail(err)
+		opts = append(opts, lt.WithTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	}
+
+	if *acme != "" {
+		if *tlsCert != "" {
+			fail(errors.New("-acme and -tls-cert cannot be used together"))
+		}
+		opts = append(opts, lt.WithACME(&lt.ACME{
+			Email:   *acmeEmail,
+			Domains: strings.Split(*acme, ","),
+			Cache:   state,
+		}))
+	}
+
+	if *countries != "" {
+		if *geoip == "" {
+			fail(errors.New("-allow-country requires a country database given by -geoip"))
+		}
+		db, err := lt.OpenGeoIP(*geoip)
+		fail(err)
+		opts = append(opts, lt.WithAllowedCountries(db, strings.Split(*countries, ",")...))
+	}
+
+	if *token == "" {
+		*token, _ = keyringGet(*host)
+	}
+
+	started := time.Now()
 	c := lt.NewClient(*host)
-	t := c.NewTunnel(*local, *port)
+	if hosts := strings.Split(*host, ","); len(hosts) > 1 {
+		c = lt.NewRaceClient(hosts...)
+	}
+	c = c.WithToken(*token)
+	outs := newOutputs(names, *only)
+	tunnels := make([]*lt.Tunnel, len(targets))
+	captures := make([]*lt.Capture, len(targets))
+	breakpoints := make([]*lt.Breakpoints, len(targets))
+	for i, tg := range targets {
+		own, capture, err := cfg.tunnelOptions(tg.Name, len(targets) > 1)
+		fail(err)
+		captures[i] = capture
+		tunnelOpts := append(opts[:len(opts):len(opts)], own...)
+		if subdomains != nil {
+			tunnelOpts = append(tunnelOpts, lt.WithSubdomainStore(subdomains, tg.Name))
+		}
+		if *breakAt != "" {
+			breakpoints[i] = lt.NewBreakpoints(strings.Split(*breakAt, ",")...)
+			tunnelOpts = append(tunnelOpts, lt.WithBreakpoints(breakpoints[i]))
+		}
+		tunnels[i] = newTunnel(c, tg, tunnelOpts, outs[i])
+		if *window > 0 {
+			fail(tunnels[i].TemporaryAccess(*window))
+		}
+	}
 
-	if *subdomain == "" {
-		fail(t.Open())
+	if len(tunnels) == 1 {
+		t := tunnels[0]
+		outs[0].Progress("registering", fields{"Host": *host})
+		if targets[0].Subdomain == "" {
+			err = t.Open()
+		} else {
+			err = t.OpenAs(targets[0].Subdomain)
+		}
+		if err != nil {
+			outs[0].EndProgress()
+		}
+
+		if err != nil && *sshTarget != "" && *proto == "tcp" && unreachable(err) {
+			fmt.Fprintln(os.Stderr, message("ssh_fallback", fields{"Err": err, "Target": *sshTarget}))
+			fail(sshFallback(*sshTarget, *sshKey, *sshPort))
+			return
+		}
 	} else {
-		fail(t.OpenAs(*subdomain))
+		m := lt.NewManager()
+		for i, t := range tunnels {
+			m.Add(t, targets[i].Subdomain)
+		}
+		err = m.Open()
+		if err != nil {
+			m.Close()
+		}
 	}
+	fail(err)
+
+	var stops []func()
+	urls := make([]string, len(tunnels))
+	for i, t := range tunnels {
+		out := outs[i]
+		urls[i] = t.URL()
+		out.Print("url", fields{"URL": urls[i]})
+
+		if *share > 0 {
+			u, err := t.ShareURL(*share)
+			fail(err)
+			out.Print("share", fields{"TTL": *share, "URL": u})
+		}
+
+		if *window > 0 {
+			until := t.AccessUntil()
+			out.Print("window_open", fields{"Until": until})
+			time.AfterFunc(time.Until(until), func() {
+				out.Print("window_closed", nil)
+			})
+		}
 
-	fmt.Printf("your url is: %s\n", t.URL())
+		stop, err := serveControl(t, t.Subdomain(), captures[i], breakpoints[i], *profiling)
+		if err != nil {
+			out.Error("control_unavailable", fields{"Err": err})
+		} else {
+			stops = append(stops, stop)
+		}
+	}
+
+	if *metrics != "" {
+		t := tunnels[0]
+		ln, err := net.Listen("tcp", *metrics)
+		fail(err)
+		defer ln.Close()
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler(t, t.Subdomain()))
+		if *profiling {
+			handlePprof(mux)
+		}
+		go http.Serve(ln, mux)
+	}
 
+	// SIGTERM is sent by systemd, docker stop and kill, which must still get the
+	// tunnels closed and their stats written
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		for s := range sig {
-			fmt.Printf("%v received\n", s)
-			t.Close()
+			fmt.Println(message("signal", fields{"Signal": s}))
+			for _, t := range tunnels {
+				go t.Close()
+			}
+		}
+	}()
+
+	failed := false
+	snapshot := &statsSnapshot{Started: started}
+	for i, t := range tunnels {
+		<-t.Done()
+		info := tunnelInfo{
+			Name:     targets[i].Name,
+			URL:      urls[i],
+			Local:    net.JoinHostPort(t.LocalHost(), strconv.Itoa(t.LocalPort())),
+			Stats:    t.Stats(),
+			Traffic:  t.TrafficStats(),
+			Visitors: t.Visitors(),
+		}
+		if err := t.Err(); err != lt.ErrClosed {
+			outs[i].Error("error", fields{"Err": err})
+			info.Error = err.Error()
+			failed = true
+		}
+		snapshot.Tunnels = append(snapshot.Tunnels, info)
+	}
+	for _, stop := range stops {
+		stop()
+	}
+
+	if *statsOut != "" {
+		snapshot.Stopped = time.Now()
+		if err := writeStats(*statsOut, snapshot); err != nil {
+			fmt.Fprintln(os.Stderr, message("stats_failed", fields{"Err": err}))
+			failed = true
+		}
+	}
+
+	fmt.Println(message("bye", fields{"Tunnels": len(tunnels)}))
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// newTunnel creates the tunnel of tg, reporting its events to out.
+func newTunnel(c *lt.Client, tg target, opts []lt.Option, out *output) *lt.Tunnel {
+	events := make(chan lt.Event, 16)
+	opts = append(append([]lt.Option(nil), opts...), lt.WithEvents(events))
+
+	// the events are only emitted once t is opened
+	var t *lt.Tunnel
+	go func() {
+		for e := range events {
+			switch e.Type {
+			case lt.EventRegistered:
+				out.Progress("connecting", fields{"Conns": 0, "Target": e.Target})
+			case lt.EventConnected:
+				if e.Conns < e.Target {
+					out.Progress("connecting", fields{"Conns": e.Conns, "Target": e.Target})
+				} else {
+					out.EndProgress()
+					out.Print("connected", fields{"Target": e.Target})
+				}
+			case lt.EventRateLimited:
+				out.Error("rate_limited", fields{"Retry": e.Retry})
+			case lt.EventPoolDegraded:
+				out.Error("pool_degraded", fields{"Conns": e.Conns, "Target": e.Target})
+			case lt.EventRotated:
+				if e.Err != nil {
+					out.Error("rotate_failed", fields{"Err": e.Err})
+				} else {
+					out.Print("rotated", fields{"URL": e.URL})
+					if err := renameControl(t, t.Subdomain()); err != nil {
+						out.Error("control_unavailable", fields{"Err": err})
+					}
+				}
+			case lt.EventBanned:
+				out.Error("banned", fields{"Client": e.Client, "Retry": e.Retry})
+			case lt.EventPanic:
+				var pe *lt.PanicError
+				file := ""
+				if errors.As(e.Err, &pe) {
+					file = pe.File
+				}
+				out.Error("panic", fields{"Err": e.Err, "File": file})
+			case lt.EventWebhook:
+				if e.Err != nil {
+					out.Error("webhook_rejected", fields{"Path": e.Path, "Err": e.Err})
+				} else {
+					out.Print("webhook_verified", fields{"Path": e.Path})
+				}
+			}
 		}
 	}()
 
-	<-t.Closing()
-	fmt.Println("Bye! tunnel closed")
+	switch *proto {
+	case "tcp":
+		t = c.NewTunnel(tg.Local, tg.Port, opts...)
+	case "udp":
+		t = c.NewUDPTunnel(tg.Local, tg.Port, opts...)
+	default:
+		fail(fmt.Errorf("Unknown protocol: %s", *proto))
+	}
+	return t
+}
+
+// flagGiven reports whether the named flag was given on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			given = true
+		}
+	})
+	return given
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// The messages printed by lt, while the tunnels run or by its commands, are
+// text/template templates, so teams can brand or translate them. The defaults are
+// overridden by the messages file of the user's locale in the config directory, then
+// by the messages of the config file.
+
+// defaultMessages are the templates of the messages, by name. Every message is
+// given the Name of its tunnel, empty outside of one, besides the fields used here.
+var defaultMessages = map[string]string{
+	"already_open":        "{{.Name}} is already open: {{.URL}}",
+	"available":           "{{.Subdomain}} is available",
+	"banned":              "visitor {{.Client}} banned for {{.Retry}}",
+	"break_edit_failed":   "Cannot edit the request: {{.Err}}",
+	"break_gone":          "#{{.ID}} is no longer held, it timed out or its visitor left",
+	"break_prompt":        "[r]elease, [e]dit and release, [x] reject? [r] ",
+	"break_request":       "\n#{{.ID}} {{.Time.Format \"15:04:05\"}} {{.Method}} {{.URL}}",
+	"break_waiting":       "waiting for requests held by {{.Name}}, ^C to quit",
+	"bye":                 "Bye! {{if eq .Tunnels 1}}tunnel{{else}}tunnels{{end}} closed",
+	"config_paths":        "config: {{.Config}}\nstate:  {{.State}}",
+	"config_valid":        "{{.Path}} is valid",
+	"config_written":      "config written to {{.Path}}",
+	"connected":           "connected to the server with {{.Target}} connections",
+	"connecting":          "registered, connecting to the server {{.Conns}}/{{.Target}}",
+	"control_unavailable": "Control socket unavailable: {{.Err}}",
+	"doctor_failed":       "{{.Check}}\tFAIL\t{{.Err}}",
+	"doctor_ok":           "{{.Check}}\tok\t{{.Duration}}",
+	"doctor_skipped":      "{{.Check}}\tskipped\t",
+	"error":               "{{.Err}}",
+	"guess_choice":        "  {{.N}}) {{.Addr}}",
+	"guess_found":         "local servers found:",
+	"guess_picked":        "tunneling port {{.Port}}",
+	"guess_prompt":        "which one to tunnel? [1] ",
+	"not_running":         "{{.Name}} is not running",
+	"panic":               "recovered from {{.Err}}{{if .File}}, crash dump written to {{.File}}{{end}}",
+	"pool_degraded":       "only {{.Conns}} of {{.Target}} connections to the server are up",
+	"profile_written":     "profile written to {{.Path}}, see go tool pprof {{.Path}}",
+	"rate_limited":        "rate limited by the server, retrying in {{.Retry}}",
+	"registering":         "registering with {{.Host}}",
+	"rotate_failed":       "cannot rotate the subdomain: {{.Err}}",
+	"rotated":             "your url is now: {{.URL}}",
+	"share":               "share link, valid for {{.TTL}}: {{.URL}}",
+	"signal":              "{{.Signal}} received",
+	"ssh_fallback":        "{{.Err}}\nfalling back to ssh {{.Target}}",
+	"stats_failed":        "Cannot write stats: {{.Err}}",
+	"status_header":       "NAME\tURL\tLOCAL\tCONNS\tQUEUED\tBANNED\tIN\tOUT",
+	"stop_failed":         "Cannot stop {{.Name}}: {{.Err}}",
+	"stopped":             "{{.Name}} stopped",
+	"taken":               "{{.Subdomain}} is taken",
+	"test_difference":     "  {{.Difference}}",
+	"test_failed":         "FAIL",
+	"test_opened":         "tunnel opened at {{.URL}}",
+	"test_passed":         "PASS",
+	"test_response":       "{{.Side}}\t{{.Status}}\t{{.ContentType}}\t{{.Size}} bytes\t{{.Duration}}",
+	"token_prompt":        "Token for {{.Host}}: ",
+	"token_removed":       "token for {{.Host}} removed from the keyring",
+	"token_stored":        "token for {{.Host}} stored in the keyring",
+	"up_to_date":          "lt {{.Version}} is up to date",
+	"update_available":    "lt {{.Latest}} is available, running {{.Version}}",
+	"updated":             "lt updated from {{.Version}} to {{.Latest}}",
+	"url":                 "your url is: {{.URL}}",
+	"version":             "lt {{.Version}} ({{.Go}} {{.OS}}/{{.Arch}})",
+	"webhook_rejected":    "webhook {{.Path}} rejected: {{.Err}}",
+	"webhook_verified":    "webhook {{.Path}} verified",
+	"window_closed":       "access window closed, requests are now refused",
+	"window_open":         `access allowed until {{.Until.Format "15:04:05"}}`,
+}
+
+// fields are the values a message is rendered with.
+type fields map[string]interface{}
+
+var (
+	defaults = mustParseMessages(defaultMessages)
+	messages = defaults
+)
+
+// parseMessage parses the template of the named message.
+func parseMessage(name, text string) (*template.Template, error) {
+	if _, ok := defaultMessages[name]; !ok {
+		return nil, fmt.Errorf("unknown message, expected one of %s", keys(defaultMessages))
+	}
+	return template.New(name).Parse(text)
+}
+
+func mustParseMessages(texts map[string]string) map[string]*template.Template {
+	tmpls := make(map[string]*template.Template, len(texts))
+	for name, text := range texts {
+		tmpls[name] = template.Must(parseMessage(name, text))
+	}
+	return tmpls
+}
+
+// setMessages overrides the default messages with those of the user's locale, then
+// with texts.
+func setMessages(texts map[string]string) error {
+	tmpls := make(map[string]*template.Template, len(defaults))
+	for name, tmpl := range defaults {
+		tmpls[name] = tmpl
+	}
+
+	locale, path, err := localeMessages()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range []struct {
+		where string
+		texts map[string]string
+	}{
+		{path, locale},
+		{"config file", texts},
+	} {
+		for name, text := range m.texts {
+			tmpl, err := parseMessage(name, text)
+			if err != nil {
+				return fmt.Errorf("Invalid message %s in %s: %s", name, m.where, err)
+			}
+			tmpls[name] = tmpl
+		}
+	}
+
+	messages = tmpls
+	return nil
+}
+
+// setCommandMessages sets the messages of the commands from the user's locale and
+// the default config file, keeping the defaults when they cannot be read.
+func setCommandMessages() {
+	var texts map[string]string
+	if path, err := defaultConfigFile(); err == nil {
+		if c, err := loadConfig(path, ""); err == nil {
+			texts = c.Messages
+		}
+	}
+	if setMessages(texts) != nil {
+		setMessages(nil)
+	}
+}
+
+// localeMessages reads the messages file of the user's locale, messages.pt_BR.json or
+// messages.pt.json in the config directory for pt_BR, returning its path.
+func localeMessages() (map[string]string, string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, "", nil
+	}
+
+	for _, locale := range locales() {
+		path := filepath.Join(dir, "messages."+locale+".json")
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		var texts map[string]string
+		d := json.NewDecoder(bytes.NewReader(b))
+		if err := d.Decode(&texts); err != nil {
+			return nil, "", decodeError(path, b, b, err)
+		}
+		return texts, path, nil
+	}
+	return nil, "", nil
+}
+
+// locales returns the locales of the messages, most specific first, from the first
+// of LC_ALL, LC_MESSAGES and LANG set, e.g. pt_BR and pt for pt_BR.UTF-8.
+func locales() []string {
+	var locale string
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(env); locale != "" {
+			break
+		}
+	}
+
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+
+	if i := strings.Index(locale, "_"); i > 0 {
+		return []string{locale, locale[:i]}
+	}
+	return []string{locale}
+}
+
+// message renders the named message with data, falling back to the default when the
+// template fails.
+func message(name string, data fields) string {
+	if _, ok := data["Name"]; !ok {
+		all := fields{"Name": ""}
+		for k, v := range data {
+			all[k] = v
+		}
+		data = all
+	}
+
+	var b strings.Builder
+	if err := messages[name].Execute(&b, data); err != nil {
+		b.Reset()
+		defaults[name].Execute(&b, data)
+	}
+	return b.String()
+}
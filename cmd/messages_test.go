@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "pt_BR.UTF-8")
+	t.Cleanup(func() { messages = defaults })
+
+	until := time.Date(2024, 1, 1, 18, 30, 0, 0, time.UTC)
+	for name, expected := range map[string]string{
+		"bye":         "Bye! tunnels closed",
+		"window_open": "access allowed until 18:30:00",
+		"panic":       "recovered from boom",
+	} {
+		data := fields{"Tunnels": 2, "Until": until, "Err": "boom"}
+		if actual := message(name, data); actual != expected {
+			t.Fatalf("Unexpected %s message. Expected: %s, Actual: %s", name, expected, actual)
+		}
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "messages.pt.json"), []byte(`{"url": "sua url é: {{.URL}}", "bye": "Tchau!"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setMessages(map[string]string{"url": "{{.Name}} is up at {{.URL}}"}); err != nil {
+		t.Fatalf("Cannot set the messages: %s", err)
+	}
+	out := &output{name: "api"}
+	for name, expected := range map[string]string{
+		"url":     "api is up at https://ltdemo.loca.lt",
+		"bye":     "Tchau!",
+		"rotated": "your url is now: https://ltdemo.loca.lt",
+	} {
+		if actual := out.message(name, fields{"URL": "https://ltdemo.loca.lt"}); actual != expected {
+			t.Fatalf("Unexpected %s message. Expected: %s, Actual: %s", name, expected, actual)
+		}
+	}
+
+	// a template failing to render falls back to the default
+	if err := setMessages(map[string]string{"window_open": "until {{.Until.Nanos}}"}); err != nil {
+		t.Fatalf("Cannot set the messages: %s", err)
+	}
+	if actual := message("window_open", fields{"Until": until}); actual != "access allowed until 18:30:00" {
+		t.Fatalf("Unexpected message failing to render. Expected: the default, Actual: %q", actual)
+	}
+}
+
+func TestLocales(t *testing.T) {
+	for env, expected := range map[[3]string][]string{
+		{"", "", "pt_BR.UTF-8"}:      {"pt_BR", "pt"},
+		{"fr_FR", "", "pt_BR.UTF-8"}: {"fr_FR", "fr"},
+		{"", "de_DE@euro", ""}:       {"de_DE", "de"},
+		{"C", "", "pt_BR.UTF-8"}:     nil,
+		{"", "", ""}:                 nil,
+		{"", "", "es"}:               {"es"},
+	} {
+		t.Setenv("LC_ALL", env[0])
+		t.Setenv("LC_MESSAGES", env[1])
+		t.Setenv("LANG", env[2])
+		if actual := locales(); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("Unexpected locales of %v. Expected: %v, Actual: %v", env, expected, actual)
+		}
+	}
+}
+
+func TestCommandMessages(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LC_ALL", "C")
+	t.Cleanup(func() { messages = defaults })
+
+	path, err := defaultConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, []byte(`{"messages": {"stopped": "{{.Name}} parado", "error": "{{.Name}}|{{.Err}}"}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setCommandMessages()
+	for name, expected := range map[string]string{
+		"stopped": "ltdemo parado",
+		"error":   "|boom",
+		"version": "lt v1 (go1.17 linux/amd64)",
+	} {
+		data := fields{"Err": "boom", "Version": "v1", "Go": "go1.17", "OS": "linux", "Arch": "amd64"}
+		if name == "stopped" {
+			data["Name"] = "ltdemo"
+		}
+		if actual := message(name, data); actual != expected {
+			t.Fatalf("Unexpected %s message. Expected: %s, Actual: %s", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// metricsHandler serves the tunnel's metrics in the Prometheus text format.
+func metricsHandler(t *lt.Tunnel, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, t, name)
+	})
+}
+
+func writeMetrics(w io.Writer, t *lt.Tunnel, name string) {
+	labels := `tunnel="` + escapeLabel(name) + `"`
+	stats := t.Stats()
+
+	up := 0
+	if t.URL() != "" {
+		up = 1
+	}
+
+	metric(w, "lt_tunnel_up", "gauge", "Whether the tunnel is open.")
+	fmt.Fprintf(w, "lt_tunnel_up{%s} %d\n", labels, up)
+	metric(w, "lt_tunnel_max_connections", "gauge", "Connections allowed by the server.")
+	fmt.Fprintf(w, "lt_tunnel_max_connections{%s} %d\n", labels, t.MaxConn())
+	metric(w, "lt_tunnel_connections", "gauge", "Open connections to the server.")
+	fmt.Fprintf(w, "lt_tunnel_connections{%s} %d\n", labels, stats.Conns)
+	metric(w, "lt_tunnel_first_connection_seconds", "gauge", "Time the connections to the server were all down the last time, until the first one was up again.")
+	fmt.Fprintf(w, "lt_tunnel_first_connection_seconds{%s} %g\n", labels, stats.FirstConn.Seconds())
+	metric(w, "lt_tunnel_queued_requests", "gauge", "Requests waiting for the local server.")
+	fmt.Fprintf(w, "lt_tunnel_queued_requests{%s} %d\n", labels, stats.Queued)
+	metric(w, "lt_tunnel_bans_total", "counter", "Visitors banned for making too many requests.")
+	fmt.Fprintf(w, "lt_tunnel_bans_total{%s} %d\n", labels, stats.Bans)
+	metric(w, "lt_tunnel_received_bytes_total", "counter", "Bytes received from the server.")
+	fmt.Fprintf(w, "lt_tunnel_received_bytes_total{%s} %d\n", labels, stats.BytesIn)
+	metric(w, "lt_tunnel_sent_bytes_total", "counter", "Bytes sent to the server.")
+	fmt.Fprintf(w, "lt_tunnel_sent_bytes_total{%s} %d\n", labels, stats.BytesOut)
+	metric(w, "lt_tunnel_connection_errors_total", "counter", "Failed connections by side and class.")
+	for _, side := range []lt.Side{lt.LocalSide, lt.RemoteSide} {
+		for _, class := range []lt.ErrorClass{lt.ClassEOF, lt.ClassReset, lt.ClassRefused, lt.ClassTimeout, lt.ClassOther} {
+			fmt.Fprintf(w, "lt_tunnel_connection_errors_total{%s,side=\"%s\",class=\"%s\"} %d\n", labels, side, class, stats.Errors[side][class])
+		}
+	}
+
+	traffic := t.TrafficStats()
+	if traffic == nil {
+		return
+	}
+
+	for _, m := range []struct {
+		by    string
+		label string
+		help  string
+		stats map[string]lt.Traffic
+	}{
+		{"path", "path", "path", traffic.Paths},
+		{"status", "class", "status", traffic.Statuses},
+		{"content_type", "type", "response content type", traffic.ContentTypes},
+		{"size", "size", "response body size", traffic.Sizes},
+	} {
+		keys := make([]string, 0, len(m.stats))
+		for k := range m.stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		prefix := "lt_http_" + m.by
+		metric(w, prefix+"_requests_total", "counter", "HTTP requests by "+m.help+".")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s_requests_total{%s,%s=\"%s\"} %d\n", prefix, labels, m.label, escapeLabel(k), m.stats[k].Requests)
+		}
+		metric(w, prefix+"_received_bytes_total", "counter", "HTTP request body bytes by "+m.help+".")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s_received_bytes_total{%s,%s=\"%s\"} %d\n", prefix, labels, m.label, escapeLabel(k), m.stats[k].BytesIn)
+		}
+		metric(w, prefix+"_sent_bytes_total", "counter", "HTTP response body bytes by "+m.help+".")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s_sent_bytes_total{%s,%s=\"%s\"} %d\n", prefix, labels, m.label, escapeLabel(k), m.stats[k].BytesOut)
+		}
+	}
+}
+
+func metric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value of the Prometheus text format.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
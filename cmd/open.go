@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// opening is the name of the tunnel of the config file started by lt open.
+var opening string
+
+func open(args []string) error {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt open [OPTION]... <NAME>\n")
+		fmt.Fprintf(os.Stderr, "Opens the named tunnel of the config file, unless it is already running.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	flag.CommandLine.Parse(args)
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errNameRequired
+	}
+
+	opening = flag.Arg(0)
+	run()
+	return nil
+}
+
+// findTarget returns the target of the config file with the given name.
+func findTarget(targets []target, name string) (target, error) {
+	if len(targets) == 0 {
+		return target{}, errors.New("lt open requires tunnels in the config file, given by -c or created by lt config init")
+	}
+
+	names := make([]string, len(targets))
+	for i, tg := range targets {
+		if tg.Name == name {
+			return tg, nil
+		}
+		names[i] = tg.Name
+	}
+	sort.Strings(names)
+	return target{}, fmt.Errorf("Unknown tunnel %s, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// runningURL returns the URL of the running tunnel exposing the local server of
+// tg, or with its subdomain, if any.
+func runningURL(tg target) string {
+	tunnels, err := runningTunnels()
+	if err != nil {
+		return ""
+	}
+
+	local := net.JoinHostPort(tg.Local, strconv.Itoa(tg.Port))
+	for _, info := range tunnels {
+		if info.Local == local || tg.Subdomain != "" && info.Name == tg.Subdomain {
+			return info.URL
+		}
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// colors are the ANSI colors the names of the tunnels are shown in, in turn.
+var colors = []string{"36", "33", "35", "32", "34", "31"}
+
+var (
+	outputMu sync.Mutex // keeps the lines of concurrent tunnels whole
+	progress string     // the line redrawn below the others while a tunnel opens
+)
+
+// output prints the lines of a tunnel. When lt runs several tunnels they are
+// prefixed with its name, in color on terminals, and hidden unless selected by -only.
+type output struct {
+	name   string
+	prefix string
+	quiet  bool
+	live   bool // shows the progress of the tunnel on the terminal
+}
+
+// newOutputs returns the outputs of the named tunnels, given in the order they are
+// shown. only is a comma separated list of the names to show, or empty for all.
+func newOutputs(names []string, only string) []*output {
+	outs := make([]*output, len(names))
+	if len(names) == 1 {
+		outs[0] = &output{name: names[0], live: isTerminal(os.Stderr)}
+		return outs
+	}
+
+	shown := map[string]bool{}
+	for _, name := range strings.Split(only, ",") {
+		if name != "" {
+			shown[name] = true
+		}
+	}
+
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	color := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	for i, name := range names {
+		prefix := fmt.Sprintf("%-*s | ", width, name)
+		if color {
+			prefix = "\x1b[" + colors[i%len(colors)] + "m" + prefix + "\x1b[0m"
+		}
+		outs[i] = &output{name: name, prefix: prefix, quiet: len(shown) > 0 && !shown[name]}
+	}
+	return outs
+}
+
+// Print prints the named message, rendered with data, to stdout.
+func (o *output) Print(name string, data fields) {
+	o.print(os.Stdout, o.message(name, data))
+}
+
+// Error prints the named message, rendered with data, to stderr.
+func (o *output) Error(name string, data fields) {
+	o.print(os.Stderr, o.message(name, data))
+}
+
+// message renders the named message with data and the name of the tunnel.
+func (o *output) message(name string, data fields) string {
+	all := fields{"Name": o.name}
+	for k, v := range data {
+		all[k] = v
+	}
+	return message(name, all)
+}
+
+func (o *output) print(w io.Writer, text string) {
+	if o.quiet {
+		return
+	}
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if progress != "" {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		defer fmt.Fprint(os.Stderr, progress)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", o.prefix, line)
+	}
+}
+
+// Progress shows the named message on the terminal until replaced by the next one or
+// cleared by EndProgress, keeping it below the lines printed meanwhile. It does
+// nothing unless lt runs a single tunnel and stderr is a terminal.
+func (o *output) Progress(name string, data fields) {
+	if !o.live {
+		return
+	}
+
+	text := o.message(name, data)
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	progress = text
+	fmt.Fprint(os.Stderr, "\r\x1b[K"+progress)
+}
+
+// EndProgress clears the line shown by Progress.
+func (o *output) EndProgress() {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if progress != "" {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		progress = ""
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+)
+
+var errProfileRequired = errors.New("Missing required arguments: name and profile")
+
+// handlePprof serves the runtime profiles of lt under /debug/pprof/, as expected by
+// go tool pprof.
+func handlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+func pprofCommand(args []string) error {
+	fs := flag.NewFlagSet("pprof", flag.ExitOnError)
+	seconds := fs.Int("seconds", 30, "Duration of the CPU profile or the trace")
+	out := fs.String("o", "", "Write the profile to this file instead of NAME-PROFILE.pprof")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt pprof [-seconds N] [-o FILE] <NAME> <PROFILE>\n")
+		fmt.Fprintf(os.Stderr, "Saves a profile of a tunnel running with -pprof, for go tool pprof.\n")
+		fmt.Fprintf(os.Stderr, "PROFILE is heap, goroutine, allocs, block, mutex, threadcreate, profile (CPU) or trace.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errProfileRequired
+	}
+
+	name, profile := tunnelName(fs.Arg(0)), fs.Arg(1)
+	if *out == "" {
+		*out = fmt.Sprintf("%s-%s.pprof", name, profile)
+	}
+
+	resp, err := controlClient(name).Get(fmt.Sprintf("http://lt/debug/pprof/%s?seconds=%d", profile, *seconds))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: profiling disabled or unknown profile, run lt with -pprof", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", name, resp.Status)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(message("profile_written", fields{"Path": *out}))
+	return nil
+}
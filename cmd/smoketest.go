@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+var errSmokeTest = errors.New("Smoke test failed")
+
+func smokeTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	host := fs.String("h", defaultHost, "Upstream server providing forwarding")
+	local := fs.String("l", "localhost", "Tunnel traffic to this host instead of localhost")
+	port := fs.Int("p", 0, "Internal http server port")
+	subdomain := fs.String("s", "", "Request this subdomain")
+	path := fs.String("path", "/", "Path requested locally and through the tunnel")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt test -p <PORT> [-h HOST] [-l HOST] [-s SUBDOMAIN] [-path PATH]\n")
+		fmt.Fprintf(os.Stderr, "Opens a tunnel, requests a path both locally and through the public URL, and fails\n")
+		fmt.Fprintf(os.Stderr, "unless both responses match, e.g. as a CI preflight check.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if *port == 0 {
+		fs.Usage()
+		return errPortRequired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	t, err := lt.Expose(ctx, fmt.Sprintf("%s:%d", *local, *port),
+		lt.WithClient(lt.NewClient(*host)), lt.WithSubdomain(*subdomain))
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	fmt.Println(message("test_opened", fields{"URL": t.URL()}))
+	c, err := t.Compare(ctx, *path)
+	if err != nil {
+		fmt.Println(message("test_failed", nil))
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, r := range []struct {
+		name string
+		s    lt.ResponseSummary
+	}{{"local", c.Local}, {"tunnel", c.Remote}} {
+		fmt.Fprintln(w, message("test_response", fields{
+			"Side":        r.name,
+			"Status":      r.s.Status,
+			"ContentType": r.s.ContentType,
+			"Size":        r.s.Size,
+			"Duration":    r.s.Duration.Round(time.Millisecond),
+		}))
+	}
+	w.Flush()
+
+	diffs := c.Differences()
+	for _, d := range diffs {
+		fmt.Println(message("test_difference", fields{"Difference": d}))
+	}
+	if len(diffs) > 0 {
+		fmt.Println(message("test_failed", nil))
+		return errSmokeTest
+	}
+	fmt.Println(message("test_passed", nil))
+	return nil
+}
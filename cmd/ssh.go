@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sshFallback exposes the local port through a reverse forward on a publicly reachable
+// box, using the ssh command, until interrupted. The box's sshd must allow remote hosts
+// to connect to forwarded ports (GatewayPorts yes or clientspecified).
+func sshFallback(target, key string, remotePort int) error {
+	args := []string{
+		"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-R", fmt.Sprintf("0.0.0.0:%d:%s", remotePort, net.JoinHostPort(*local, strconv.Itoa(*port))),
+	}
+	if key != "" {
+		args = append(args, "-i", key)
+	}
+	args = append(args, target)
+
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	// ssh exits early when the forward cannot be established
+	select {
+	case err := <-done:
+		return fmt.Errorf("ssh: %s", err)
+	case <-time.After(3 * time.Second):
+	}
+
+	fmt.Println(message("url", fields{"URL": "http://" + net.JoinHostPort(sshHostname(target), strconv.Itoa(remotePort))}))
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		for s := range sig {
+			fmt.Println(message("signal", fields{"Signal": s}))
+			cmd.Process.Signal(os.Interrupt)
+		}
+	}()
+
+	err = <-done
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && !exit.Exited() {
+		// interrupted
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("ssh: %s", err)
+	}
+
+	fmt.Println(message("bye", fields{"Tunnels": 1}))
+	return nil
+}
+
+// sshHostname returns the host of an ssh destination: [user@]host or ssh://[user@]host[:port].
+func sshHostname(target string) string {
+	target = strings.TrimPrefix(target, "ssh://")
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		target = target[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		return h
+	}
+	return target
+}
+
+// unreachable reports whether err means the localtunnel server could not be reached.
+func unreachable(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// statsSnapshot is written by -stats-out once the tunnels are closed.
+type statsSnapshot struct {
+	Started time.Time    `json:"started"`
+	Stopped time.Time    `json:"stopped"`
+	Tunnels []tunnelInfo `json:"tunnels"`
+}
+
+// writeStats writes the snapshot to path as indented JSON.
+func writeStats(path string, s *statsSnapshot) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
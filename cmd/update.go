@@ -0,0 +1,287 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+const (
+	releasesURL    = "https://api.github.com/repos/jweslley/localtunnel/releases/latest"
+	checksumsFile  = "checksums.txt"
+	signatureFile  = checksumsFile + ".sig"
+	maxReleaseSize = 64 << 20
+)
+
+// releaseKey is the ed25519 public key, in base64, the checksums of the releases are
+// signed with. It is pinned in the binary by make dist, so a release cannot vouch for
+// itself: replacing its archives and checksums also requires the private key.
+var releaseKey string
+
+var (
+	errNoRelease     = errors.New("No release available for this platform")
+	errNoReleaseKey  = errors.New("This build of lt has no release key to verify updates with, install the update by hand")
+	errBadSignature  = errors.New("Invalid signature of the release checksums")
+	errBadReleaseKey = errors.New("Invalid release key")
+)
+
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// asset returns the download URL of the named asset.
+func (r *release) asset(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+func update(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	checkOnly := fs.Bool("check", false, "Only report whether an update is available")
+	force := fs.Bool("f", false, "Reinstall the latest release even if already running it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt update [-check] [-f]\n")
+		fmt.Fprintf(os.Stderr, "Replaces lt with the latest release for this platform, once its signed checksum is verified.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	var r release
+	err := fetchJSON(releasesURL, &r)
+	if err != nil {
+		return fmt.Errorf("Cannot check the latest release: %s", err)
+	}
+
+	current := strings.TrimPrefix(lt.Version(), "v")
+	latest := strings.TrimPrefix(r.Tag, "v")
+	if latest == current && !*force {
+		fmt.Println(message("up_to_date", fields{"Version": current}))
+		return nil
+	}
+
+	if *checkOnly {
+		fmt.Println(message("update_available", fields{"Version": current, "Latest": latest}))
+		return nil
+	}
+
+	if releaseKey == "" {
+		return errNoReleaseKey
+	}
+
+	name := fmt.Sprintf("lt-%s-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH, latest)
+	archiveURL, ok := r.asset(name)
+	if !ok {
+		return errNoRelease
+	}
+	sumsURL, ok := r.asset(checksumsFile)
+	if !ok {
+		return fmt.Errorf("Release %s has no %s", latest, checksumsFile)
+	}
+	sigURL, ok := r.asset(signatureFile)
+	if !ok {
+		return fmt.Errorf("Release %s has no %s", latest, signatureFile)
+	}
+
+	sums, err := download(sumsURL)
+	if err != nil {
+		return err
+	}
+	sig, err := download(sigURL)
+	if err != nil {
+		return err
+	}
+	err = verifySignature(releaseKey, sums, sig)
+	if err != nil {
+		return err
+	}
+	sum, ok := checksum(sums, name)
+	if !ok {
+		return fmt.Errorf("No checksum for %s", name)
+	}
+
+	archive, err := download(archiveURL)
+	if err != nil {
+		return err
+	}
+	actual := sha256.Sum256(archive)
+	if hex.EncodeToString(actual[:]) != sum {
+		return fmt.Errorf("Checksum mismatch for %s", name)
+	}
+
+	bin, err := extractBinary(archive)
+	if err != nil {
+		return err
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	err = replaceExecutable(path, bin)
+	if err != nil {
+		return fmt.Errorf("Cannot replace %s: %s", path, err)
+	}
+
+	fmt.Println(message("updated", fields{"Version": current, "Latest": latest}))
+	return nil
+}
+
+func fetchJSON(url string, v interface{}) error {
+	b, err := download(url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxReleaseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxReleaseSize {
+		return nil, fmt.Errorf("%s: too large", url)
+	}
+	return b, nil
+}
+
+// verifySignature checks that sig is the ed25519 signature of sums by key, a public
+// key in base64. The signature may be raw or in base64.
+func verifySignature(key string, sums, sig []byte) error {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errBadReleaseKey
+	}
+
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil {
+			return errBadSignature
+		}
+		sig = decoded
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(pub), sums, sig) {
+		return errBadSignature
+	}
+	return nil
+}
+
+// checksum looks up the SHA-256 of name in the output of sha256sum.
+func checksum(sums []byte, name string) (string, bool) {
+	s := bufio.NewScanner(bytes.NewReader(sums))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), true
+		}
+	}
+	return "", false
+}
+
+// extractBinary returns the lt binary of a release archive.
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.New("No lt binary in the release archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		base := filepath.Base(h.Name)
+		if h.Typeflag == tar.TypeReg && (base == "lt" || base == "lt.exe") {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// replaceExecutable swaps the binary at path for bin. The running binary is moved
+// aside first, as Windows does not allow overwriting it.
+func replaceExecutable(path string, bin []byte) error {
+	dir := filepath.Dir(path)
+	f, err := ioutil.TempFile(dir, ".lt-update-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(bin)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), 0755)
+	if err != nil {
+		return err
+	}
+
+	old := path + ".old"
+	os.Remove(old)
+	err = os.Rename(path, old)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		os.Rename(old, path)
+		return err
+	}
+
+	// fails on Windows while still running, the next update removes it
+	os.Remove(old)
+	return nil
+}
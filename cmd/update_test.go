@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+	sums := []byte("abc123  lt-linux-amd64-0.2.0.tar.gz\n")
+	sig := ed25519.Sign(priv, sums)
+
+	if err := verifySignature(key, sums, sig); err != nil {
+		t.Fatalf("Valid signature should be accepted: %s", err)
+	}
+	if err := verifySignature(key, sums, []byte(base64.StdEncoding.EncodeToString(sig)+"\n")); err != nil {
+		t.Fatalf("Valid signature in base64 should be accepted: %s", err)
+	}
+
+	tampered := []byte("def456  lt-linux-amd64-0.2.0.tar.gz\n")
+	if err := verifySignature(key, tampered, sig); err != errBadSignature {
+		t.Fatalf("Unexpected error for tampered checksums. Expected: %v, Actual: %v", errBadSignature, err)
+	}
+
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if err := verifySignature(base64.StdEncoding.EncodeToString(other), sums, sig); err != errBadSignature {
+		t.Fatalf("Unexpected error for another key. Expected: %v, Actual: %v", errBadSignature, err)
+	}
+	if err := verifySignature("not a key", sums, sig); err != errBadReleaseKey {
+		t.Fatalf("Unexpected error for an invalid key. Expected: %v, Actual: %v", errBadReleaseKey, err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	sums := []byte("ABC123  lt-linux-amd64-0.2.0.tar.gz\ndef456 *lt-darwin-amd64-0.2.0.tar.gz\n")
+
+	for name, expected := range map[string]string{
+		"lt-linux-amd64-0.2.0.tar.gz":  "abc123",
+		"lt-darwin-amd64-0.2.0.tar.gz": "def456",
+	} {
+		sum, ok := checksum(sums, name)
+		if !ok || sum != expected {
+			t.Fatalf("Unexpected checksum of %s. Expected: %s, Actual: %s", name, expected, sum)
+		}
+	}
+
+	if _, ok := checksum(sums, "lt-linux-386-0.2.0.tar.gz"); ok {
+		t.Fatal("Missing archive should have no checksum")
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+// subdomainPattern matches the subdomains accepted by the localtunnel server.
+var subdomainPattern = regexp.MustCompile(`^(?:[a-z0-9][a-z0-9-]{4,63}[a-z0-9]|[a-z0-9]{4,63})$`)
+
+// invalidConfig lists the problems found in a config file.
+type invalidConfig struct {
+	path     string
+	profile  string
+	problems []string
+}
+
+func (e *invalidConfig) Error() string {
+	where := e.path
+	if e.profile != "" {
+		where += " with profile " + e.profile
+	}
+	return fmt.Sprintf("Invalid config file %s:\n  %s", where, strings.Join(e.problems, "\n  "))
+}
+
+// decodeError describes a JSON error of the config file b with the line and column
+// where it happened, raw being the part of b whose decoding failed.
+func decodeError(path string, b, raw []byte, err error) error {
+	base := int64(bytes.Index(b, raw))
+	offset := int64(-1)
+	msg := strings.TrimPrefix(err.Error(), "json: ")
+
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		offset = syntax.Offset - 1
+	case errors.As(err, &typ):
+		offset = typ.Offset - 1
+		msg = fmt.Sprintf("%s: expected %s, found %s", typ.Field, typ.Type, typ.Value)
+	case strings.HasPrefix(msg, "unknown field "):
+		name := strings.TrimPrefix(msg, "unknown field ")
+		if i := bytes.Index(raw, []byte(name)); i >= 0 {
+			offset = int64(i)
+		}
+	}
+
+	if offset < 0 || base < 0 {
+		return fmt.Errorf("Invalid config file %s: %s", path, msg)
+	}
+	line, col := position(b, base+offset)
+	return fmt.Errorf("Invalid config file %s:%d:%d: %s", path, line, col, msg)
+}
+
+// position returns the line and column of offset in b, both starting at 1.
+func position(b []byte, offset int64) (line, col int) {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	before := b[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// validate returns the problems of the config, each one starting with the field
+// involved.
+func (c *config) validate() []string {
+	var problems []string
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if c.Host != "" {
+		if u, err := url.Parse(c.Host); err != nil || u.Scheme == "" || u.Host == "" {
+			add("host", "%q is not a URL", c.Host)
+		}
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		add("port", "%d is not a port", c.Port)
+	}
+	if c.Subdomain != "" && !subdomainPattern.MatchString(c.Subdomain) {
+		add("subdomain", "%q is not a valid subdomain", c.Subdomain)
+	}
+
+	names := map[string]string{}
+	subdomains := map[string]string{}
+	locals := map[string]string{}
+	for i, t := range c.Tunnels {
+		field := fmt.Sprintf("tunnels[%d]", i)
+		name := t.Name
+		if name == "" {
+			name = t.Subdomain
+		}
+		if name == "" {
+			name = strconv.Itoa(t.Port)
+		}
+
+		if t.Port <= 0 || t.Port > 65535 {
+			add(field+".port", "missing or invalid port of tunnel %q", name)
+		}
+
+		if other, ok := names[name]; ok {
+			add(field+".name", "%q is already the name of %s", name, other)
+		} else {
+			names[name] = field
+		}
+
+		if t.Subdomain != "" {
+			if !subdomainPattern.MatchString(t.Subdomain) {
+				add(field+".subdomain", "%q is not a valid subdomain", t.Subdomain)
+			} else if other, ok := subdomains[t.Subdomain]; ok {
+				add(field+".subdomain", "%q is already requested by %s", t.Subdomain, other)
+			} else {
+				subdomains[t.Subdomain] = field
+			}
+		}
+
+		local := t.Local
+		if local == "" {
+			local = c.Local
+		}
+		if local == "" {
+			local = "localhost"
+		}
+		addr := net.JoinHostPort(local, strconv.Itoa(t.Port))
+		if other, ok := locals[addr]; ok && t.Port > 0 {
+			add(field+".port", "%s is already exposed by %s", addr, other)
+		} else {
+			locals[addr] = field
+		}
+	}
+
+	for i, r := range c.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		switch r.Action {
+		case lt.Allow, lt.Deny:
+		case lt.Rewrite:
+			if r.To == "" {
+				add(field+".to", "missing path replacing %q", r.Path)
+			}
+		case lt.RewriteStatus:
+			if len(r.Statuses) == 0 {
+				add(field+".statuses", "missing statuses to rewrite")
+			}
+			for from, to := range r.Statuses {
+				if !validStatus(from) || !validStatus(to) {
+					add(field+".statuses", "%d to %d is not a valid rewrite", from, to)
+				}
+			}
+		default:
+			add(field+".action", "unknown action %q", r.Action)
+		}
+	}
+
+	for i, m := range c.Mocks {
+		field := fmt.Sprintf("mocks[%d]", i)
+		if m.Path == "" {
+			add(field+".path", "missing path")
+		}
+		if m.Status != 0 && !validStatus(m.Status) {
+			add(field+".status", "%d is not a valid status", m.Status)
+		}
+	}
+
+	if c.OAuth != nil {
+		if _, ok := oauthProviders[c.OAuth.Provider]; !ok {
+			add("oauth.provider", "unknown provider %q, expected one of %s", c.OAuth.Provider, keys(oauthProviders))
+		}
+		if c.OAuth.ClientID == "" || c.OAuth.ClientSecret == "" {
+			add("oauth", "missing client_id or client_secret")
+		}
+	}
+
+	for i, w := range c.Webhooks {
+		field := fmt.Sprintf("webhooks[%d]", i)
+		if w.Path == "" {
+			add(field+".path", "missing path")
+		}
+		if _, ok := webhookVerifiers[w.Provider]; !ok {
+			add(field+".provider", "unknown provider %q, expected one of %s", w.Provider, keys(webhookVerifiers))
+		}
+		if w.Secret == "" {
+			add(field+".secret", "missing secret")
+		}
+	}
+
+	for i, b := range c.Backends {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			add(fmt.Sprintf("backends[%d]", i), "%q is not a host:port", b)
+		}
+	}
+
+	if c.TrafficStats < 0 {
+		add("traffic_stats", "negative depth %d", c.TrafficStats)
+	}
+	if c.Supervise < 0 {
+		add("supervise", "negative interval")
+	}
+
+	if c.Coalesce != nil && (c.Coalesce.Threshold < 0 || c.Coalesce.Interval < 0) {
+		add("coalesce", "negative threshold or interval")
+	}
+
+	if c.Ban != nil && c.Ban.Limit <= 0 {
+		add("ban.limit", "expected a positive limit, found %d", c.Ban.Limit)
+	}
+	if c.Ban != nil && (c.Ban.Window < 0 || c.Ban.Duration < 0) {
+		add("ban", "negative window or duration")
+	}
+
+	if c.Queue != nil {
+		if c.Queue.Limit < 0 {
+			add("queue.limit", "negative limit %d", c.Queue.Limit)
+		}
+		if _, ok := queuePolicies[c.Queue.Policy]; c.Queue.Policy != "" && !ok {
+			add("queue.policy", "unknown policy %q, expected one of %s", c.Queue.Policy, keys(queuePolicies))
+		}
+	}
+
+	for name, text := range c.Messages {
+		if _, err := parseMessage(name, text); err != nil {
+			add("messages."+name, "%s", err)
+		}
+	}
+
+	if c.Capture != nil {
+		for i, expr := range c.Capture.RedactBody {
+			if _, err := regexp.Compile(expr); err != nil {
+				add(fmt.Sprintf("capture.redact_body[%d]", i), "%s", err)
+			}
+		}
+	}
+
+	if p := c.Playback; p != nil {
+		if p.HAR == "" && !p.Capture {
+			add("playback", "missing har file or capture")
+		}
+		if p.Capture && c.Capture == nil {
+			add("playback.capture", "requires the capture section")
+		}
+	}
+
+	return problems
+}
+
+func validStatus(code int) bool { return code >= 100 && code <= 599 }
+
+// keys returns the sorted keys of a map with string keys, joined by commas.
+func keys(m interface{}) string {
+	return strings.Join(sortedKeys(m), ", ")
+}
+
+// sortedKeys returns the keys of the map m, which must have string keys, in order.
+func sortedKeys(m interface{}) []string {
+	var names []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func configValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	path := fs.String("c", "", "Config file to validate, instead of the one shown by lt config path")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt config validate [-c FILE]\n")
+		fmt.Fprintf(os.Stderr, "Checks the config file and each of its profiles.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if *path == "" {
+		var err error
+		*path, err = defaultConfigFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	c, err := loadConfig(*path, "")
+	if err != nil {
+		return err
+	}
+
+	profiles := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+
+	var failed []string
+	for _, name := range profiles {
+		_, err := loadConfig(*path, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Invalid profiles in %s: %s", *path, strings.Join(failed, ", "))
+	}
+
+	fmt.Println(message("config_valid", fields{"Path": *path}))
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	lt "github.com/jweslley/localtunnel"
+)
+
+func version(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the build information as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lt version [-json]\n")
+		fmt.Fprintf(os.Stderr, "Prints the version of lt, useful when reporting bugs.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+	}
+	fs.Parse(args)
+
+	if !*asJSON {
+		fmt.Println(message("version", fields{"Version": lt.Version(), "Go": runtime.Version(), "OS": runtime.GOOS, "Arch": runtime.GOARCH}))
+		return nil
+	}
+
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(struct {
+		Version string `json:"version"`
+		Go      string `json:"go"`
+		OS      string `json:"os"`
+		Arch    string `json:"arch"`
+	}{lt.Version(), runtime.Version(), runtime.GOOS, runtime.GOARCH})
+}
@@ -0,0 +1,101 @@
+package localtunnel
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	// DefaultCoalesceThreshold is how many bytes WithWriteCoalescing lets pending by
+	// default.
+	DefaultCoalesceThreshold = 16 << 10
+
+	// DefaultCoalesceInterval is how long WithWriteCoalescing lets bytes pending by
+	// default.
+	DefaultCoalesceInterval = 2 * time.Millisecond
+)
+
+// WithWriteCoalescing batches the data the local server sends through the tunnel,
+// writing it to the remote server once threshold bytes are pending or interval after
+// the first pending byte, whichever comes first. Chatty local servers making many
+// small writes then cost fewer, larger ones, while their latency grows by interval
+// at most. Zero values stand for DefaultCoalesceThreshold and DefaultCoalesceInterval.
+// Nagle's algorithm is disabled on the remote connections, so the batches are sent
+// as soon as written instead of being delayed again by the kernel.
+func WithWriteCoalescing(threshold int, interval time.Duration) Option {
+	return func(t *Tunnel) {
+		if threshold <= 0 {
+			threshold = DefaultCoalesceThreshold
+		}
+		if interval <= 0 {
+			interval = DefaultCoalesceInterval
+		}
+		t.coalesceThreshold = threshold
+		t.coalesceInterval = interval
+	}
+}
+
+// batch holds the data pending to be written to the remote server. Its timer follows
+// the real clock, like the deadlines of the connections, as the data must flow
+// whatever the tunnel's Clock.
+type batch struct {
+	c       *conn
+	remote  net.Conn
+	pending []byte
+	timer   *time.Timer // fires once the pending data is due
+	armed   bool
+}
+
+// newBatch returns a batch of the data written to remote.
+func (c *conn) newBatch(remote net.Conn) *batch {
+	if tc, ok := remote.(*net.TCPConn); ok {
+		tc.SetNoDelay(true)
+	}
+	return &batch{c: c, remote: remote, pending: make([]byte, 0, c.t.coalesceThreshold)}
+}
+
+// add appends b to the pending data, writing it once over the threshold.
+func (b *batch) add(p []byte) error {
+	b.pending = append(b.pending, p...)
+	if len(b.pending) >= b.c.t.coalesceThreshold {
+		return b.flush()
+	}
+
+	if !b.armed {
+		if b.timer == nil {
+			b.timer = time.NewTimer(b.c.t.coalesceInterval)
+		} else {
+			b.timer.Reset(b.c.t.coalesceInterval)
+		}
+		b.armed = true
+	}
+	return nil
+}
+
+// due returns a channel receiving once the pending data must be written. It blocks
+// forever on a nil batch.
+func (b *batch) due() <-chan time.Time {
+	if b == nil || !b.armed {
+		return nil
+	}
+	return b.timer.C
+}
+
+// flush writes the pending data.
+func (b *batch) flush() error {
+	if b.armed && !b.timer.Stop() {
+		// fired but not received, unless flushed because it did
+		select {
+		case <-b.timer.C:
+		default:
+		}
+	}
+	b.armed = false
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	err := b.c.write(b.remote, b.pending)
+	b.pending = b.pending[:0]
+	return err
+}
@@ -0,0 +1,101 @@
+package localtunnel
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// openRawTunnel opens a tunnel to a local server writing what it receives on writes,
+// returning the connection on which the fake server reads it.
+func openRawTunnel(tb testing.TB, writes <-chan []byte, opts ...Option) net.Conn {
+	local, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { local.Close() })
+
+	go func() {
+		c, err := local.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for b := range writes {
+			c.Write(b)
+		}
+	}()
+
+	s := newFakeServer(tb, 1)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", local.Addr().(*net.TCPAddr).Port, opts...)
+	err = tunnel.Open()
+	if err != nil {
+		tb.Fatalf("Cannot open tunnel: %s", err)
+	}
+	tb.Cleanup(tunnel.Close)
+
+	return s.conn(tb)
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	writes := make(chan []byte)
+	defer close(writes)
+	// the interval follows the real clock, whatever the tunnel's one
+	remote := openRawTunnel(t, writes, WithWriteCoalescing(8, 300*time.Millisecond), WithClock(newFakeClock()))
+
+	start := time.Now()
+	writes <- []byte("abc")
+
+	remote.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	b := make([]byte, 16)
+	if n, err := remote.Read(b); err == nil {
+		t.Fatalf("Unexpected write before the interval: %q", b[:n])
+	}
+
+	remote.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := remote.Read(b)
+	if err != nil || string(b[:n]) != "abc" {
+		t.Fatalf("Unexpected write after the interval. Expected: abc, Actual: %q %v", b[:n], err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("Pending data written before the interval, after %s", elapsed)
+	}
+
+	// over the threshold, the data is written at once
+	writes <- []byte("0123456789")
+	n, err = io.ReadFull(remote, b[:10])
+	if err != nil || string(b[:n]) != "0123456789" {
+		t.Fatalf("Unexpected write over the threshold. Expected: 0123456789, Actual: %q %v", b[:n], err)
+	}
+}
+
+func benchmarkPipe(b *testing.B, opts ...Option) {
+	const chunk, chunks = 64, 256
+	writes := make(chan []byte)
+	remote := openRawTunnel(b, writes, opts...)
+
+	go func() {
+		defer close(writes)
+		p := make([]byte, chunk)
+		for i := 0; i < b.N*chunks; i++ {
+			writes <- p
+		}
+	}()
+
+	b.SetBytes(chunk * chunks)
+	b.ResetTimer()
+	buf := make([]byte, chunk*chunks)
+	for i := 0; i < b.N; i++ {
+		_, err := io.ReadFull(remote, buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPipe(b *testing.B) { benchmarkPipe(b) }
+
+func BenchmarkPipeCoalesced(b *testing.B) {
+	benchmarkPipe(b, WithWriteCoalescing(0, 0))
+}
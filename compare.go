@@ -0,0 +1,103 @@
+package localtunnel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ResponseSummary describes a response compared by Compare.
+type ResponseSummary struct {
+	Status      int
+	ContentType string
+	Size        int64
+	Digest      string // SHA-256 of the body, in hex
+	Duration    time.Duration
+}
+
+// A Comparison is the outcome of Compare.
+type Comparison struct {
+	Path   string
+	Local  ResponseSummary
+	Remote ResponseSummary
+}
+
+// Differences lists how the response through the tunnel differs from the one of the
+// local server, none when the tunnel served it faithfully.
+func (c *Comparison) Differences() []string {
+	var diffs []string
+	if c.Local.Status != c.Remote.Status {
+		diffs = append(diffs, fmt.Sprintf("status: %d locally, %d through the tunnel", c.Local.Status, c.Remote.Status))
+	}
+	if c.Local.ContentType != c.Remote.ContentType {
+		diffs = append(diffs, fmt.Sprintf("content type: %q locally, %q through the tunnel", c.Local.ContentType, c.Remote.ContentType))
+	}
+	if c.Local.Size != c.Remote.Size {
+		diffs = append(diffs, fmt.Sprintf("size: %d bytes locally, %d through the tunnel", c.Local.Size, c.Remote.Size))
+	} else if c.Local.Digest != c.Remote.Digest {
+		diffs = append(diffs, "body: same size but different content")
+	}
+	return diffs
+}
+
+// Compare requests path from the local server, then through the tunnel's public URL,
+// so the Differences of their responses tell whether the tunnel works end to end.
+// The tunnel must be open.
+func (t *Tunnel) Compare(ctx context.Context, path string) (*Comparison, error) {
+	remote := t.URL()
+	if remote == "" {
+		return nil, ErrClosed
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	c := &Comparison{Path: path}
+	local := "http://" + net.JoinHostPort(t.LocalHost(), strconv.Itoa(t.LocalPort()))
+	err := t.summarize(ctx, local+path, &c.Local)
+	if err != nil {
+		return nil, fmt.Errorf("localtunnel: local request: %w", err)
+	}
+	err = t.summarize(ctx, strings.TrimSuffix(remote, "/")+path, &c.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("localtunnel: request through the tunnel: %w", err)
+	}
+	return c, nil
+}
+
+func (t *Tunnel) summarize(ctx context.Context, url string, s *ResponseSummary) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Bypass-Tunnel-Reminder", "1")
+	req.Header.Set("User-Agent", userAgent())
+
+	start := time.Now()
+	resp, err := httpClient(t.withResolver(ctx)).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	h := sha256.New()
+	s.Size, err = io.Copy(h, resp.Body)
+	if err != nil {
+		return err
+	}
+	s.Duration = time.Since(start)
+	s.Status = resp.StatusCode
+	s.ContentType = resp.Header.Get("Content-Type")
+	s.Digest = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
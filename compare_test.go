@@ -0,0 +1,81 @@
+package localtunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+)
+
+// newRelay returns a tunnel to handler whose public URL relays the requests to the
+// connections of the tunnel, as the localtunnel server does.
+func newRelay(t *testing.T, handler http.Handler) *Tunnel {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(handler)
+	t.Cleanup(local.Close)
+
+	relay := httptest.NewServer(&httputil.ReverseProxy{
+		Director: func(r *http.Request) { r.URL.Scheme, r.URL.Host = "http", "tunnel" },
+		Transport: &http.Transport{
+			DialContext:       func(ctx context.Context, network, addr string) (net.Conn, error) { return s.conn(t), nil },
+			DisableKeepAlives: true,
+		},
+	})
+	t.Cleanup(relay.Close)
+
+	addr := s.ln.Addr().(*net.TCPAddr)
+	tunnel := NewProviderClient(&staticProvider{&Registration{
+		URL:        relay.URL,
+		RemoteHost: addr.IP.String(),
+		RemotePort: addr.Port,
+		MaxConn:    1,
+	}}).NewTunnel("127.0.0.1", getServerPort(t, local))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	t.Cleanup(tunnel.Close)
+	return tunnel
+}
+
+func TestCompare(t *testing.T) {
+	tunnel := newRelay(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "hello from %s", r.URL.Path)
+	}))
+
+	c, err := tunnel.Compare(context.Background(), "status")
+	if err != nil {
+		t.Fatalf("Cannot compare: %s", err)
+	}
+	if c.Path != "/status" || c.Local.Status != http.StatusOK || c.Local.Size != int64(len("hello from /status")) {
+		t.Fatalf("Unexpected comparison. Actual: %+v", c)
+	}
+	if diffs := c.Differences(); len(diffs) != 0 {
+		t.Fatalf("Unexpected differences: %v", diffs)
+	}
+}
+
+func TestCompareDifferences(t *testing.T) {
+	c := &Comparison{
+		Local:  ResponseSummary{Status: 200, ContentType: "text/html", Size: 10, Digest: "a"},
+		Remote: ResponseSummary{Status: 200, ContentType: "text/html", Size: 10, Digest: "b"},
+	}
+	if diffs := c.Differences(); len(diffs) != 1 || diffs[0] != "body: same size but different content" {
+		t.Fatalf("Unexpected differences: %v", diffs)
+	}
+
+	c.Remote = ResponseSummary{Status: 503, ContentType: "text/plain", Size: 4}
+	if diffs := c.Differences(); len(diffs) != 3 {
+		t.Fatalf("Unexpected differences. Expected: status, content type and size, Actual: %v", diffs)
+	}
+}
+
+func TestCompareClosedTunnel(t *testing.T) {
+	if _, err := NewTunnel("127.0.0.1", 8000).Compare(context.Background(), "/"); err != ErrClosed {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrClosed, err)
+	}
+}
@@ -0,0 +1,83 @@
+package localtunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// EventConnError is emitted when one of the tunnel's connections fails, with Err
+// holding a *ConnError.
+const EventConnError EventType = "conn_error"
+
+// A Side is the end of a forwarded connection.
+type Side string
+
+const (
+	LocalSide  Side = "local"  // the local server
+	RemoteSide Side = "remote" // the relay of the localtunnel server
+)
+
+// An ErrorClass tells how a connection failed.
+type ErrorClass string
+
+const (
+	ClassEOF     ErrorClass = "eof"     // closed by its end
+	ClassReset   ErrorClass = "reset"   // reset or aborted by its end
+	ClassRefused ErrorClass = "refused" // refused, nothing listening
+	ClassTimeout ErrorClass = "timeout" // idle beyond the read or write timeout
+	ClassOther   ErrorClass = "other"
+)
+
+// A ConnError is the failure of a connection to the local or the remote server,
+// classified so that a crashed local server can be told from a dropped relay.
+type ConnError struct {
+	Side  Side
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ConnError) Error() string {
+	return fmt.Sprintf("localtunnel: %s connection failed (%s): %v", e.Side, e.Class, e.Err)
+}
+
+func (e *ConnError) Unwrap() error { return e.Err }
+
+// sideError classifies err as a failure of the connection to side, or returns nil
+// when err is nil.
+func sideError(side Side, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ConnError{Side: side, Class: classify(err), Err: err}
+}
+
+func classify(err error) ErrorClass {
+	var ne net.Error
+	switch {
+	case errors.Is(err, io.EOF):
+		return ClassEOF
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE):
+		return ClassReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassRefused
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return ClassTimeout
+	}
+	return ClassOther
+}
+
+// connFailed accounts for the failure of a connection of the tunnel, unless the
+// tunnel is closing.
+func (t *Tunnel) connFailed(closeCh <-chan struct{}, err error) {
+	var ce *ConnError
+	if !isOpen(closeCh) || !errors.As(err, &ce) {
+		return
+	}
+
+	t.connErrors.add(ce.Side, ce.Class)
+	t.emit(Event{Type: EventConnError, Err: ce})
+}
@@ -0,0 +1,113 @@
+package localtunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	op := func(err error) error {
+		return &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", err)}
+	}
+	tests := map[error]ErrorClass{
+		io.EOF:                       ClassEOF,
+		fmt.Errorf("x: %w", io.EOF):  ClassEOF,
+		op(syscall.ECONNRESET):       ClassReset,
+		op(syscall.EPIPE):            ClassReset,
+		op(syscall.ECONNREFUSED):     ClassRefused,
+		op(os.ErrDeadlineExceeded):   ClassTimeout,
+		errors.New("something else"): ClassOther,
+	}
+
+	for err, expected := range tests {
+		if class := classify(err); class != expected {
+			t.Fatalf("Unexpected class of %v. Expected: %s, Actual: %s", err, expected, class)
+		}
+	}
+}
+
+func TestConnErrorLocalRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	s := newFakeServer(t, 1)
+	events := make(chan Event, 10)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", port, WithEvents(events))
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+	s.conn(t)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type != EventConnError {
+				continue
+			}
+			var ce *ConnError
+			if !errors.As(e.Err, &ce) || ce.Side != LocalSide || ce.Class != ClassRefused {
+				t.Fatalf("Unexpected error. Expected: local refused, Actual: %v", e.Err)
+			}
+			if n := tunnel.Stats().Errors[LocalSide][ClassRefused]; n != 1 {
+				t.Fatalf("Unexpected count of local refused errors. Expected: 1, Actual: %d", n)
+			}
+			return
+		case <-timeout:
+			t.Fatal("Timeout waiting for the connection error")
+		}
+	}
+}
+
+func TestConnErrorLocalEOF(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	s := newFakeServer(t, 1)
+	events := make(chan Event, 10)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, WithEvents(events))
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+	s.conn(t)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type != EventConnError {
+				continue
+			}
+			var ce *ConnError
+			if !errors.As(e.Err, &ce) || ce.Side != LocalSide || ce.Class != ClassEOF {
+				t.Fatalf("Unexpected error. Expected: local eof, Actual: %v", e.Err)
+			}
+			return
+		case <-timeout:
+			t.Fatal("Timeout waiting for the connection error")
+		}
+	}
+}
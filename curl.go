@@ -0,0 +1,47 @@
+package localtunnel
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AsCurl returns a curl command line reproducing the captured request against the
+// tunnel's public URL. A spooled body is sent from its file. Bodies truncated by
+// MaxBodySize are sent as captured.
+func (r *RequestRecord) AsCurl() string {
+	args := []string{"curl"}
+	if r.Method != "" && r.Method != http.MethodGet {
+		args = append(args, "-X", shellQuote(r.Method))
+	}
+	args = append(args, shellQuote("https://"+r.Host+r.URL))
+
+	names := make([]string, 0, len(r.RequestHeader))
+	for name := range r.RequestHeader {
+		// curl sets them from the URL and the body
+		if name == "Host" || name == "Content-Length" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, v := range r.RequestHeader[name] {
+			args = append(args, "-H", shellQuote(name+": "+v))
+		}
+	}
+
+	if r.RequestBodyFile != "" {
+		args = append(args, "--data-binary", shellQuote("@"+r.RequestBodyFile))
+	} else if len(r.RequestBody) > 0 {
+		args = append(args, "--data-binary", shellQuote(string(r.RequestBody)))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
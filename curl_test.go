@@ -0,0 +1,42 @@
+package localtunnel
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAsCurl(t *testing.T) {
+	tests := []struct {
+		r        RequestRecord
+		expected string
+	}{
+		{
+			RequestRecord{Method: "GET", Host: "ltdemo.loca.lt", URL: "/?q=1"},
+			`curl 'https://ltdemo.loca.lt/?q=1'`,
+		},
+		{
+			RequestRecord{
+				Method: "POST",
+				Host:   "ltdemo.loca.lt",
+				URL:    "/hooks",
+				RequestHeader: http.Header{
+					"Content-Type":   {"application/json"},
+					"Content-Length": {"17"},
+					"X-Signature":    {"a", "b"},
+				},
+				RequestBody: []byte(`{"name":"o'neil"}`),
+			},
+			`curl -X 'POST' 'https://ltdemo.loca.lt/hooks' -H 'Content-Type: application/json' -H 'X-Signature: a' -H 'X-Signature: b' --data-binary '{"name":"o'\''neil"}'`,
+		},
+		{
+			RequestRecord{Method: "PUT", Host: "ltdemo.loca.lt", URL: "/upload", RequestBodyFile: "/tmp/lt-1.req"},
+			`curl -X 'PUT' 'https://ltdemo.loca.lt/upload' --data-binary '@/tmp/lt-1.req'`,
+		},
+	}
+
+	for _, test := range tests {
+		if actual := test.r.AsCurl(); actual != test.expected {
+			t.Fatalf("Unexpected curl command. Expected: %s, Actual: %s", test.expected, actual)
+		}
+	}
+}
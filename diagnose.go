@@ -0,0 +1,138 @@
+package localtunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A Check is the outcome of testing one hop of a tunnel.
+type Check struct {
+	Name     string
+	Err      error
+	Skipped  bool // not run because a hop it depends on is broken
+	Duration time.Duration
+}
+
+// OK reports whether the check ran and passed.
+func (c Check) OK() bool { return !c.Skipped && c.Err == nil }
+
+// Names of the checks run by Diagnose, in order.
+const (
+	CheckLocal    = "local server"
+	CheckRegister = "registration"
+	CheckDNS      = "dns"
+	CheckRemote   = "remote connections"
+	CheckRequest  = "end-to-end request"
+)
+
+// Diagnose tests every hop of the tunnel, in order: the local server, the registration
+// on the localtunnel server, the DNS of the tunnel, the connections to the remote server
+// and a request going all the way through the tunnel. A tunnel not open yet is opened
+// for the diagnosis and closed afterwards.
+func (t *Tunnel) Diagnose(ctx context.Context) []Check {
+	d := &diagnosis{}
+	forwards := t.streams == nil || t.proxy
+
+	local := d.run(CheckLocal, !forwards, func() error {
+		c, err := t.dialer().DialContext(ctx, "tcp", net.JoinHostPort(t.LocalHost(), strconv.Itoa(t.LocalPort())))
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	})
+
+	register := d.run(CheckRegister, false, func() error {
+		if closeCh := t.closing(); closeCh == nil || !isOpen(closeCh) {
+			err := t.OpenContext(ctx)
+			if err != nil {
+				return err
+			}
+			d.close = t.Close
+		}
+
+		// the tunnel closes itself as soon as the local server is unreachable
+		d.remote = net.JoinHostPort(t.RemoteHost(), strconv.Itoa(t.RemotePort()))
+		d.url = t.URL()
+		return nil
+	})
+	if d.close != nil {
+		defer d.close()
+	}
+
+	dns := d.run(CheckDNS, !register, func() error {
+		u, err := url.Parse(d.url)
+		if err != nil {
+			return err
+		}
+		remoteHost, _, _ := net.SplitHostPort(d.remote)
+		for _, host := range []string{remoteHost, u.Hostname()} {
+			_, err := t.lookupHost(ctx, host)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	remote := d.run(CheckRemote, !register, func() error {
+		c, err := t.dialer().DialContext(ctx, "tcp", d.remote)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	})
+
+	d.run(CheckRequest, !(local && dns && remote && forwards), func() error {
+		return t.roundTrip(ctx)
+	})
+
+	return d.checks
+}
+
+type diagnosis struct {
+	checks []Check
+	close  func()
+	remote string
+	url    string
+}
+
+// run runs the check unless skipped, reporting whether it passed.
+func (d *diagnosis) run(name string, skip bool, check func() error) bool {
+	c := Check{Name: name, Skipped: skip}
+	if !skip {
+		start := time.Now()
+		c.Err = check()
+		c.Duration = time.Since(start)
+	}
+	d.checks = append(d.checks, c)
+	return c.OK()
+}
+
+// roundTrip sends a request to the tunnel's URL and checks it went through the tunnel.
+func (t *Tunnel) roundTrip(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Bypass-Tunnel-Reminder", "1")
+
+	before := t.Stats().BytesIn
+	resp, err := httpClient(t.withResolver(ctx)).Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if t.Stats().BytesIn == before {
+		return fmt.Errorf("localtunnel: request answered with %s without reaching the tunnel", resp.Status)
+	}
+	return nil
+}
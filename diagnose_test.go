@@ -0,0 +1,69 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	// a port nobody listens on
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", port)
+	checks := tunnel.Diagnose(context.Background())
+
+	expected := []struct {
+		name    string
+		ok      bool
+		skipped bool
+	}{
+		{CheckLocal, false, false},
+		{CheckRegister, true, false},
+		{CheckDNS, false, false}, // DNS is not asserted, *.loca.lt may not resolve here
+		{CheckRemote, true, false},
+		{CheckRequest, false, true},
+	}
+
+	if len(checks) != len(expected) {
+		t.Fatalf("Unexpected number of checks. Expected: %d, Actual: %d", len(expected), len(checks))
+	}
+	for i, e := range expected {
+		c := checks[i]
+		if c.Name != e.name {
+			t.Fatalf("Unexpected check. Expected: %s, Actual: %s", e.name, c.Name)
+		}
+		if e.name != CheckDNS && (c.OK() != e.ok || c.Skipped != e.skipped) {
+			t.Fatalf("%s: unexpected outcome. Expected ok: %v, skipped: %v. Actual: %+v", c.Name, e.ok, e.skipped, c)
+		}
+	}
+
+	if tunnel.URL() != "" {
+		t.Fatal("The tunnel opened for the diagnosis should be closed")
+	}
+}
+
+func TestDiagnoseRegistrationFailure(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+
+	checks := NewClient(s.URL).NewStreamTunnel().Diagnose(context.Background())
+	for _, c := range checks {
+		switch c.Name {
+		case CheckRegister:
+			if c.Err == nil {
+				t.Fatal("Registration should fail")
+			}
+		default:
+			if !c.Skipped {
+				t.Fatalf("%s should be skipped", c.Name)
+			}
+		}
+	}
+}
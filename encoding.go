@@ -0,0 +1,57 @@
+package localtunnel
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithIdentityEncoding asks the local server for uncompressed responses, with
+// Accept-Encoding: identity, so the middlewares inspecting the bodies, such as
+// captures, always see them in clear. The gzip and deflate responses of local
+// servers compressing anyway are decoded. Visitors get the responses uncompressed,
+// which every HTTP client accepts. It implies WithHTTPProxy.
+func WithIdentityEncoding() Option {
+	return func(t *Tunnel) {
+		t.proxy = true
+		t.identityEncoding = true
+	}
+}
+
+// decodeResponse replaces the gzip or deflate body of res with its decoded content.
+// Other encodings are left as they are.
+func decodeResponse(res *http.Response) error {
+	var body io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(res.Body)
+	case "deflate":
+		body, err = zlib.NewReader(res.Body)
+	default:
+		return nil
+	}
+	if err == io.EOF {
+		// empty body, e.g. of a HEAD request
+		body, err = strings.NewReader(""), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	res.Body = decodedBody{body, res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	return nil
+}
+
+// decodedBody reads the decoded content of a response body, closing the body.
+type decodedBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b decodedBody) Close() error { return b.body.Close() }
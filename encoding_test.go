@@ -0,0 +1,52 @@
+package localtunnel
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentityEncoding(t *testing.T) {
+	var accepted string
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+
+		// compressing whatever the request accepts
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("hello"))
+		gz.Close()
+	}), WithIdentityEncoding())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	w := serve(h, req)
+
+	if accepted != "identity" {
+		t.Fatalf("Unexpected Accept-Encoding. Expected: identity, Actual: %s", accepted)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("Unexpected response. Expected: hello, Actual: %d %q", w.Code, w.Body.String())
+	}
+	if ce := w.Header().Get("Content-Encoding"); ce != "" {
+		t.Fatalf("Unexpected Content-Encoding. Expected none, Actual: %s", ce)
+	}
+
+	w = serve(h, httptest.NewRequest("HEAD", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status of an empty body. Expected: %d, Actual: %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestIdentityEncodingUnknown(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte("brotli"))
+	}), WithIdentityEncoding())
+
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "brotli" {
+		t.Fatalf("Unknown encodings should be left as they are. Actual: %s %q", w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
@@ -0,0 +1,65 @@
+package localtunnel
+
+import "time"
+
+// An EventType tells what an Event reports.
+type EventType string
+
+const (
+	// EventRateLimited is emitted when the server rejects the registration with 429
+	// Too Many Requests and the tunnel waits before trying again.
+	EventRateLimited EventType = "rate_limited"
+
+	// EventRegistered is emitted by Open once the server assigned the tunnel's URL,
+	// before its Target connections are established.
+	EventRegistered EventType = "registered"
+
+	// EventConnected is emitted each time one of the connections opened by Open is
+	// established, Conns of Target being up.
+	EventConnected EventType = "connected"
+)
+
+// An Event reports a change in the life of a tunnel.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Retry is how long the tunnel waits before trying again.
+	Retry time.Duration
+
+	// Conns is the number of connections in the pool, short of the Target size.
+	Conns  int
+	Target int
+
+	// URL is the public URL registered, or traffic was switched to.
+	URL string
+
+	// Path is the path of a webhook request.
+	Path string
+
+	// Err is why the tunnel was closed, as returned by Tunnel.Err, or the error of a
+	// webhook verification, a failed connection, a recovered panic or a failed
+	// rotation.
+	Err error
+
+	// Client is the IP address of the visitor banned.
+	Client string
+}
+
+// WithEvents sends the tunnel's events to ch. Events are dropped when ch is not ready
+// to receive them, so the tunnel is never blocked by a slow reader.
+func WithEvents(ch chan<- Event) Option {
+	return func(t *Tunnel) { t.events = ch }
+}
+
+func (t *Tunnel) emit(e Event) {
+	if t.events == nil {
+		return
+	}
+
+	e.Time = t.now()
+	select {
+	case t.events <- e:
+	default:
+	}
+}
@@ -0,0 +1,65 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"strconv"
+)
+
+// EventClosed is emitted once the tunnel is closed, Err telling why.
+const EventClosed EventType = "closed"
+
+// Expose opens a tunnel to the local server at addr, given as host:port or :port for
+// localhost, and returns it once registered. It is the context-first entry point of
+// the package: the tunnel is set up by opts, including the client by WithClient and
+// the subdomain by WithSubdomain, reports what happens to it through WithEvents, and
+// is closed once ctx is done, its Err being ctx.Err() then.
+//
+// NewTunnel and Open remain, and are equivalent to Expose with a context never done:
+//
+//	tunnel := c.NewTunnel(host, port, opts...)
+//	err := tunnel.OpenAs(subdomain)
+//
+//	tunnel, err := localtunnel.Expose(context.Background(), "host:port",
+//		append(opts, localtunnel.WithClient(c), localtunnel.WithSubdomain(subdomain))...)
+func Expose(ctx context.Context, addr string, opts ...Option) (*Tunnel, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return nil, &net.AddrError{Err: "invalid port", Addr: addr}
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
+	t := newTunnel(DefaultClient, host, port, opts)
+	err = t.OpenAsContext(ctx, t.requestedSubdomain)
+	if err != nil {
+		return nil, err
+	}
+
+	closeCh := t.closing()
+	go func() {
+		select {
+		case <-ctx.Done():
+			t.close(ctx.Err())
+		case <-closeCh:
+		}
+	}()
+	return t, nil
+}
+
+// WithClient registers the tunnel with c, instead of DefaultClient, when opened by
+// Expose.
+func WithClient(c *Client) Option {
+	return func(t *Tunnel) { t.c = c }
+}
+
+// WithSubdomain requests subdomain for the tunnel opened by Expose, instead of a
+// random one.
+func WithSubdomain(subdomain string) Option {
+	return func(t *Tunnel) { t.requestedSubdomain = subdomain }
+}
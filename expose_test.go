@@ -0,0 +1,56 @@
+package localtunnel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpose(t *testing.T) {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	events := make(chan Event, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := strings.TrimPrefix(local.URL, "http://")
+	tunnel, err := Expose(ctx, addr, WithClient(NewClient(s.URL)), WithSubdomain("ltdemo"), WithEvents(events))
+	if err != nil {
+		t.Fatalf("Cannot expose the local server: %s", err)
+	}
+	if tunnel.URL() != "https://ltdemo.loca.lt" || tunnel.LocalPort() != getServerPort(t, local) {
+		t.Fatalf("Unexpected tunnel. Expected: https://ltdemo.loca.lt, Actual: %s to port %d", tunnel.URL(), tunnel.LocalPort())
+	}
+	s.conn(t)
+
+	cancel()
+	select {
+	case <-tunnel.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tunnel should be closed once its context is done")
+	}
+	if tunnel.Err() != context.Canceled {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", context.Canceled, tunnel.Err())
+	}
+
+	var types []EventType
+	for len(events) > 0 {
+		types = append(types, (<-events).Type)
+	}
+	if len(types) == 0 || types[0] != EventRegistered || types[len(types)-1] != EventClosed {
+		t.Fatalf("Unexpected events. Expected: registered first and closed last, Actual: %v", types)
+	}
+}
+
+func TestExposeInvalidAddress(t *testing.T) {
+	for _, addr := range []string{"8000", "localhost:http"} {
+		if _, err := Expose(context.Background(), addr); err == nil {
+			t.Fatalf("Exposing %q should fail", addr)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Requests reach the tunnel from the relay, so their RemoteAddr is the relay's address.
+// The visitor's address is only known from the headers added by the relay, if any.
+//
+// The relay appends the visitor's address to X-Forwarded-For or Forwarded, after the
+// entries sent by the visitor, which anybody can forge. So only the last entry is
+// trusted, or the one before the entries appended by the proxies counted by
+// WithTrustedProxies, when the relay is itself behind proxies. X-Real-IP is only
+// used when neither header is sent and there are trusted proxies, which replace the
+// visitor's one, as otherwise the visitor may have sent it.
+
+// WithTrustedProxies tells how many proxies stand between the visitor and the relay of
+// the localtunnel server, each appending the address it received the request from to
+// X-Forwarded-For or Forwarded. The visitor's address is then the entry appended by
+// the first of them, instead of the one appended by the relay.
+func WithTrustedProxies(n int) Option {
+	return func(t *Tunnel) { t.trustedProxies = n }
+}
+
+type clientIPKey struct{}
+
+// withClientAddr sets the RemoteAddr of the requests to the visitor's address when
+// the relay tells it, so the middlewares see the real visitor.
+func (t *Tunnel) withClientAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := originIP(r.Header, t.trustedProxies); ip != "" {
+			r.RemoteAddr = net.JoinHostPort(ip, "0")
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the visitor's IP, or nil if the relay did not tell it.
+func clientIP(r *http.Request) net.IP {
+	ip, _ := r.Context().Value(clientIPKey{}).(string)
+	return net.ParseIP(ip)
+}
+
+// forwardClient sets the X-Forwarded-For, X-Real-IP and Forwarded headers of a request
+// proxied to the local server from the visitor's address found by withClientAddr.
+// The relay's own address is never reported as the client.
+func forwardClient(r *http.Request) {
+	ip := clientIP(r)
+	if ip == nil {
+		return
+	}
+
+	if r.Header.Get("X-Forwarded-For") == "" {
+		r.Header.Set("X-Forwarded-For", ip.String())
+	}
+	r.Header.Set("X-Real-IP", ip.String())
+
+	if r.Header.Get("Forwarded") == "" {
+		node := ip.String()
+		if ip.To4() == nil {
+			node = `"[` + node + `]"`
+		}
+		proto := r.Header.Get("X-Forwarded-Proto")
+		if proto == "" {
+			proto = "https"
+		}
+		r.Header.Set("Forwarded", "for="+node+";host="+quoteForwarded(r.Host)+";proto="+proto)
+	}
+}
+
+// originIP returns the visitor's IP reported by the relay in the X-Forwarded-For,
+// Forwarded or, behind trusted proxies, X-Real-IP headers, skipping the entries of the
+// given number of trusted proxies, or "" if there is none.
+func originIP(h http.Header, proxies int) string {
+	if xff := h.Values("X-Forwarded-For"); len(xff) > 0 {
+		entries := strings.Split(strings.Join(xff, ","), ",")
+		return parseIP(strings.TrimSpace(trustedEntry(entries, proxies)))
+	}
+
+	if f := h.Values("Forwarded"); len(f) > 0 {
+		elements := strings.Split(strings.Join(f, ","), ",")
+		for _, pair := range strings.Split(trustedEntry(elements, proxies), ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				return parseIP(strings.Trim(kv[1], `"`))
+			}
+		}
+		return ""
+	}
+
+	if proxies > 0 {
+		return parseIP(strings.TrimSpace(h.Get("X-Real-IP")))
+	}
+	return ""
+}
+
+// trustedEntry returns the entry appended by the first trusted hop, counting from the
+// end, or "" when there are fewer entries than hops.
+func trustedEntry(entries []string, proxies int) string {
+	i := len(entries) - 1 - proxies
+	if i < 0 {
+		return ""
+	}
+	return entries[i]
+}
+
+// parseIP returns the IP of an address, with or without port, or "" if it is not an IP.
+func parseIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func quoteForwarded(s string) string {
+	if strings.ContainsAny(s, ":[]") {
+		return `"` + s + `"`
+	}
+	return s
+}
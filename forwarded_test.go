@@ -0,0 +1,98 @@
+package localtunnel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginIP(t *testing.T) {
+	tests := []struct {
+		header  http.Header
+		proxies int
+		ip      string
+	}{
+		{http.Header{}, 0, ""},
+		// X-Real-IP may be sent by the visitor, unless a trusted proxy replaced it
+		{http.Header{"X-Real-Ip": {"203.0.113.7"}}, 0, ""},
+		{http.Header{"X-Real-Ip": {"203.0.113.7"}}, 1, "203.0.113.7"},
+		{http.Header{"X-Forwarded-For": {"203.0.113.7"}}, 0, "203.0.113.7"},
+		// the leftmost entries are sent by the visitor, only the last one is trusted
+		{http.Header{"X-Forwarded-For": {"198.51.100.9, 203.0.113.7"}}, 0, "203.0.113.7"},
+		{http.Header{"X-Forwarded-For": {"198.51.100.9", "203.0.113.7"}}, 0, "203.0.113.7"},
+		{http.Header{"X-Forwarded-For": {"198.51.100.9, 203.0.113.7, 10.0.0.1"}}, 1, "203.0.113.7"},
+		{http.Header{"X-Forwarded-For": {"10.0.0.1"}}, 1, ""},
+		{http.Header{"X-Forwarded-For": {"unknown"}, "X-Real-Ip": {"203.0.113.7"}}, 0, ""},
+		{http.Header{"Forwarded": {`for=198.51.100.9, for=192.0.2.60;proto=http`}}, 0, "192.0.2.60"},
+		{http.Header{"Forwarded": {`For="[2001:db8::1]:4711"`}}, 0, "2001:db8::1"},
+		{http.Header{"Forwarded": {`for=_hidden`}, "X-Forwarded-For": {"198.51.100.2:1234"}}, 0, "198.51.100.2"},
+	}
+
+	for _, test := range tests {
+		if ip := originIP(test.header, test.proxies); ip != test.ip {
+			t.Fatalf("%v, %d proxies: unexpected IP. Expected: %q, Actual: %q", test.header, test.proxies, test.ip, ip)
+		}
+	}
+}
+
+func TestForwardClient(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"xff":       r.Header.Get("X-Forwarded-For"),
+			"real":      r.Header.Get("X-Real-IP"),
+			"forwarded": r.Header.Get("Forwarded"),
+		})
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "ltdemo.loca.lt"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	var got map[string]string
+	json.NewDecoder(serve(h, req).Body).Decode(&got)
+	if got["xff"] != "203.0.113.7" {
+		t.Fatalf("Relay address should not be appended. Actual: %s", got["xff"])
+	}
+	if got["real"] != "203.0.113.7" {
+		t.Fatalf("Unexpected X-Real-IP. Expected: 203.0.113.7, Actual: %s", got["real"])
+	}
+	if got["forwarded"] != "for=203.0.113.7;host=ltdemo.loca.lt;proto=https" {
+		t.Fatalf("Unexpected Forwarded: %s", got["forwarded"])
+	}
+
+	got = nil
+	json.NewDecoder(serve(h, httptest.NewRequest("GET", "/", nil)).Body).Decode(&got)
+	if got["xff"] != "" || got["real"] != "" || got["forwarded"] != "" {
+		t.Fatalf("Relay address should not be reported as the client: %v", got)
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	var addr string
+	h := NewTunnel("127.0.0.1", 8000).withClientAddr(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr = r.RemoteAddr
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Real-IP", "203.0.113.7")
+	serve(h, req)
+	if addr != req.RemoteAddr {
+		t.Fatalf("X-Real-IP sent by the visitor should be ignored. Expected: %s, Actual: %s", req.RemoteAddr, addr)
+	}
+
+	behind := NewTunnel("127.0.0.1", 8000, WithTrustedProxies(1)).withClientAddr(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr = r.RemoteAddr
+	}))
+	serve(behind, req)
+	if addr != "203.0.113.7:0" {
+		t.Fatalf("Unexpected remote address behind a trusted proxy. Expected: 203.0.113.7:0, Actual: %s", addr)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.9, 203.0.113.7")
+	serve(h, req)
+	if addr != "203.0.113.7:0" {
+		t.Fatalf("Spoofed address should be ignored. Expected: 203.0.113.7:0, Actual: %s", addr)
+	}
+}
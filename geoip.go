@@ -0,0 +1,340 @@
+package localtunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidGeoIP is returned when reading a file which is not a MaxMind DB.
+var ErrInvalidGeoIP = errors.New("localtunnel: invalid MaxMind DB file")
+
+// metadataMarker starts the metadata section of a MaxMind DB file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// A GeoIP database maps IP addresses to countries. It reads the MaxMind DB (MMDB)
+// files of the GeoLite2 Country and GeoIP2 Country databases, or any database
+// compatible with them.
+type GeoIP struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+}
+
+// OpenGeoIP reads the MaxMind DB file at path.
+func OpenGeoIP(path string) (*GeoIP, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewGeoIP(b)
+}
+
+// NewGeoIP reads a MaxMind DB from its content.
+func NewGeoIP(b []byte) (*GeoIP, error) {
+	i := bytes.LastIndex(b, metadataMarker)
+	if i < 0 {
+		return nil, ErrInvalidGeoIP
+	}
+
+	meta := mmdbDecoder{b: b[i+len(metadataMarker):]}
+	v, _, err := meta.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidGeoIP
+	}
+
+	db := &GeoIP{}
+	for key, dst := range map[string]*uint{"node_count": &db.nodeCount, "record_size": &db.recordSize, "ip_version": &db.ipVersion} {
+		n, ok := m[key].(uint64)
+		if !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidGeoIP, key)
+		}
+		*dst = uint(n)
+	}
+	if db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidGeoIP, db.recordSize)
+	}
+
+	// the search tree is followed by 16 zero bytes, then by the data section
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+16 > uint(i) {
+		return nil, ErrInvalidGeoIP
+	}
+	db.tree = b[:treeSize]
+	db.data = b[treeSize+16 : i]
+	return db, nil
+}
+
+// Country returns the ISO 3166-1 code of the country of ip, or "" when the database
+// does not know it.
+func (db *GeoIP) Country(ip net.IP) (string, error) {
+	v, err := db.lookup(ip)
+	if err != nil || v == nil {
+		return "", err
+	}
+
+	m, _ := v.(map[string]interface{})
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := m[key].(map[string]interface{}); ok {
+			if code, ok := c["iso_code"].(string); ok {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// lookup returns the record of ip, or nil when there is none.
+func (db *GeoIP) lookup(ip net.IP) (interface{}, error) {
+	bits := ip.To16()
+	if bits == nil {
+		return nil, fmt.Errorf("localtunnel: invalid IP %s", ip)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if db.ipVersion == 6 {
+			// IPv4 addresses are stored as ::a.b.c.d in IPv6 databases
+			bits = append(make([]byte, 12), ip4...)
+		}
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	node := uint(0)
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = db.record(node, bit)
+	}
+
+	if node == db.nodeCount {
+		return nil, nil
+	}
+	if node < db.nodeCount {
+		return nil, ErrInvalidGeoIP
+	}
+
+	d := mmdbDecoder{b: db.data}
+	v, _, err := d.decode(node - db.nodeCount - 16)
+	return v, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *GeoIP) record(node, bit uint) uint {
+	b := db.tree[node*db.recordSize/4:]
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	}
+	return uint(binary.BigEndian.Uint32(b[bit*4:]))
+}
+
+// mmdbDecoder decodes the values of a MaxMind DB data section.
+type mmdbDecoder struct {
+	b []byte
+}
+
+// Types of the MaxMind DB values.
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// decode decodes the value at offset, returning it with the offset following it.
+// Maps decode as map[string]interface{}, arrays as []interface{}, unsigned integers
+// as uint64 and floats as float64.
+func (d *mmdbDecoder) decode(offset uint) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == mmdbPointer {
+		v, _, err := d.decode(size)
+		return v, offset, err
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var k, v interface{}
+			k, offset, err = d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			v, offset, err = d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, ErrInvalidGeoIP
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+
+	case mmdbArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			a[i], offset, err = d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.b)) {
+		return nil, 0, ErrInvalidGeoIP
+	}
+	b := d.b[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbBytes, mmdbUint128:
+		return append([]byte(nil), b...), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, ErrInvalidGeoIP
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, ErrInvalidGeoIP
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if typ == mmdbInt32 {
+			return int32(uint32(n)), offset, nil
+		}
+		return n, offset, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown type %d", ErrInvalidGeoIP, typ)
+}
+
+// control decodes the control byte at offset and the bytes extending it, returning
+// the type and size of the value, or the target of a pointer as size, with the offset
+// of the payload.
+func (d *mmdbDecoder) control(offset uint) (typ, size, next uint, err error) {
+	read := func(n uint) []byte {
+		if err != nil || offset+n > uint(len(d.b)) {
+			err = ErrInvalidGeoIP
+			return make([]byte, n)
+		}
+		b := d.b[offset : offset+n]
+		offset += n
+		return b
+	}
+	num := func(b []byte) uint {
+		var n uint
+		for _, c := range b {
+			n = n<<8 | uint(c)
+		}
+		return n
+	}
+
+	ctrl := read(1)[0]
+	typ = uint(ctrl >> 5)
+	if typ == mmdbPointer {
+		ss, v := uint(ctrl>>3)&3, uint(ctrl&7)
+		switch ss {
+		case 0:
+			size = v<<8 | num(read(1))
+		case 1:
+			size = (v<<16 | num(read(2))) + 2048
+		case 2:
+			size = (v<<24 | num(read(3))) + 526336
+		default:
+			size = num(read(4))
+		}
+		return typ, size, offset, err
+	}
+
+	if typ == mmdbExtended {
+		typ = 7 + uint(read(1)[0])
+	}
+
+	size = uint(ctrl & 0x1f)
+	switch size {
+	case 29:
+		size = 29 + num(read(1))
+	case 30:
+		size = 285 + num(read(2))
+	case 31:
+		size = 65821 + num(read(3))
+	}
+	return typ, size, offset, err
+}
+
+// WithAllowedCountries rejects with 403 Forbidden the requests of visitors whose
+// country, looked up in db, is none of countries, given as ISO 3166-1 codes such as
+// "BR". Requests whose visitor or country is unknown are rejected as well.
+// It implies WithHTTPProxy.
+func WithAllowedCountries(db *GeoIP, countries ...string) Option {
+	allowed := make(map[string]bool, len(countries))
+	for _, c := range countries {
+		if c = strings.TrimSpace(c); c != "" {
+			allowed[strings.ToUpper(c)] = true
+		}
+	}
+
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				country := ""
+				if ip := clientIP(r); ip != nil {
+					country, _ = db.Country(ip)
+				}
+
+				if !allowed[country] {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package localtunnel
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mmdbNode is a node of the search tree built by writeMMDB. Its records are either
+// the index of another node, or ^i for the i-th data value, or 0 when empty.
+type mmdbNode [2]int
+
+// writeMMDB returns a MaxMind DB with 24-bit records mapping the IPv6 networks to
+// their country.
+func writeMMDB(t *testing.T, networks map[string]string) []byte {
+	nodes := []mmdbNode{{}}
+	var data bytes.Buffer
+	offsets := map[string]int{}
+
+	for cidr, country := range networks {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := n.IP.To16()
+		ones, bits := n.Mask.Size()
+		ones += 128 - bits
+
+		if _, ok := offsets[country]; !ok {
+			offsets[country] = data.Len()
+			writeMMDBValue(&data, map[string]interface{}{
+				"country": map[string]interface{}{"iso_code": country},
+			})
+		}
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = ^offsets[country]
+				break
+			}
+			if nodes[node][bit] <= 0 {
+				nodes = append(nodes, mmdbNode{})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var b bytes.Buffer
+	count := len(nodes)
+	for _, n := range nodes {
+		for _, r := range n {
+			v := count
+			if r > 0 {
+				v = r
+			} else if r < 0 {
+				v = count + 16 + ^r
+			}
+			b.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	b.Write(make([]byte, 16))
+	b.Write(data.Bytes())
+	b.Write(metadataMarker)
+	writeMMDBValue(&b, map[string]interface{}{
+		"node_count":                  uint64(count),
+		"record_size":                 uint64(24),
+		"ip_version":                  uint64(6),
+		"database_type":               "Test-Country",
+		"binary_format_major_version": uint64(2),
+	})
+	return b.Bytes()
+}
+
+func writeMMDBValue(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		b.WriteByte(mmdbString<<5 | byte(len(v)))
+		b.WriteString(v)
+	case uint64:
+		var n []byte
+		for ; v > 0; v >>= 8 {
+			n = append([]byte{byte(v)}, n...)
+		}
+		b.WriteByte(mmdbUint32<<5 | byte(len(n)))
+		b.Write(n)
+	case map[string]interface{}:
+		b.WriteByte(mmdbMap<<5 | byte(len(v)))
+		for k, e := range v {
+			writeMMDBValue(b, k)
+			writeMMDBValue(b, e)
+		}
+	}
+}
+
+func TestGeoIPCountry(t *testing.T) {
+	db, err := NewGeoIP(writeMMDB(t, map[string]string{
+		"::1.2.0.0/112": "BR",
+		"::8.8.8.0/120": "US",
+		"2001:db8::/32": "DE",
+	}))
+	if err != nil {
+		t.Fatalf("Cannot read database: %s", err)
+	}
+
+	for ip, expected := range map[string]string{
+		"1.2.3.4":     "BR",
+		"8.8.8.8":     "US",
+		"2001:db8::1": "DE",
+		"9.9.9.9":     "",
+		"2001:db9::1": "",
+	} {
+		country, err := db.Country(net.ParseIP(ip))
+		if err != nil {
+			t.Fatalf("Cannot look up %s: %s", ip, err)
+		}
+		if country != expected {
+			t.Fatalf("Unexpected country of %s. Expected: %q, Actual: %q", ip, expected, country)
+		}
+	}
+
+	if _, err := NewGeoIP([]byte("not a database")); err != ErrInvalidGeoIP {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", ErrInvalidGeoIP, err)
+	}
+}
+
+func TestAllowedCountries(t *testing.T) {
+	db, err := NewGeoIP(writeMMDB(t, map[string]string{"::1.2.0.0/112": "BR", "::8.8.8.0/120": "US"}))
+	if err != nil {
+		t.Fatalf("Cannot read database: %s", err)
+	}
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithAllowedCountries(db, "br", " CA"))
+
+	for ip, expected := range map[string]int{
+		"1.2.3.4": http.StatusOK,
+		"8.8.8.8": http.StatusForbidden,
+		"9.9.9.9": http.StatusForbidden,
+		"":        http.StatusForbidden,
+		// the visitor sent the leftmost entry, the relay appended the real address
+		"1.2.3.4, 8.8.8.8": http.StatusForbidden,
+		"8.8.8.8, 1.2.3.4": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/", strings.NewReader(""))
+		if ip != "" {
+			req.Header.Set("X-Forwarded-For", ip)
+		}
+		if w := serve(h, req); w.Code != expected {
+			t.Fatalf("Unexpected status for %q. Expected: %d, Actual: %d", ip, expected, w.Code)
+		}
+	}
+}
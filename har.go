@@ -0,0 +1,247 @@
+package localtunnel
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// WriteHAR writes the captured requests to w in the HTTP Archive (HAR) 1.2 format.
+func (c *Capture) WriteHAR(w io.Writer) error {
+	records, err := c.Records()
+	if err != nil {
+		return err
+	}
+	return WriteHAR(w, records)
+}
+
+// WriteHAR writes records to w in the HTTP Archive (HAR) 1.2 format.
+func WriteHAR(w io.Writer, records []RequestRecord) error {
+	entries := make([]harEntry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, newHAREntry(r))
+	}
+
+	var har struct {
+		Log struct {
+			Version string     `json:"version"`
+			Creator harCreator `json:"creator"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	har.Log.Version = "1.2"
+	har.Log.Creator = harCreator{Name: "localtunnel", Version: "1"}
+	har.Log.Entries = entries
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(har)
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harPair   `json:"cookies"`
+	Headers     []harPair   `json:"headers"`
+	QueryString []harPair   `json:"queryString"`
+	PostData    *harContent `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int64       `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Cookies     []harPair  `json:"cookies"`
+	Headers     []harPair  `json:"headers"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int64      `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func newHAREntry(r RequestRecord) harEntry {
+	u := url.URL{Scheme: "https", Host: r.Host}
+	if ref, err := url.ParseRequestURI(r.URL); err == nil {
+		u.Path, u.RawPath, u.RawQuery = ref.Path, ref.RawPath, ref.RawQuery
+	}
+
+	var query []harPair
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			query = append(query, harPair{k, v})
+		}
+	}
+	sortPairs(query)
+
+	e := harEntry{
+		StartedDateTime: r.Time.Format(time.RFC3339Nano),
+		Time:            millis(r.Duration),
+		Request: harRequest{
+			Method:      r.Method,
+			URL:         u.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     []harPair{},
+			Headers:     harHeaders(r.RequestHeader),
+			QueryString: append([]harPair{}, query...),
+			HeadersSize: -1,
+			BodySize:    r.RequestSize,
+		},
+		Response: harResponse{
+			Status:      r.Status,
+			StatusText:  http.StatusText(r.Status),
+			HTTPVersion: r.Proto,
+			Cookies:     []harPair{},
+			Headers:     harHeaders(r.ResponseHeader),
+			Content:     newHARContent(r.ResponseSize, contentType(r.ResponseHeader), readBody(r.ResponseBody, r.ResponseBodyFile)),
+			RedirectURL: r.ResponseHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    r.ResponseSize,
+		},
+		Timings: harTimings{Wait: millis(r.Duration)},
+	}
+
+	if r.RequestSize > 0 {
+		c := newHARContent(r.RequestSize, contentType(r.RequestHeader), readBody(r.RequestBody, r.RequestBodyFile))
+		e.Request.PostData = &c
+	}
+	return e
+}
+
+func newHARContent(size int64, mimeType string, body []byte) harContent {
+	c := harContent{Size: size, MimeType: mimeType}
+	if utf8.Valid(body) {
+		c.Text = string(body)
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+func harHeaders(h http.Header) []harPair {
+	pairs := []harPair{}
+	for k, vs := range h {
+		for _, v := range vs {
+			pairs = append(pairs, harPair{k, v})
+		}
+	}
+	sortPairs(pairs)
+	return pairs
+}
+
+func sortPairs(pairs []harPair) {
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ReadHAR reads the entries of an HTTP Archive (HAR) as RequestRecords, e.g. to
+// replay them with WithPlayback.
+func ReadHAR(r io.Reader) ([]RequestRecord, error) {
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+
+	err := json.NewDecoder(r).Decode(&har)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]RequestRecord, 0, len(har.Log.Entries))
+	for i, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		started, _ := time.Parse(time.RFC3339Nano, e.StartedDateTime)
+		record := RequestRecord{
+			ID:             int64(i + 1),
+			Time:           started,
+			Duration:       time.Duration(e.Time * float64(time.Millisecond)),
+			Method:         e.Request.Method,
+			Host:           u.Host,
+			URL:            u.RequestURI(),
+			Proto:          e.Request.HTTPVersion,
+			RequestHeader:  headerFromHAR(e.Request.Headers),
+			RequestSize:    e.Request.BodySize,
+			Status:         e.Response.Status,
+			ResponseHeader: headerFromHAR(e.Response.Headers),
+			ResponseSize:   e.Response.Content.Size,
+		}
+
+		if e.Request.PostData != nil {
+			record.RequestBody, err = e.Request.PostData.body()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		record.ResponseBody, err = e.Response.Content.body()
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (c *harContent) body() ([]byte, error) {
+	if c.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(c.Text)
+	}
+	return []byte(c.Text), nil
+}
+
+func headerFromHAR(pairs []harPair) http.Header {
+	h := http.Header{}
+	for _, p := range pairs {
+		h.Add(p.Name, p.Value)
+	}
+	return h
+}
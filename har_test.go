@@ -0,0 +1,106 @@
+package localtunnel
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWriteHAR(t *testing.T) {
+	records := []RequestRecord{{
+		ID:             1,
+		Time:           time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC),
+		Duration:       1500 * time.Microsecond,
+		Method:         "POST",
+		Host:           "ltdemo.loca.lt",
+		URL:            "/hooks?source=github",
+		Proto:          "HTTP/1.1",
+		RequestHeader:  http.Header{"Content-Type": {"application/json"}},
+		RequestBody:    []byte(`{"ok":true}`),
+		RequestSize:    11,
+		Status:         200,
+		ResponseHeader: http.Header{"Content-Type": {"application/octet-stream"}},
+		ResponseBody:   []byte{0xff, 0xfe},
+		ResponseSize:   2,
+	}}
+
+	var buf bytes.Buffer
+	err := WriteHAR(&buf, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var har struct {
+		Log struct {
+			Version string
+			Entries []harEntry
+		}
+	}
+	err = json.Unmarshal(buf.Bytes(), &har)
+	if err != nil {
+		t.Fatalf("Invalid HAR: %s", err)
+	}
+
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("Unexpected HAR log: %s", buf.String())
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.URL != "https://ltdemo.loca.lt/hooks?source=github" {
+		t.Fatalf("Unexpected URL: %s", e.Request.URL)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0] != (harPair{"source", "github"}) {
+		t.Fatalf("Unexpected query string: %v", e.Request.QueryString)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"ok":true}` || e.Request.PostData.MimeType != "application/json" {
+		t.Fatalf("Unexpected post data: %v", e.Request.PostData)
+	}
+	if e.Response.Content.Encoding != "base64" || e.Response.Content.Text != "//4=" {
+		t.Fatalf("Binary content should be base64 encoded: %v", e.Response.Content)
+	}
+	if e.Time != 1.5 {
+		t.Fatalf("Unexpected time. Expected: 1.5, Actual: %f", e.Time)
+	}
+}
+
+func TestReadHAR(t *testing.T) {
+	records := []RequestRecord{{
+		Time:           time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC),
+		Method:         "GET",
+		Host:           "ltdemo.loca.lt",
+		URL:            "/logo.png?v=2",
+		Proto:          "HTTP/1.1",
+		RequestHeader:  http.Header{"Accept": {"image/*"}},
+		Status:         200,
+		ResponseHeader: http.Header{"Content-Type": {"image/png"}},
+		ResponseBody:   []byte{0x89, 'P', 'N', 'G', 0xff},
+		ResponseSize:   5,
+	}}
+
+	var buf bytes.Buffer
+	WriteHAR(&buf, records)
+
+	read, err := ReadHAR(&buf)
+	if err != nil {
+		t.Fatalf("Cannot read HAR: %s", err)
+	}
+	if len(read) != 1 {
+		t.Fatalf("Unexpected number of records. Expected: 1, Actual: %d", len(read))
+	}
+
+	r := read[0]
+	if r.Method != "GET" || r.Host != "ltdemo.loca.lt" || r.URL != "/logo.png?v=2" || r.Status != 200 {
+		t.Fatalf("Unexpected record: %s %s %s %d", r.Method, r.Host, r.URL, r.Status)
+	}
+	if !bytes.Equal(r.ResponseBody, records[0].ResponseBody) {
+		t.Fatalf("Unexpected body: %v", r.ResponseBody)
+	}
+	if r.ResponseHeader.Get("Content-Type") != "image/png" || r.RequestHeader.Get("Accept") != "image/*" {
+		t.Fatalf("Unexpected headers: %v %v", r.RequestHeader, r.ResponseHeader)
+	}
+	if !r.Time.Equal(records[0].Time) {
+		t.Fatalf("Unexpected time: %s", r.Time)
+	}
+}
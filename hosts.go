@@ -0,0 +1,53 @@
+package localtunnel
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WithAllowedHosts rejects the requests whose Host header matches none of hosts with
+// 421 Misdirected Request. Hosts may start with "*." to match any subdomain. When no
+// host is given, only the hostname of the tunnel's URL is allowed.
+// It implies WithHTTPProxy.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				allowed := hosts
+				if len(allowed) == 0 {
+					if u, err := url.Parse(t.URL()); err == nil {
+						allowed = []string{u.Hostname()}
+					}
+				}
+
+				if !matchHost(allowed, r.Host) {
+					http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+					return
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+// matchHost reports whether host, with or without port, matches one of the patterns.
+func matchHost(patterns []string, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == host {
+			return true
+		}
+		if strings.HasPrefix(p, "*.") && strings.HasSuffix(host, p[1:]) && len(host) > len(p)-1 {
+			return true
+		}
+	}
+	return false
+}
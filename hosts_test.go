@@ -0,0 +1,53 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedHosts(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithAllowedHosts("ltdemo.loca.lt", "*.example.com"))
+
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"ltdemo.loca.lt", 200},
+		{"LTDEMO.loca.lt:443", 200},
+		{"app.example.com", 200},
+		{"example.com", 421},
+		{"evil.loca.lt", 421},
+		{"ltdemo.loca.lt.evil.com", 421},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = test.host
+		if w := serve(h, req); w.Code != test.status {
+			t.Fatalf("%s: unexpected status. Expected: %d, Actual: %d", test.host, test.status, w.Code)
+		}
+	}
+}
+
+func TestAllowedHostsDefaultsToTunnelHost(t *testing.T) {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", getServerPort(t, local), WithAllowedHosts())
+	err := tunnel.OpenAs("ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	h := tunnel.httpHandler()
+	for host, status := range map[string]int{"ltdemo.loca.lt": 200, "other.loca.lt": 421} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		if w := serve(h, req); w.Code != status {
+			t.Fatalf("%s: unexpected status. Expected: %d, Actual: %d", host, status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package localtunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shutdownTimeout bounds how long closing an HTTP tunnel waits for the requests in
+// flight.
+const shutdownTimeout = 5 * time.Second
+
+// serveHTTP proxies the HTTP requests arriving through the tunnel to the local server
+// until closeCh is closed, then waits up to shutdownTimeout for the requests in flight.
+func (t *Tunnel) serveHTTP(closeCh <-chan struct{}) {
+	s := &http.Server{Handler: t.httpHandler()}
+	var ln net.Listener = &listener{t: t, accept: t.nextStream}
+	if t.tlsConfig != nil {
+		ln = tls.NewListener(ln, t.tlsConfig)
+	}
+	t.spawn(t.workers, func() {
+		s.Serve(ln)
+	})
+	t.spawn(t.workers, func() {
+		<-closeCh
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if s.Shutdown(ctx) != nil {
+			s.Close()
+		}
+	})
+}
+
+// httpHandler returns the handler serving the requests of an HTTP tunnel.
+//
+// httputil.ReverseProxy keeps the semantics of the original request: Expect:
+// 100-continue is forwarded so the local server decides whether the body is sent,
+// chunked bodies are streamed as they arrive, and Connection: upgrade handshakes
+// switch to a raw bidirectional copy once the local server answers 101. GET and HEAD
+// requests are retried once when the local server drops them, see retryTransport.
+func (t *Tunnel) httpHandler() http.Handler {
+	scheme := "http"
+	if t.backendScheme != "" {
+		scheme = t.backendScheme
+	}
+	target := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(t.localHost, strconv.Itoa(t.localPort)),
+	}
+	p := httputil.NewSingleHostReverseProxy(target)
+	director := p.Director
+	p.Director = func(r *http.Request) {
+		director(r)
+		routeBackend(r)
+		if r.TLS != nil {
+			// terminated by WithTLS rather than by the relay
+			r.Header.Set("X-Forwarded-Proto", "https")
+		}
+		forwardClient(r)
+		if t.identityEncoding {
+			r.Header.Set("Accept-Encoding", "identity")
+		}
+	}
+	if t.identityEncoding {
+		p.ModifyResponse = decodeResponse
+	}
+	p.Transport = t.backend
+	if p.Transport == nil {
+		tr := localTransport()
+		if t.resolver != nil {
+			tr.DialContext = t.dialer().DialContext
+		}
+		p.Transport = tr
+	}
+	p.Transport = &retryTransport{next: p.Transport}
+	p.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if t.fallback != nil {
+			t.fallback.ServeHTTP(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	// ReverseProxy appends RemoteAddr to X-Forwarded-For, which is done by
+	// forwardClient instead
+	b := t.balancer()
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = ""
+		if b != nil {
+			r = b.pick(w, r)
+		}
+		p.ServeHTTP(w, r)
+	})
+	for i := len(t.middlewares) - 1; i >= 0; i-- {
+		h = t.middlewares[i](h)
+	}
+	return t.withRecovery(t.withClientAddr(h))
+}
+
+// middleware wraps the handler of an HTTP tunnel.
+type middleware func(http.Handler) http.Handler
+
+// use adds a middleware to the tunnel, switching it to the HTTP proxy mode.
+// Middlewares run in the order they were added.
+func (t *Tunnel) use(m middleware) {
+	t.proxy = true
+	t.middlewares = append(t.middlewares, m)
+}
+
+// localTransport returns the transport used to reach the local server. Unlike
+// http.DefaultTransport, it never goes through an HTTP proxy.
+func localTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	return tr
+}
+
+// removeCookie removes the cookie name from the Cookie headers of r, so the cookies
+// used by the tunnel do not reach the local server. The other cookies are kept as sent.
+func removeCookie(r *http.Request, name string) {
+	lines := r.Header["Cookie"]
+	r.Header.Del("Cookie")
+	for _, line := range lines {
+		var kept []string
+		for _, c := range strings.Split(line, ";") {
+			c = strings.TrimSpace(c)
+			if c != "" && strings.TrimSpace(strings.SplitN(c, "=", 2)[0]) != name {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) > 0 {
+			r.Header.Add("Cookie", strings.Join(kept, "; "))
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package localtunnel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openHTTPTunnel opens an HTTP tunnel to handler and returns a connection on which
+// the fake server sends requests through the tunnel.
+func openHTTPTunnel(t *testing.T, handler http.Handler, opts ...Option) (*Tunnel, net.Conn) {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(handler)
+	t.Cleanup(local.Close)
+
+	opts = append([]Option{WithHTTPProxy()}, opts...)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", getServerPort(t, local), opts...)
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	t.Cleanup(tunnel.Close)
+
+	remote := s.conn(t)
+	remote.SetDeadline(time.Now().Add(5 * time.Second))
+	return tunnel, remote
+}
+
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, b)
+}
+
+// cookieHandler answers the Cookie header received by the local server.
+func cookieHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, strings.Join(r.Header["Cookie"], " | "))
+}
+
+func TestRemoveCookie(t *testing.T) {
+	for _, test := range []struct {
+		cookies  []string
+		expected []string
+	}{
+		{[]string{"lt_session=abc"}, nil},
+		{[]string{"a=1; lt_session=abc; b=2"}, []string{"a=1; b=2"}},
+		{[]string{"lt_session=abc;a=1", "b=2"}, []string{"a=1", "b=2"}},
+		{[]string{"lt_sessions=1; a=2"}, []string{"lt_sessions=1; a=2"}},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header["Cookie"] = test.cookies
+		removeCookie(r, "lt_session")
+		if !reflect.DeepEqual(r.Header["Cookie"], test.expected) {
+			t.Fatalf("Unexpected cookies left of %q. Expected: %q, Actual: %q", test.cookies, test.expected, r.Header["Cookie"])
+		}
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(echoHandler))
+	br := bufio.NewReader(remote)
+
+	for i := 0; i < 2; i++ {
+		fmt.Fprint(remote, "POST /echo HTTP/1.1\r\nHost: demo.loca.lt\r\nContent-Length: 5\r\n\r\nhello")
+
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if string(b) != "POST /echo hello" {
+			t.Fatalf("Unexpected response. Expected: 'POST /echo hello'. Actual: '%s'", b)
+		}
+	}
+}
+
+func TestHTTPProxyChunked(t *testing.T) {
+	var te []string
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		te = r.TransferEncoding
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+		w.(http.Flusher).Flush()
+		w.Write(b)
+	}))
+
+	fmt.Fprint(remote, "POST / HTTP/1.1\r\nHost: demo.loca.lt\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"3\r\nfoo\r\n3\r\nbar\r\n0\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(remote), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "foobarfoobar" {
+		t.Fatalf("Unexpected response. Expected: 'foobarfoobar'. Actual: '%s'", b)
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("Response should be chunked. Actual: %v", resp.TransferEncoding)
+	}
+	if len(te) != 1 || te[0] != "chunked" {
+		t.Fatalf("Request should reach the local server chunked. Actual: %v", te)
+	}
+}
+
+func TestHTTPProxyExpectContinue(t *testing.T) {
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(echoHandler))
+	br := bufio.NewReader(remote)
+
+	fmt.Fprint(remote, "PUT /upload HTTP/1.1\r\nHost: demo.loca.lt\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "HTTP/1.1 100 Continue") {
+		t.Fatalf("Expected 100 Continue before sending the body. Actual: %q", line)
+	}
+	br.ReadString('\n')
+
+	fmt.Fprint(remote, "hello")
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	if string(b) != "PUT /upload hello" {
+		t.Fatalf("Unexpected response. Expected: 'PUT /upload hello'. Actual: '%s'", b)
+	}
+}
+
+func TestHTTPProxyExpectContinueRejected(t *testing.T) {
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "denied", http.StatusUnauthorized)
+	}))
+
+	fmt.Fprint(remote, "PUT /upload HTTP/1.1\r\nHost: demo.loca.lt\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(remote), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Unexpected status. Expected: 401, Actual: %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPProxyUpgrade(t *testing.T) {
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		c, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		fmt.Fprint(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		io.Copy(c, rw)
+	}))
+	br := bufio.NewReader(remote)
+
+	fmt.Fprint(remote, "GET /ws HTTP/1.1\r\nHost: demo.loca.lt\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Unexpected status. Expected: 101, Actual: %d", resp.StatusCode)
+	}
+
+	fmt.Fprint(remote, "ping")
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(br, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ping" {
+		t.Fatalf("Unexpected upgraded data. Expected: 'ping'. Actual: '%s'", b)
+	}
+}
+
+func TestAcceptStreamOnHTTPTunnel(t *testing.T) {
+	tunnel := NewLocalTunnel(8000, WithHTTPProxy())
+	if _, err := tunnel.AcceptStream(); err != ErrNotStreamTunnel {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", ErrNotStreamTunnel, err)
+	}
+}
+
+// tunnelHandler returns the handler of an HTTP tunnel to a local server running
+// handler, which lets middlewares be tested without opening the tunnel.
+func tunnelHandler(t *testing.T, handler http.Handler, opts ...Option) http.Handler {
+	local := httptest.NewServer(handler)
+	t.Cleanup(local.Close)
+
+	opts = append([]Option{WithHTTPProxy()}, opts...)
+	return NewTunnel("127.0.0.1", getServerPort(t, local), opts...).httpHandler()
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// roundTripperFunc is a RoundTripper calling itself.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestLocalBackend(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	var target string
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		target = r.URL.String()
+
+		// the local server of the test only speaks plain HTTP
+		r.URL.Scheme = "http"
+		return http.DefaultTransport.RoundTrip(r)
+	})
+
+	h := NewTunnel("127.0.0.1", getServerPort(t, local), WithLocalBackend("https", rt)).httpHandler()
+	w := serve(h, httptest.NewRequest("POST", "/upload?a=1", strings.NewReader("hello")))
+	if w.Code != http.StatusOK || w.Body.String() != "POST /upload hello" {
+		t.Fatalf("Unexpected response. Expected: 200 POST /upload hello, Actual: %d %s", w.Code, w.Body)
+	}
+
+	expected := fmt.Sprintf("https://127.0.0.1:%d/upload?a=1", getServerPort(t, local))
+	if target != expected {
+		t.Fatalf("Unexpected backend URL. Expected: %s, Actual: %s", expected, target)
+	}
+}
@@ -0,0 +1,212 @@
+//go:build integration
+// +build integration
+
+package localtunnel
+
+// The integration tests run against the reference localtunnel server,
+// https://github.com/localtunnel/server, started in Docker unless LT_SERVER gives
+// the URL of one already running:
+//
+//	go test -tags integration ./...
+//
+// The server routes the requests of a tunnel by the subdomain of their Host, which
+// is set explicitly so no DNS is involved.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	integrationImage = "defunctzombie/localtunnel-server:latest"
+	integrationPort  = "3000"
+)
+
+var integrationServer string
+
+func TestMain(m *testing.M) {
+	integrationServer = os.Getenv("LT_SERVER")
+	if integrationServer != "" {
+		os.Exit(runIntegration(m))
+	}
+
+	// host networking, as the tunnels' connections are dialed on random ports
+	out, err := exec.Command("docker", "run", "-d", "--rm", "--net", "host", integrationImage,
+		"--port", integrationPort, "--domain", "localhost").Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot start the localtunnel server: %s\n", err)
+		os.Exit(1)
+	}
+	container := strings.TrimSpace(string(out))
+	integrationServer = "http://localhost:" + integrationPort
+
+	code := 1
+	if waitForServer(integrationServer, 30*time.Second) {
+		code = runIntegration(m)
+	} else {
+		fmt.Fprintf(os.Stderr, "Timeout waiting for the localtunnel server\n")
+	}
+
+	exec.Command("docker", "stop", container).Run()
+	os.Exit(code)
+}
+
+// runIntegration runs the tests with DefaultClient pointed at the server, so those
+// using it, such as TestSetupLocalTunnel, run against it too.
+func runIntegration(m *testing.M) int {
+	u, err := url.Parse(integrationServer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid LT_SERVER: %s\n", err)
+		return 1
+	}
+
+	client, urls := DefaultClient, ltRegexp
+	defer func() { DefaultClient, ltRegexp, tunnelClient = client, urls, http.DefaultClient }()
+
+	DefaultClient = NewClient(integrationServer)
+	// the tunnels' URLs are subdomains of the server's host, which may not resolve
+	ltRegexp = regexp.MustCompile("^" + regexp.QuoteMeta(u.Scheme) + `://.+\.` + regexp.QuoteMeta(u.Host) + "$")
+	tunnelClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, u.Host)
+		},
+	}}
+	return m.Run()
+}
+
+func waitForServer(endpoint string, timeout time.Duration) bool {
+	c := NewClient(endpoint)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(500 * time.Millisecond) {
+		if _, err := c.ServerStatus(context.Background()); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// getThroughServer sends a request to the tunnel through the server.
+func getThroughServer(t *testing.T, tunnel *Tunnel, method, path string, body []byte) (int, string) {
+	u, err := url.Parse(integrationServer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, integrationServer+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = tunnel.Subdomain() + "." + u.Host
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Cannot connect through the tunnel: %s", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func openIntegrationTunnel(t *testing.T, subdomain string, opts ...Option) *Tunnel {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	t.Cleanup(local.Close)
+
+	tunnel := NewClient(integrationServer).NewTunnel("127.0.0.1", getServerPort(t, local), opts...)
+	err := tunnel.OpenAs(subdomain)
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	t.Cleanup(tunnel.Close)
+	return tunnel
+}
+
+func TestIntegrationRawTunnel(t *testing.T) {
+	tunnel := openIntegrationTunnel(t, "")
+
+	if tunnel.Subdomain() == "" || tunnel.MaxConn() <= 0 {
+		t.Fatalf("Unexpected registration: %s %d", tunnel.Subdomain(), tunnel.MaxConn())
+	}
+
+	// more requests than connections, so they are re-dialed
+	for i := 0; i <= tunnel.MaxConn()*2; i++ {
+		code, body := getThroughServer(t, tunnel, "POST", "/echo", []byte("hello"))
+		if code != http.StatusOK || body != "POST /echo hello" {
+			t.Fatalf("Unexpected response. Expected: 200 POST /echo hello, Actual: %d %s", code, body)
+		}
+	}
+}
+
+func TestIntegrationHTTPTunnel(t *testing.T) {
+	tunnel := openIntegrationTunnel(t, "", WithHTTPProxy())
+
+	code, body := getThroughServer(t, tunnel, "PUT", "/upload", bytes.Repeat([]byte("a"), 1<<20))
+	if code != http.StatusOK || body != "PUT /upload "+strings.Repeat("a", 1<<20) {
+		t.Fatalf("Unexpected response: %d, %d bytes", code, len(body))
+	}
+}
+
+func TestIntegrationSubdomain(t *testing.T) {
+	name := fmt.Sprintf("ltgo%d", time.Now().UnixNano()%1e6)
+	c := NewClient(integrationServer)
+
+	available, err := c.SubdomainAvailable(context.Background(), name)
+	if err != nil || !available {
+		t.Fatalf("%s should be available: %v", name, err)
+	}
+
+	tunnel := openIntegrationTunnel(t, name)
+	if tunnel.Subdomain() != name {
+		t.Fatalf("Unexpected subdomain. Expected: %s, Actual: %s", name, tunnel.Subdomain())
+	}
+
+	available, err = c.SubdomainAvailable(context.Background(), name)
+	if err != nil || available {
+		t.Fatalf("%s should be taken: %v", name, err)
+	}
+
+	s, err := c.ServerStatus(context.Background())
+	if err != nil || s.Tunnels < 1 {
+		t.Fatalf("Unexpected server status: %+v %v", s, err)
+	}
+}
+
+func TestIntegrationStreamTunnel(t *testing.T) {
+	tunnel := NewClient(integrationServer).NewStreamTunnel()
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	go func() {
+		c, err := tunnel.AcceptStream()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		http.ReadRequest(bufio.NewReader(c))
+		fmt.Fprint(c, "HTTP/1.1 200 OK\r\nContent-Length: 6\r\nConnection: close\r\n\r\nstream")
+	}()
+
+	code, body := getThroughServer(t, tunnel, "GET", "/", nil)
+	if code != http.StatusOK || body != "stream" {
+		t.Fatalf("Unexpected response. Expected: 200 stream, Actual: %d %s", code, body)
+	}
+}
@@ -23,147 +23,365 @@
 package localtunnel
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
+)
+
+var (
+	// ErrOpen is returned when opening a tunnel which is already open.
+	ErrOpen = errors.New("localtunnel: tunnel already open")
+
+	// ErrServerLost is wrapped by Err when the tunnel closed because the remote
+	// server could not be reached.
+	ErrServerLost = errors.New("localtunnel: remote server lost")
+
+	// ErrLocalUnreachable is wrapped by Err when the tunnel closed because the local
+	// server could not be reached.
+	ErrLocalUnreachable = errors.New("localtunnel: local server unreachable")
 )
 
 // A Client is an localtunnel client.
 type Client struct {
-	endPoint string
+	endPoint  string
+	endPoints []string // raced by NewRaceClient
+	provider  Provider
+	token     string
 }
 
 // NewLocalTunnel create a tunnel for a server in a given port from localhost.
-func (c *Client) NewLocalTunnel(port int) *Tunnel {
-	return c.NewTunnel("localhost", port)
+func (c *Client) NewLocalTunnel(port int, opts ...Option) *Tunnel {
+	return c.NewTunnel("localhost", port, opts...)
 }
 
 // NewTunnel create a tunnel for a server in a given host and port.
-func (c *Client) NewTunnel(host string, port int) *Tunnel {
-	return &Tunnel{c: c, localHost: host, localPort: port}
+func (c *Client) NewTunnel(host string, port int, opts ...Option) *Tunnel {
+	return newTunnel(c, host, port, opts)
 }
 
-// NewClient returns a client using the given end point.
+func newTunnel(c *Client, host string, port int, opts []Option) *Tunnel {
+	t := &Tunnel{c: c, localHost: host, localPort: port}
+	t.apply(opts)
+	return t
+}
+
+// NewClient returns a client using the given end point, with the Provider registered
+// for its URL scheme.
 func NewClient(url string) *Client {
 	return &Client{endPoint: url}
 }
 
+// WithToken returns a copy of the client authenticating with token, for servers
+// requiring it.
+func (c *Client) WithToken(token string) *Client {
+	cc := *c
+	cc.token = token
+	return &cc
+}
+
 // DefaultClient is the default Client and is used by NewLocalTunnel and NewTunnel.
 var DefaultClient = NewClient("https://localtunnel.me")
 
 // NewLocalTunnel create a tunnel for a server in a given port from localhost using the DefaultClient.
-func NewLocalTunnel(port int) *Tunnel {
-	return DefaultClient.NewTunnel("localhost", port)
+func NewLocalTunnel(port int, opts ...Option) *Tunnel {
+	return DefaultClient.NewTunnel("localhost", port, opts...)
 }
 
 // NewTunnel create a tunnel for a server in a given host and port using the DefaultClient.
-func NewTunnel(host string, port int) *Tunnel {
-	return DefaultClient.NewTunnel(host, port)
+func NewTunnel(host string, port int, opts ...Option) *Tunnel {
+	return DefaultClient.NewTunnel(host, port, opts...)
 }
 
 // Tunnel forwards remote requests to another server, typically to a port on localhost.
 type Tunnel struct {
-	c       *Client
-	m       sync.Mutex
-	closeCh chan struct{}
+	stats      Stats // first field to keep the counters 64-bit aligned
+	connErrors errorCounts
+
+	c         *Client
+	m         sync.Mutex // serializes Open and Close
+	localHost string
+	localPort int
+
+	requestedSubdomain string // by WithSubdomain, for Expose
 
+	subdomainStore Store // by WithSubdomainStore
+	subdomainKey   string
+
+	// sm guards the state of an open tunnel below, read by the getters while Open
+	// and Close change it
+	sm         sync.RWMutex
 	remoteHost string
 	remotePort int
-	localHost  string
-	localPort  int
 	subdomain  string
 	url        string
 	maxConn    int
+	closeCh    chan struct{}
+	retired    chan struct{} // closed once the registration is rotated or the tunnel closed
+	err        error         // why the tunnel was closed
+
+	accessUntil time.Time // end of the window set by TemporaryAccess
+
+	// the goroutines of an open tunnel are tracked by workers, done being closed
+	// once they all exited after the tunnel is closed
+	workers *sync.WaitGroup
+	done    chan struct{}
+	ctx     context.Context // canceled along with retired
+	cancel  context.CancelFunc
+
+	streams chan net.Conn
+	proxy   bool
+	udp     bool
+
+	middlewares   []middleware
+	fallback      http.Handler
+	backend       http.RoundTripper
+	backendScheme string
+	backends      []string
+	stickyCookie  string
+	tlsConfig     *tls.Config
+	traffic       *trafficStats
+	visitors      *visitors
+	shareSecret   []byte
+	signedAccess  bool
+	windowed      bool
+
+	events            chan<- Event
+	superviseInterval time.Duration
+	rotateInterval    time.Duration
+	crashDir          string // by WithCrashDumps
+
+	clock    Clock
+	rand     io.Reader
+	resolver *net.Resolver
+
+	trustedProxies int // between the visitor and the relay
+
+	identityEncoding bool // by WithIdentityEncoding
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	coalesceThreshold int
+	coalesceInterval  time.Duration
 }
 
-func (t *Tunnel) RemoteHost() string { return t.remoteHost }
-func (t *Tunnel) RemotePort() int    { return t.remotePort }
-func (t *Tunnel) LocalHost() string  { return t.localHost }
-func (t *Tunnel) LocalPort() int     { return t.localPort }
-func (t *Tunnel) Subdomain() string  { return t.subdomain }
+func (t *Tunnel) RemoteHost() string {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.remoteHost
+}
+
+func (t *Tunnel) RemotePort() int {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.remotePort
+}
+
+func (t *Tunnel) LocalHost() string { return t.localHost }
+func (t *Tunnel) LocalPort() int    { return t.localPort }
+
+func (t *Tunnel) Subdomain() string {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.subdomain
+}
 
 // URL at which the localtunnel is exposed.
-func (t *Tunnel) URL() string { return t.url }
+func (t *Tunnel) URL() string {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.url
+}
 
 // MaxConn is the maximum number of connections allowed.
-func (t *Tunnel) MaxConn() int { return t.maxConn }
+func (t *Tunnel) MaxConn() int {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.maxConn
+}
 
 // Open setup the tunnel creating connections between the remote and local servers.
 func (t *Tunnel) Open() error {
-	return t.OpenAs("?new")
+	return t.OpenContext(context.Background())
 }
 
 // Open setup the tunnel creating connections between the remote and local servers with a custom subdomain.
 func (t *Tunnel) OpenAs(subdomain string) error {
+	return t.OpenAsContext(context.Background(), subdomain)
+}
+
+// OpenContext is like Open but gives up registering the tunnel once ctx is done.
+func (t *Tunnel) OpenContext(ctx context.Context) error {
+	return t.OpenAsContext(ctx, "")
+}
+
+// OpenAsContext is like OpenAs but gives up registering the tunnel once ctx is done.
+// Registrations rate limited by the server are retried after the wait it asks for,
+// as long as ctx allows it.
+func (t *Tunnel) OpenAsContext(ctx context.Context, subdomain string) error {
 	t.m.Lock()
 	defer t.m.Unlock()
 
-	err := t.setup(subdomain)
+	if t.closeCh != nil && isOpen(t.closeCh) {
+		return ErrOpen
+	}
+
+	r, err := t.setup(ctx, t.rememberedSubdomain(subdomain))
 	if err != nil {
 		return err
 	}
+	t.rememberSubdomain(r.Subdomain)
 
+	t.sm.Lock()
+	t.register(r)
 	t.closeCh = make(chan struct{})
+	t.err = nil
+	t.done = make(chan struct{})
+	t.workers = &sync.WaitGroup{}
+	t.sm.Unlock()
+
+	t.emit(Event{Type: EventRegistered, URL: r.URL, Target: r.MaxConn})
 	t.establish()
+
+	if t.proxy {
+		t.serveHTTP(t.closeCh)
+	}
+
+	if t.rotateInterval > 0 {
+		closeCh := t.closeCh
+		t.spawn(t.workers, func() { t.rotate(closeCh) })
+	}
+
+	go func(closeCh, done chan struct{}, workers *sync.WaitGroup) {
+		<-closeCh
+		workers.Wait()
+		close(done)
+	}(t.closeCh, t.done, t.workers)
 	return nil
 }
 
-// Close closes all tunnel's connections.
+// register sets the state of the tunnel registered as r, whose connections are
+// retired along with ctx. It is called with sm held.
+func (t *Tunnel) register(r *Registration) {
+	t.remoteHost = r.RemoteHost
+	t.remotePort = r.RemotePort
+	t.maxConn = r.MaxConn
+	t.subdomain = r.Subdomain
+	t.url = r.URL
+	t.retired = make(chan struct{})
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+}
+
+// Close closes all tunnel's connections, returning once all its goroutines exited.
+// HTTP tunnels first wait up to 5 seconds for the handlers of the requests in flight.
 func (t *Tunnel) Close() {
+	t.close(ErrClosed)
+
+	if done := t.Done(); done != nil {
+		<-done
+	}
+}
+
+// Done is a channel which is closed once the tunnel is closed and all its goroutines
+// exited. It is nil until the tunnel is opened.
+func (t *Tunnel) Done() <-chan struct{} {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.done
+}
+
+// Err returns nil while the tunnel is open, or was never opened. Once the tunnel is
+// closed it tells why: ErrClosed when closed by Close, the error of the context of
+// Expose once done, an error wrapping ErrServerLost or ErrLocalUnreachable when a
+// connection failed, or a *PanicError when one of its goroutines panicked.
+func (t *Tunnel) Err() error {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.err
+}
+
+// close closes the tunnel for reason without waiting for its goroutines, which may
+// call it.
+func (t *Tunnel) close(reason error) {
 	t.m.Lock()
 	defer t.m.Unlock()
 
+	if t.closeCh == nil || !isOpen(t.closeCh) {
+		return
+	}
+
+	t.sm.Lock()
+	defer t.sm.Unlock()
+
 	t.remoteHost = ""
 	t.remotePort = 0
 	t.maxConn = 0
 	t.subdomain = ""
 	t.url = ""
+	t.err = reason
+	t.cancel()
+	close(t.retired)
 	close(t.closeCh)
+	t.emit(Event{Type: EventClosed, Err: reason})
 }
 
 // Closing is a channel which is closed when the tunnel is closed.
+//
+// Deprecated: use Done, and Err to learn why the tunnel was closed.
 func (t *Tunnel) Closing() <-chan struct{} {
+	return t.closing()
+}
+
+func (t *Tunnel) closing() <-chan struct{} {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
 	return t.closeCh
 }
 
-func (t *Tunnel) setup(subdomain string) error {
-	url := fmt.Sprintf(t.c.endPoint+"/%s", subdomain)
-	resp, err := http.Get(url)
+// setup registers the tunnel with the client's provider.
+func (t *Tunnel) setup(ctx context.Context, subdomain string) (*Registration, error) {
+	p, err := t.c.getProvider()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
+	for retries := 0; ; retries++ {
+		r, err := p.Register(t.withClock(t.withResolver(ctx)), subdomain)
 
-	var i struct {
-		ID      string `json:"id,omitempty"`
-		URL     string `json:"url,omitempty"`
-		Port    int    `json:"port,omitempty"`
-		MaxConn int    `json:"max_conn_count,omitempty"`
-	}
-
-	d := json.NewDecoder(resp.Body)
-	err = d.Decode(&i)
-	if err != nil {
-		return err
-	}
+		var rl *RateLimitError
+		if errors.As(err, &rl) {
+			if retries == maxRateLimitRetries {
+				return nil, ErrRateLimited
+			}
 
-	t.remoteHost = resp.Request.URL.Host
-	t.remotePort = i.Port
-	t.maxConn = i.MaxConn
-	t.subdomain = i.ID
-	t.url = i.URL
+			t.emit(Event{Type: EventRateLimited, Retry: rl.RetryAfter})
+			err = wait(ctx, t.after, rl.RetryAfter)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 
-	return nil
+		return r, err
+	}
 }
 
 func (t *Tunnel) establish() {
-	for i := 0; i < t.MaxConn(); i++ {
-		c := &conn{t: t}
-		go c.open()
+	p := newPool(t, t.MaxConn())
+	c := &conn{t: t, pool: p, closeCh: t.retired, workers: t.workers, ctx: t.ctx}
+	for i := 0; i < p.target; i++ {
+		c.replace()
+	}
+
+	if t.superviseInterval > 0 {
+		c.spawn(func() { t.supervise(p, c) })
 	}
 }
 
@@ -171,24 +389,94 @@ type conn struct {
 	t          *Tunnel
 	remoteConn net.Conn
 	localConn  net.Conn
+
+	// the state of the tunnel when the connection was established, so a reopened
+	// or rotated tunnel does not mix up with the goroutines of the previous one
+	pool    *pool
+	closeCh <-chan struct{}
+	workers *sync.WaitGroup
+	ctx     context.Context
 }
 
+// spawn runs f in a goroutine tracked by the tunnel's workers.
+func (c *conn) spawn(f func()) {
+	c.t.spawn(c.workers, f)
+}
+
+// spawn runs f in a goroutine tracked by workers, closing the tunnel if it panics.
+func (t *Tunnel) spawn(workers *sync.WaitGroup, f func()) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		defer t.recoverPanic()
+		f()
+	}()
+}
+
+// replace adds a new connection to the pool of c's tunnel.
+func (c *conn) replace() {
+	n := &conn{t: c.t, pool: c.pool, closeCh: c.closeCh, workers: c.workers, ctx: c.ctx}
+	n.spawn(n.open)
+}
+
+// open keeps the connection to the remote server until the tunnel is closed.
 func (c *conn) open() {
+	c.pool.join()
+	defer c.pool.leave()
+
+	for isOpen(c.closeCh) && c.serve() {
+	}
+}
+
+func (c *conn) dial(network, host string, port int) (net.Conn, error) {
+	return c.t.dialer().DialContext(c.ctx, network, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// serve connects the remote and local servers, reporting whether the connection
+// must be re-dialed once it is done.
+func (c *conn) serve() bool {
 	var err error
 
-	c.remoteConn, err = net.Dial("tcp", net.JoinHostPort(c.t.RemoteHost(), strconv.Itoa(c.t.RemotePort())))
+	release, ok := c.pool.turn(c.closeCh)
+	if !ok {
+		return false
+	}
+	c.remoteConn, err = c.dial("tcp", c.t.RemoteHost(), c.t.RemotePort())
+	release()
 	if err != nil {
-		c.t.Close()
-		return
+		c.t.connFailed(c.closeCh, sideError(RemoteSide, err))
+
+		// left to the supervisor, if any, to replace
+		if c.t.superviseInterval == 0 && isOpen(c.closeCh) {
+			c.t.close(fmt.Errorf("%w: %v", ErrServerLost, err))
+		}
+		return false
+	}
+
+	c.pool.connUp(&c.t.stats)
+	if n := c.pool.connected(); n <= c.pool.target {
+		c.t.emit(Event{Type: EventConnected, Conns: n, Target: c.pool.target})
+	}
+
+	if c.t.udp {
+		return c.serveUDP()
+	}
+
+	if c.t.streams != nil {
+		return c.accept()
 	}
 
-	c.localConn, err = net.Dial("tcp", net.JoinHostPort(c.t.LocalHost(), strconv.Itoa(c.t.LocalPort())))
+	c.localConn, err = c.dial("tcp", c.t.LocalHost(), c.t.LocalPort())
 	if err != nil {
-		c.t.Close()
-		return
+		c.t.connFailed(c.closeCh, sideError(LocalSide, err))
+		c.close()
+		if isOpen(c.closeCh) {
+			c.t.close(fmt.Errorf("%w: %v", ErrLocalUnreachable, err))
+		}
+		return false
 	}
 
-	c.pipe()
+	return c.pipe()
 }
 
 func (c *conn) close() {
@@ -198,50 +486,15 @@ func (c *conn) close() {
 
 	if c.remoteConn != nil {
 		c.remoteConn.Close()
+		c.pool.connDown(&c.t.stats)
 	}
 }
 
-func (c *conn) pipe() {
-	errorCh := make(chan error)
-	remoteCh := chanFromConn(c.remoteConn, errorCh)
-	localCh := chanFromConn(c.localConn, errorCh)
-
-	for {
-		select {
-		case b := <-remoteCh:
-			c.localConn.Write(b)
-		case b := <-localCh:
-			c.remoteConn.Write(b)
-		case <-errorCh:
-			c.close()
-			c.open()
-			return
-		case <-c.t.closeCh:
-			c.close()
-			return
-		}
+func (c *conn) write(conn net.Conn, b []byte) error {
+	if c.t.writeTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(c.t.writeTimeout))
 	}
-}
-
-func chanFromConn(conn net.Conn, errorCh chan error) chan []byte {
-	c := make(chan []byte)
-
-	go func() {
-		b := make([]byte, 1024)
-
-		for {
-			n, err := conn.Read(b)
-			if n > 0 {
-				res := make([]byte, n)
-				copy(res, b[:n])
-				c <- res
-			}
-			if err != nil {
-				errorCh <- err
-				break
-			}
-		}
-	}()
 
-	return c
+	_, err := conn.Write(b)
+	return err
 }
@@ -14,13 +14,19 @@ import (
 
 var ltRegexp = regexp.MustCompile("^https:\\/\\/.*\\.loca.lt$")
 
+// defaultClient is the DefaultClient of the package, replaced by TestMain.
+var defaultClient = DefaultClient
+
+// tunnelClient sends the requests for the tunnels' URLs, set by TestMain.
+var tunnelClient = http.DefaultClient
+
 func TestDefaultClient(t *testing.T) {
-	if DefaultClient == nil {
+	if defaultClient == nil {
 		t.Fatal("DefaultClient can not be null")
 	}
 
-	if DefaultClient.endPoint != "https://localtunnel.me" {
-		t.Fatalf("Unexpected default remote host: %s", DefaultClient.endPoint)
+	if defaultClient.endPoint != "https://localtunnel.me" {
+		t.Fatalf("Unexpected default remote host: %s", defaultClient.endPoint)
 	}
 }
 
@@ -125,7 +131,7 @@ func checkTunnelIsConnected(t *testing.T, tunnel *Tunnel, localPort int) {
 }
 
 func readFromURL(url string) (string, error) {
-	resp, err := http.Get(url)
+	resp, err := tunnelClient.Get(url)
 	if err != nil {
 		return "", err
 	}
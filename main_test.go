@@ -0,0 +1,130 @@
+//go:build !integration
+// +build !integration
+
+package localtunnel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestMain points DefaultClient at an in-process relay, so the tests using it, such
+// as TestSetupLocalTunnel, do not depend on localtunnel.me. The integration tests
+// point it at the reference server instead, see integration_test.go.
+func TestMain(m *testing.M) {
+	r := newTestRelay()
+	client := DefaultClient
+	DefaultClient = NewClient(r.api.URL)
+	tunnelClient = r.client()
+
+	code := m.Run()
+
+	DefaultClient = client
+	tunnelClient = http.DefaultClient
+	r.close()
+	os.Exit(code)
+}
+
+// testRelay mimics the public side of a localtunnel server: it registers tunnels under
+// https://<id>.loca.lt and sends each request of their visitors through one of the
+// connections opened by the tunnel, closed once answered.
+type testRelay struct {
+	api      *httptest.Server
+	visitors *httptest.Server
+	ln       net.Listener
+	conns    chan net.Conn
+	ids      int64
+}
+
+func newTestRelay() *testRelay {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	r := &testRelay{ln: ln, conns: make(chan net.Conn, 100)}
+	r.api = httptest.NewServer(http.HandlerFunc(r.register))
+	r.visitors = httptest.NewTLSServer(http.HandlerFunc(r.forward))
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r.conns <- c
+		}
+	}()
+	return r
+}
+
+func (r *testRelay) register(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/")
+	if id == "" {
+		id = fmt.Sprintf("relay%d", atomic.AddInt64(&r.ids, 1))
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             id,
+		"url":            fmt.Sprintf("https://%s.loca.lt", id),
+		"port":           r.ln.Addr().(*net.TCPAddr).Port,
+		"max_conn_count": 2,
+	})
+}
+
+func (r *testRelay) forward(w http.ResponseWriter, req *http.Request) {
+	var c net.Conn
+	select {
+	case c = <-r.conns:
+	case <-req.Context().Done():
+		return
+	}
+	defer c.Close()
+
+	err := req.Write(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(c), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// client returns a client sending the requests for the tunnels' URLs to the relay.
+func (r *testRelay) client() *http.Client {
+	addr := r.visitors.Listener.Addr().String()
+	tr := r.visitors.Client().Transport.(*http.Transport).Clone()
+	tr.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	// the relay's certificate is not issued for loca.lt
+	tr.TLSClientConfig.InsecureSkipVerify = true
+	return &http.Client{Transport: tr}
+}
+
+func (r *testRelay) close() {
+	r.api.Close()
+	r.visitors.Close()
+	r.ln.Close()
+}
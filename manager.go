@@ -0,0 +1,142 @@
+package localtunnel
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultParallelism is the number of tunnels a Manager opens at the same time by default.
+const DefaultParallelism = 4
+
+// A Manager opens and closes a group of tunnels together.
+type Manager struct {
+	// Parallelism limits how many tunnels are opened at the same time.
+	// DefaultParallelism is used when zero.
+	Parallelism int
+
+	// Events receives the EventSwitched of the tunnels taken over by their standby.
+	Events chan<- Event
+
+	m       sync.Mutex
+	entries []managed
+}
+
+type managed struct {
+	t         *Tunnel
+	subdomain string
+	standby   *managed
+}
+
+// NewManager returns an empty Manager.
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Add adds a tunnel to be opened with the given subdomain, or a random one when empty.
+func (m *Manager) Add(t *Tunnel, subdomain string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.entries = append(m.entries, managed{t: t, subdomain: subdomain})
+}
+
+// Tunnels returns the managed tunnels in the order they were added.
+func (m *Manager) Tunnels() []*Tunnel {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	tunnels := make([]*Tunnel, len(m.entries))
+	for i, e := range m.entries {
+		tunnels[i] = e.t
+	}
+	return tunnels
+}
+
+// Open opens concurrently the managed tunnels which are not open yet. The tunnels
+// which failed are left closed and their errors returned as a MultiError.
+func (m *Manager) Open() error {
+	m.m.Lock()
+	entries := append([]managed(nil), m.entries...)
+	m.m.Unlock()
+
+	n := m.Parallelism
+	if n <= 0 {
+		n = DefaultParallelism
+	}
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, n)
+		errs = make([]error, len(entries))
+	)
+	for i, e := range entries {
+		if closeCh := e.t.closing(); closeCh != nil && isOpen(closeCh) {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, e managed) {
+			defer func() {
+				if v := recover(); v != nil {
+					errs[i] = newPanicError(v)
+				}
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = e.open()
+		}(i, e)
+	}
+	wg.Wait()
+
+	var merr MultiError
+	for _, err := range errs {
+		if err != nil {
+			merr = append(merr, err)
+		}
+	}
+	if len(merr) > 0 {
+		return merr
+	}
+	return nil
+}
+
+// Close closes all managed tunnels and their standbys.
+func (m *Manager) Close() {
+	m.m.Lock()
+	entries := append([]managed(nil), m.entries...)
+	m.m.Unlock()
+
+	for _, e := range entries {
+		e.t.Close()
+		if e.standby != nil {
+			e.standby.t.Close()
+		}
+	}
+}
+
+func (e managed) open() error {
+	err := e.t.OpenAs(e.subdomain)
+	if err != nil {
+		name := e.subdomain
+		if name == "" {
+			name = net.JoinHostPort(e.t.LocalHost(), strconv.Itoa(e.t.LocalPort()))
+		}
+		return fmt.Errorf("localtunnel: cannot open %s: %w", name, err)
+	}
+	return nil
+}
+
+// MultiError holds the errors of several tunnels.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,126 @@
+package localtunnel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManagerOpen(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	var m sync.Mutex
+	var running, peak int
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		m.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+		s.Config.Handler.ServeHTTP(w, r)
+
+		m.Lock()
+		running--
+		m.Unlock()
+	}))
+	defer slow.Close()
+
+	manager := NewManager()
+	manager.Parallelism = 2
+	names := []string{"one", "two", "three", "four", "five"}
+	for _, name := range names {
+		manager.Add(NewClient(slow.URL).NewStreamTunnel(), name)
+	}
+	defer manager.Close()
+
+	err := manager.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnels: %s", err)
+	}
+
+	if peak != 2 {
+		t.Fatalf("Unexpected parallelism. Expected: %d, Actual: %d", 2, peak)
+	}
+
+	for i, tunnel := range manager.Tunnels() {
+		if tunnel.Subdomain() != names[i] {
+			t.Fatalf("Unexpected subdomain. Expected: %s, Actual: %s", names[i], tunnel.Subdomain())
+		}
+	}
+}
+
+func TestManagerOpenErrors(t *testing.T) {
+	s := newFakeServer(t, 1)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	manager := NewManager()
+	manager.Add(NewClient(s.URL).NewStreamTunnel(), "up")
+	manager.Add(NewClient(down.URL).NewStreamTunnel(), "down1")
+	manager.Add(NewClient(down.URL).NewStreamTunnel(), "down2")
+	defer manager.Close()
+
+	err := manager.Open()
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 2 {
+		t.Fatalf("Unexpected error. Expected 2 errors, Actual: %v", err)
+	}
+
+	if manager.Tunnels()[0].URL() == "" {
+		t.Fatal("The successful tunnel must stay open")
+	}
+}
+
+func TestManagerSwitch(t *testing.T) {
+	s1 := newFakeServer(t, 1)
+	s2 := newFakeServer(t, 1)
+
+	events := make(chan Event, 1)
+	manager := NewManager()
+	manager.Events = events
+	defer manager.Close()
+
+	primary := NewClient(s1.URL).NewStreamTunnel()
+	manager.Add(primary, "blue")
+	err := manager.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnels: %s", err)
+	}
+
+	if _, err := manager.Switch(primary); err != ErrNoStandby {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrNoStandby, err)
+	}
+
+	standby := NewClient(s2.URL).NewStreamTunnel()
+	err = manager.OpenStandby(primary, standby, "green")
+	if err != nil {
+		t.Fatalf("Cannot open standby: %s", err)
+	}
+	s2.conn(t)
+
+	active, err := manager.Switch(primary)
+	if err != nil {
+		t.Fatalf("Cannot switch: %s", err)
+	}
+	if active != standby || manager.Tunnels()[0] != standby {
+		t.Fatal("The standby should replace the primary tunnel")
+	}
+	if primary.URL() != "" {
+		t.Fatal("The primary tunnel should be closed")
+	}
+
+	e := <-events
+	if e.Type != EventSwitched || e.URL != "https://green.loca.lt" {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+
+	if err := manager.OpenStandby(primary, NewClient(s1.URL).NewStreamTunnel(), ""); err != ErrNotManaged {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrNotManaged, err)
+	}
+}
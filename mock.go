@@ -0,0 +1,67 @@
+package localtunnel
+
+import (
+	"net/http"
+	"strings"
+)
+
+// A Mock is a canned response served by the tunnel itself, without reaching the
+// local server.
+type Mock struct {
+	// Method matches the request method, case-insensitively. Any method matches when empty.
+	Method string `json:"method,omitempty"`
+
+	// Path matches the requests whose path is Path or lies under it.
+	Path string `json:"path"`
+
+	// Status is the response status. Defaults to 200.
+	Status int `json:"status,omitempty"`
+
+	// Header holds the response headers.
+	Header map[string]string `json:"header,omitempty"`
+
+	Body string `json:"body,omitempty"`
+}
+
+// Match reports whether the mock answers the request.
+func (m *Mock) Match(req *http.Request) bool {
+	if m.Method != "" && !strings.EqualFold(m.Method, req.Method) {
+		return false
+	}
+	return hasPathPrefix(req.URL.Path, m.Path)
+}
+
+func (m *Mock) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for k, v := range m.Header {
+		w.Header().Set(k, v)
+	}
+
+	status := m.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	if req.Method != http.MethodHead {
+		w.Write([]byte(m.Body))
+	}
+}
+
+// WithMocks answers the requests matched by mocks with their canned responses, the
+// first matching mock being used. Other requests reach the local server.
+// It implies WithHTTPProxy.
+func WithMocks(mocks ...Mock) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				for i := range mocks {
+					if mocks[i].Match(req) {
+						mocks[i].ServeHTTP(w, req)
+						return
+					}
+				}
+				next.ServeHTTP(w, req)
+			})
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMocks(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithMocks(
+		Mock{Method: "GET", Path: "/api/users", Header: map[string]string{"Content-Type": "application/json"}, Body: `[{"name":"jane"}]`},
+		Mock{Path: "/api/payments", Status: http.StatusServiceUnavailable, Body: "not implemented yet"},
+	))
+
+	w := serve(h, httptest.NewRequest("GET", "/api/users", nil))
+	if w.Code != http.StatusOK || w.Body.String() != `[{"name":"jane"}]` || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected mock response: %d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	w = serve(h, httptest.NewRequest("POST", "/api/payments/1", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "not implemented yet" {
+		t.Fatalf("Unexpected mock response: %d %s", w.Code, w.Body)
+	}
+
+	w = serve(h, httptest.NewRequest("POST", "/api/users", nil))
+	if w.Body.String() != "POST /api/users " {
+		t.Fatalf("Unmatched requests should reach the local server. Actual: %s", w.Body)
+	}
+}
@@ -0,0 +1,332 @@
+package localtunnel
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthCallbackPath is the path handled by the tunnel to complete the login flow.
+const oauthCallbackPath = "/.lt/oauth/callback"
+
+const (
+	sessionCookie = "lt_session"
+	stateCookie   = "lt_oauth_state"
+)
+
+// purposes of the values signed by the tunnel
+const (
+	sessionPurpose = "session"
+	statePurpose   = "state"
+	sharePurpose   = "share"
+)
+
+// An OAuthProvider describes the endpoints of an OAuth2/OIDC identity provider.
+type OAuthProvider struct {
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	Scopes      []string
+}
+
+var (
+	// GoogleOAuth signs in with Google accounts.
+	GoogleOAuth = OAuthProvider{
+		AuthURL:     "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenURL:    "https://oauth2.googleapis.com/token",
+		UserInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:      []string{"openid", "email"},
+	}
+
+	// GitHubOAuth signs in with GitHub accounts.
+	GitHubOAuth = OAuthProvider{
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		Scopes:      []string{"read:user", "user:email"},
+	}
+)
+
+// OAuthConfig configures the login flow protecting a tunnel.
+type OAuthConfig struct {
+	Provider     OAuthProvider
+	ClientID     string
+	ClientSecret string
+
+	// Allow lists the emails or logins allowed in. Any signed in user is allowed when empty.
+	Allow []string
+
+	// SessionTTL is how long a login lasts. Defaults to 24 hours.
+	SessionTTL time.Duration
+
+	// Secret signs the session cookies. A random secret is used when empty,
+	// so sessions do not survive a restart.
+	Secret []byte
+}
+
+// WithOAuth requires visitors to sign in with an OAuth2/OIDC provider before reaching
+// the local server. The provider must accept <tunnel url>/.lt/oauth/callback as
+// redirect URL. It implies WithHTTPProxy.
+func WithOAuth(c OAuthConfig) Option {
+	if c.SessionTTL == 0 {
+		c.SessionTTL = 24 * time.Hour
+	}
+
+	return func(t *Tunnel) {
+		var once sync.Once
+		t.use(func(next http.Handler) http.Handler {
+			// after WithRand, whatever the order of the options
+			once.Do(func() {
+				if len(c.Secret) == 0 {
+					c.Secret = t.randomBytes(32)
+				}
+			})
+			return &oauthHandler{c: c, t: t, next: next}
+		})
+	}
+}
+
+type oauthHandler struct {
+	c    OAuthConfig
+	t    *Tunnel
+	next http.Handler
+}
+
+func (h *oauthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == oauthCallbackPath {
+		h.callback(w, r)
+		return
+	}
+
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if user, ok := verifyValue(h.c.Secret, sessionPurpose, cookie.Value, h.t.now()); ok && h.allowed(user) {
+			// the session is the tunnel's, not the local server's
+			removeCookie(r, sessionCookie)
+			removeCookie(r, stateCookie)
+			h.next.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	h.login(w, r)
+}
+
+// login redirects the visitor to the provider, remembering where to go back.
+func (h *oauthHandler) login(w http.ResponseWriter, r *http.Request) {
+	if h.t.URL() == "" {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	state := hex.EncodeToString(h.t.randomBytes(16))
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    signValue(h.c.Secret, statePurpose, state+"|"+r.URL.RequestURI(), h.t.now().Add(10*time.Minute)),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   h.secure(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	q := url.Values{
+		"client_id":     {h.c.ClientID},
+		"redirect_uri":  {h.redirectURL()},
+		"response_type": {"code"},
+		"scope":         {strings.Join(h.c.Provider.Scopes, " ")},
+		"state":         {state},
+	}
+	http.Redirect(w, r, h.c.Provider.AuthURL+"?"+q.Encode(), http.StatusFound)
+}
+
+// callback completes the login started by login.
+func (h *oauthHandler) callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "Missing login state", http.StatusBadRequest)
+		return
+	}
+
+	value, ok := verifyValue(h.c.Secret, statePurpose, cookie.Value, h.t.now())
+	parts := strings.SplitN(value, "|", 2)
+	if !ok || len(parts) != 2 || parts[0] != r.FormValue("state") {
+		http.Error(w, "Invalid login state", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.user(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if !h.allowed(user) {
+		http.Error(w, fmt.Sprintf("%s is not allowed", user), http.StatusForbidden)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    signValue(h.c.Secret, sessionPurpose, user, h.t.now().Add(h.c.SessionTTL)),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   h.secure(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	back := parts[1]
+	if !strings.HasPrefix(back, "/") || strings.HasPrefix(back, "//") {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusFound)
+}
+
+// user exchanges the authorization code and returns the visitor's email or login.
+func (h *oauthHandler) user(r *http.Request) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {r.FormValue("code")},
+		"redirect_uri":  {h.redirectURL()},
+		"client_id":     {h.c.ClientID},
+		"client_secret": {h.c.ClientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, h.c.Provider.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = doJSON(req, &token)
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("localtunnel: no access token from %s", h.c.Provider.TokenURL)
+	}
+
+	req, err = http.NewRequestWithContext(r.Context(), http.MethodGet, h.c.Provider.UserInfoURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	var info struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+		Login         string `json:"login"`
+	}
+	err = doJSON(req, &info)
+	if err != nil {
+		return "", err
+	}
+
+	// OIDC providers tell whether the email was verified, an unverified one could
+	// be anybody's
+	if info.Email != "" && (info.EmailVerified == nil || *info.EmailVerified) {
+		return info.Email, nil
+	}
+	if info.Login != "" {
+		return info.Login, nil
+	}
+	return "", fmt.Errorf("localtunnel: no user identity from %s", h.c.Provider.UserInfoURL)
+}
+
+func (h *oauthHandler) allowed(user string) bool {
+	if len(h.c.Allow) == 0 {
+		return true
+	}
+
+	for _, a := range h.c.Allow {
+		if strings.EqualFold(a, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectURL returns the callback URL of the tunnel, which is never derived from the
+// Host and X-Forwarded-Proto headers as the visitor chooses them.
+func (h *oauthHandler) redirectURL() string {
+	return h.t.URL() + oauthCallbackPath
+}
+
+// secure tells whether the cookies should only be sent over HTTPS.
+func (h *oauthHandler) secure() bool {
+	return !strings.HasPrefix(h.t.URL(), "http://")
+}
+
+func doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("localtunnel: unexpected response from %s: %s", req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// signValue returns value with its expiration time and an HMAC signature. The
+// signature also covers purpose, e.g. "session", so that a value signed for one use
+// is never accepted for another one sharing the secret.
+func signValue(secret []byte, purpose, value string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signature(secret, purpose+"|"+payload)
+}
+
+// verifyValue returns the value signed by signValue for purpose if the signature is
+// valid and has not expired by now.
+func verifyValue(secret []byte, purpose, signed string, now time.Time) (string, bool) {
+	i := strings.LastIndex(signed, ".")
+	if i < 0 || !hmac.Equal([]byte(signed[i+1:]), []byte(signature(secret, purpose+"|"+signed[:i]))) {
+		return "", false
+	}
+
+	parts := strings.SplitN(signed[:i], ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return "", false
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
+
+func signature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func readRandom(r io.Reader, n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		panic(err)
+	}
+	return b
+}
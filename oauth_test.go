@@ -0,0 +1,233 @@
+package localtunnel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newFakeOAuthProvider(t *testing.T, user string) OAuthProvider {
+	return newFakeOIDCProvider(t, map[string]interface{}{"login": user})
+}
+
+// newFakeOIDCProvider returns a provider answering info about the signed in user.
+func newFakeOIDCProvider(t *testing.T, info map[string]interface{}) OAuthProvider {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "secret-code" || r.FormValue("client_secret") != "client-secret" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "token"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(info)
+	})
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return OAuthProvider{
+		AuthURL:     s.URL + "/authorize",
+		TokenURL:    s.URL + "/token",
+		UserInfoURL: s.URL + "/user",
+		Scopes:      []string{"read:user"},
+	}
+}
+
+// oauthHandlerFor returns the handler of a tunnel requiring a login, opened as
+// https://example.com.
+func oauthHandlerFor(t *testing.T, c OAuthConfig) http.Handler {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	t.Cleanup(local.Close)
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithOAuth(c))
+	tunnel.url = "https://example.com"
+	return tunnel.httpHandler()
+}
+
+// login runs the login flow for a request to path, returning the session cookie.
+func login(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, *http.Cookie) {
+	w := serve(h, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Unexpected status. Expected: 302, Actual: %d", w.Code)
+	}
+
+	auth, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.Query().Get("redirect_uri") != "https://example.com"+oauthCallbackPath {
+		t.Fatalf("Unexpected redirect uri: %s", auth.Query().Get("redirect_uri"))
+	}
+
+	state := w.Result().Cookies()[0]
+	req := httptest.NewRequest("GET", oauthCallbackPath+"?code=secret-code&state="+auth.Query().Get("state"), nil)
+	req.AddCookie(state)
+	w = serve(h, req)
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie {
+			return w, c
+		}
+	}
+	return w, nil
+}
+
+func TestOAuth(t *testing.T) {
+	h := oauthHandlerFor(t, OAuthConfig{
+		Provider:     newFakeOAuthProvider(t, "octocat"),
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Allow:        []string{"octocat"},
+	})
+
+	w, session := login(t, h, "/private?x=1")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/private?x=1" {
+		t.Fatalf("Unexpected callback response: %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if session == nil {
+		t.Fatal("Session cookie should be set")
+	}
+
+	req := httptest.NewRequest("GET", "/private", nil)
+	req.AddCookie(session)
+	w = serve(h, req)
+	if w.Code != http.StatusOK || w.Body.String() != "GET /private " {
+		t.Fatalf("Unexpected response: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestOAuthSessionNotForwarded(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(cookieHandler))
+	t.Cleanup(local.Close)
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithOAuth(OAuthConfig{
+		Provider:     newFakeOAuthProvider(t, "octocat"),
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}))
+	tunnel.url = "https://example.com"
+	h := tunnel.httpHandler()
+
+	_, session := login(t, h, "/")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	req.AddCookie(session)
+	w := serve(h, req)
+	if w.Code != http.StatusOK || w.Body.String() != "theme=dark" {
+		t.Fatalf("Unexpected cookies for the local server. Expected: theme=dark, Actual: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestOAuthUserNotAllowed(t *testing.T) {
+	h := oauthHandlerFor(t, OAuthConfig{
+		Provider:     newFakeOAuthProvider(t, "mallory"),
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Allow:        []string{"octocat"},
+	})
+
+	w, session := login(t, h, "/")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Unexpected status. Expected: 403, Actual: %d", w.Code)
+	}
+	if session != nil {
+		t.Fatal("Session cookie should not be set")
+	}
+}
+
+func TestOAuthInvalidState(t *testing.T) {
+	h := oauthHandlerFor(t, OAuthConfig{
+		Provider: newFakeOAuthProvider(t, "octocat"),
+	})
+
+	w := serve(h, httptest.NewRequest("GET", oauthCallbackPath+"?code=secret-code&state=forged", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Unexpected status. Expected: 400, Actual: %d", w.Code)
+	}
+}
+
+func TestOAuthIgnoresForwardedHost(t *testing.T) {
+	h := oauthHandlerFor(t, OAuthConfig{Provider: newFakeOAuthProvider(t, "octocat")})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "evil.example.org"
+	req.Header.Set("X-Forwarded-Proto", "http")
+	w := serve(h, req)
+
+	auth, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := auth.Query().Get("redirect_uri"); u != "https://example.com"+oauthCallbackPath {
+		t.Fatalf("Unexpected redirect uri. Expected: %s, Actual: %s", "https://example.com"+oauthCallbackPath, u)
+	}
+	if state := w.Result().Cookies()[0]; !state.Secure {
+		t.Fatal("State cookie should be secure")
+	}
+}
+
+func TestOAuthUnverifiedEmail(t *testing.T) {
+	for _, c := range []struct {
+		info map[string]interface{}
+		code int
+	}{
+		{map[string]interface{}{"email": "jane@example.com", "email_verified": true}, http.StatusFound},
+		{map[string]interface{}{"email": "jane@example.com", "email_verified": false}, http.StatusBadGateway},
+	} {
+		h := oauthHandlerFor(t, OAuthConfig{
+			Provider:     newFakeOIDCProvider(t, c.info),
+			ClientSecret: "client-secret",
+			Allow:        []string{"jane@example.com"},
+		})
+
+		if w, _ := login(t, h, "/"); w.Code != c.code {
+			t.Fatalf("Unexpected status for %v. Expected: %d, Actual: %d", c.info, c.code, w.Code)
+		}
+	}
+}
+
+func TestOAuthStateReplayedAsSession(t *testing.T) {
+	h := oauthHandlerFor(t, OAuthConfig{
+		Provider: newFakeOAuthProvider(t, "octocat"),
+		Allow:    []string{"nobody"},
+	})
+
+	w := serve(h, httptest.NewRequest("GET", "/private", nil))
+	state := w.Result().Cookies()[0]
+
+	req := httptest.NewRequest("GET", "/private", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: state.Value})
+	if w := serve(h, req); w.Code != http.StatusFound {
+		t.Fatalf("State cookie should not be accepted as a session. Expected: 302, Actual: %d", w.Code)
+	}
+}
+
+func TestSignedValue(t *testing.T) {
+	secret := []byte("secret")
+
+	v, ok := verifyValue(secret, sessionPurpose, signValue(secret, sessionPurpose, "octocat", time.Now().Add(time.Minute)), time.Now())
+	if !ok || v != "octocat" {
+		t.Fatalf("Unexpected value. Expected: octocat, Actual: %s", v)
+	}
+
+	if _, ok := verifyValue([]byte("other"), sessionPurpose, signValue(secret, sessionPurpose, "octocat", time.Now().Add(time.Minute)), time.Now()); ok {
+		t.Fatal("Value signed with another secret should be invalid")
+	}
+
+	if _, ok := verifyValue(secret, sessionPurpose, signValue(secret, sessionPurpose, "octocat", time.Now().Add(-time.Minute)), time.Now()); ok {
+		t.Fatal("Expired value should be invalid")
+	}
+
+	if _, ok := verifyValue(secret, sessionPurpose, signValue(secret, statePurpose, "octocat", time.Now().Add(time.Minute)), time.Now()); ok {
+		t.Fatal("Value signed for another purpose should be invalid")
+	}
+}
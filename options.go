@@ -0,0 +1,43 @@
+package localtunnel
+
+import (
+	"net"
+	"time"
+)
+
+// An Option configures a Tunnel.
+type Option func(*Tunnel)
+
+func (t *Tunnel) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	// after WithRand, whatever the order of the options
+	if t.signedAccess && len(t.shareSecret) == 0 {
+		t.shareSecret = t.randomBytes(32)
+	}
+
+	if t.proxy && t.streams == nil {
+		t.streams = make(chan net.Conn)
+	}
+}
+
+// WithReadTimeout sets how long a forwarded connection may go without data from
+// either side before it is recycled. A connection carrying data in one direction only
+// is not recycled. Recycled connections are re-dialed automatically.
+func WithReadTimeout(d time.Duration) Option {
+	return func(t *Tunnel) { t.readTimeout = d }
+}
+
+// WithWriteTimeout sets how long a write to either side of a forwarded connection may
+// block before the connection is recycled.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(t *Tunnel) { t.writeTimeout = d }
+}
+
+// WithHTTPProxy makes the tunnel parse the forwarded traffic as HTTP and proxy each
+// request to the local server, instead of piping raw bytes.
+func WithHTTPProxy() Option {
+	return func(t *Tunnel) { t.proxy = true }
+}
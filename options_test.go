@@ -0,0 +1,47 @@
+package localtunnel
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadTimeoutRecyclesConnection(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	local, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	go func() {
+		for {
+			c, err := local.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	port := local.Addr().(*net.TCPAddr).Port
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", port, WithReadTimeout(50*time.Millisecond))
+
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	remote := s.conn(t)
+	remote.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+
+	// the local server never answers, so the connection is recycled
+	s.conn(t)
+
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := remote.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Stalled connection should have been closed")
+	}
+}
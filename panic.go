@@ -0,0 +1,95 @@
+package localtunnel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// EventPanic is emitted when one of the tunnel's goroutines or HTTP handlers
+// panicked, with Err holding a *PanicError.
+const EventPanic EventType = "panic"
+
+// A PanicError is a panic recovered in the tunnel instead of crashing the program
+// embedding it. A panic of one of the tunnel's goroutines closes the tunnel, with
+// Err returning the PanicError, while a panic of an HTTP handler, such as a
+// middleware or the transport given to WithLocalBackend, only fails its request with 500 Internal Server Error.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+
+	// File is the crash dump written by WithCrashDumps, if any.
+	File string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("localtunnel: panic: %v", e.Value)
+}
+
+func newPanicError(v interface{}) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+// WithCrashDumps writes the panics recovered by the tunnel to files in dir, named
+// lt-crash-*.txt, with the stack of the goroutine which panicked.
+func WithCrashDumps(dir string) Option {
+	return func(t *Tunnel) { t.crashDir = dir }
+}
+
+// recoverPanic reports the panic of one of the tunnel's goroutines and closes the
+// tunnel. It must be deferred.
+func (t *Tunnel) recoverPanic() {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	err := t.panicked(v)
+	t.close(err)
+}
+
+// withRecovery fails the requests whose handler panicked, which net/http would
+// only log, and reports the panic.
+func (t *Tunnel) withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			t.panicked(v)
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// panicked reports the panic v as an event, writing its crash dump first.
+func (t *Tunnel) panicked(v interface{}) *PanicError {
+	err := newPanicError(v)
+	if t.crashDir != "" {
+		err.File = t.writeCrashDump(err)
+	}
+	t.emit(Event{Type: EventPanic, Err: err})
+	return err
+}
+
+// writeCrashDump writes err to a new file of the crash dumps directory, returning
+// its name, or "" when it could not be written.
+func (t *Tunnel) writeCrashDump(err *PanicError) string {
+	f, ferr := ioutil.TempFile(t.crashDir, "lt-crash-*.txt")
+	if ferr != nil {
+		return ""
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "localtunnel %s, %s:%d, %s\n\n", Version(), t.localHost, t.localPort, t.now().Format(time.RFC3339))
+	fmt.Fprintf(f, "panic: %v\n\n%s", err.Value, err.Stack)
+	return f.Name()
+}
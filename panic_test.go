@@ -0,0 +1,86 @@
+package localtunnel
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPanicClosesTunnel(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	s := newFakeServer(t, 1)
+	dir := t.TempDir()
+	events := make(chan Event, 16)
+	tunnel := NewClient(s.URL).NewTunnel("127.0.0.1", getServerPort(t, local), WithCrashDumps(dir), WithEvents(events))
+	if err := tunnel.Open(); err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+
+	tunnel.spawn(tunnel.workers, func() { panic("boom") })
+	select {
+	case <-tunnel.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Tunnel should be closed by the panic")
+	}
+
+	var pe *PanicError
+	if err := tunnel.Err(); !errors.As(err, &pe) || pe.Value != "boom" {
+		t.Fatalf("Unexpected error. Expected: a *PanicError, Actual: %v", err)
+	}
+	if !strings.Contains(string(pe.Stack), "TestPanicClosesTunnel") {
+		t.Fatalf("Unexpected stack. Actual: %s", pe.Stack)
+	}
+
+	b, err := ioutil.ReadFile(pe.File)
+	if err != nil {
+		t.Fatalf("Cannot read the crash dump: %s", err)
+	}
+	if !strings.Contains(string(b), "panic: boom") {
+		t.Fatalf("Unexpected crash dump. Actual: %s", b)
+	}
+
+	for e := range events {
+		if e.Type == EventPanic {
+			if e.Err != pe {
+				t.Fatalf("Unexpected event error. Expected: %v, Actual: %v", pe, e.Err)
+			}
+			break
+		}
+	}
+	checkNoGoroutines(t)
+}
+
+func TestPanicFailsRequest(t *testing.T) {
+	events := make(chan Event, 1)
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithEvents(events), WithLocalBackend("http", roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		panic("boom")
+	})))
+
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var pe *PanicError
+	if e := <-events; e.Type != EventPanic || !errors.As(e.Err, &pe) || pe.File != "" {
+		t.Fatalf("Unexpected event. Actual: %+v", e)
+	}
+}
+
+func TestRaceProvidersPanic(t *testing.T) {
+	panicking := providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+		panic("boom")
+	})
+
+	_, err := raceProvider{panicking}.Register(context.Background(), "")
+	if err == nil || !strings.Contains(err.Error(), "localtunnel: panic: boom") {
+		t.Fatalf("Unexpected error. Actual: %v", err)
+	}
+}
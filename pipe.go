@@ -0,0 +1,150 @@
+package localtunnel
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// pipeBuffers is how many buffers the data read from one side of a connection
+	// can wait in for the other side, after which the reads stop until it catches up.
+	pipeBuffers = 4
+
+	// pipeBufferSize is the size of the reads, so at most pipeBuffers*pipeBufferSize
+	// bytes are held by each direction of a connection.
+	pipeBufferSize = 16 << 10
+)
+
+// A flow carries the data read from one side of a connection to the other through
+// a bounded set of buffers. A reader waiting for a free buffer leaves its side
+// unread, so a slow consumer applies backpressure to the producer, through TCP,
+// instead of the data piling up in memory.
+type flow struct {
+	free chan []byte // buffers to read into, allocated on first use
+	data chan []byte // buffers read, to be written to the other side
+	err  error       // why the reads stopped, once data is closed
+}
+
+func newFlow() *flow {
+	f := &flow{free: make(chan []byte, pipeBuffers), data: make(chan []byte, pipeBuffers)}
+	for i := 0; i < pipeBuffers; i++ {
+		f.free <- nil
+	}
+	return f
+}
+
+// pipe copies the data between the remote and local servers, each direction on its
+// own so a slow side does not hold up the other, reporting whether the connection
+// must be re-dialed once it is done.
+func (c *conn) pipe() bool {
+	quit := make(chan struct{})
+	defer close(quit)
+
+	// the goroutines may outlive the connections, re-dialed once pipe returns
+	remote, local := c.remoteConn, c.localConn
+
+	var batch *batch
+	if c.t.coalesceThreshold > 0 {
+		batch = c.newBatch(remote)
+	}
+
+	// the time of the last read on either side, in Unix nanoseconds
+	last := time.Now().UnixNano()
+
+	in, out := newFlow(), newFlow()
+	errorCh := make(chan error, 2)
+	c.spawn(func() { c.read(remote, RemoteSide, in, &last, quit) })
+	c.spawn(func() { c.read(local, LocalSide, out, &last, quit) })
+	c.spawn(func() { errorCh <- c.drain(in, local, LocalSide, nil, c.t.stats.addBytesIn, quit) })
+	c.spawn(func() { errorCh <- c.drain(out, remote, RemoteSide, batch, c.t.stats.addBytesOut, quit) })
+
+	select {
+	case err := <-errorCh:
+		c.t.connFailed(c.closeCh, err)
+		c.close()
+		return true
+	case <-c.closeCh:
+		c.close()
+		return false
+	}
+}
+
+// read reads conn, the connection to side, into the buffers of f until it fails or
+// quit is closed, storing the time of each read in last. The connection is idle once
+// neither side was read for the read timeout, so the silent side of a one-way
+// transfer does not time out while the other keeps sending.
+func (c *conn) read(conn net.Conn, side Side, f *flow, last *int64, quit <-chan struct{}) {
+	timeout := c.t.readTimeout
+
+	for {
+		var b []byte
+		select {
+		case b = <-f.free:
+		case <-quit:
+			return
+		}
+		if b == nil {
+			b = make([]byte, pipeBufferSize)
+		}
+
+		if timeout > 0 {
+			conn.SetReadDeadline(time.Unix(0, atomic.LoadInt64(last)).Add(timeout))
+		}
+
+		n, err := conn.Read(b)
+		if n > 0 {
+			atomic.StoreInt64(last, time.Now().UnixNano())
+			// never blocks, data having room for all the buffers
+			f.data <- b[:n]
+		} else {
+			f.free <- b
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && timeout > 0 &&
+			time.Since(time.Unix(0, atomic.LoadInt64(last))) < timeout {
+			// the other side was read meanwhile
+			continue
+		}
+		if err != nil {
+			f.err = sideError(side, err)
+			close(f.data)
+			return
+		}
+	}
+}
+
+// drain writes the data of f to conn, the connection to side, through batch unless
+// nil, counting the bytes written. It returns why it stopped: the error of the
+// reads once all the data read was written, or the error of a write. It returns nil
+// once quit is closed.
+func (c *conn) drain(f *flow, conn net.Conn, side Side, batch *batch, count func(int), quit <-chan struct{}) error {
+	for {
+		select {
+		case b, ok := <-f.data:
+			if !ok {
+				if batch != nil {
+					batch.flush()
+				}
+				return f.err
+			}
+
+			count(len(b))
+			var err error
+			if batch != nil {
+				err = batch.add(b)
+			} else {
+				err = c.write(conn, b)
+			}
+			f.free <- b[:cap(b)]
+			if err != nil {
+				return sideError(side, err)
+			}
+		case <-batch.due():
+			if err := batch.flush(); err != nil {
+				return sideError(side, err)
+			}
+		case <-quit:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package localtunnel
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipeSlowConsumer(t *testing.T) {
+	// net.Pipe does not buffer, so all the data written and not read yet is held
+	// by the tunnel
+	remote, remotePeer := net.Pipe()
+	local, localPeer := net.Pipe()
+	defer remotePeer.Close()
+	defer localPeer.Close()
+
+	tunnel := NewTunnel("127.0.0.1", 8000)
+	c := &conn{
+		t:          tunnel,
+		remoteConn: remote,
+		localConn:  local,
+		pool:       newPool(tunnel, 1),
+		closeCh:    make(chan struct{}),
+		workers:    &sync.WaitGroup{},
+	}
+	done := make(chan bool, 1)
+	go func() { done <- c.pipe() }()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 64<<10) // 1MB
+	var written int64
+	go func() {
+		for b := data; len(b) > 0; b = b[1024:] {
+			if _, err := localPeer.Write(b[:1024]); err != nil {
+				return
+			}
+			atomic.AddInt64(&written, 1024)
+		}
+	}()
+
+	// the local server is stopped once the buffers are full
+	var n int64 = -1
+	for n != atomic.LoadInt64(&written) {
+		n = atomic.LoadInt64(&written)
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n == 0 || n > pipeBuffers*pipeBufferSize {
+		t.Fatalf("Unexpected bytes held for the slow consumer. Expected: at most %d, Actual: %d", pipeBuffers*pipeBufferSize, n)
+	}
+
+	// while the other direction keeps flowing
+	remotePeer.SetDeadline(time.Now().Add(5 * time.Second))
+	localPeer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := remotePeer.Write([]byte("ping")); err != nil {
+		t.Fatalf("Cannot write to the tunnel: %s", err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(localPeer, b); err != nil || string(b) != "ping" {
+		t.Fatalf("Unexpected data for the local server. Expected: ping, Actual: %q %v", b, err)
+	}
+
+	received := make([]byte, len(data))
+	if _, err := io.ReadFull(remotePeer, received); err != nil || !bytes.Equal(received, data) {
+		t.Fatalf("Unexpected data once the consumer caught up: %v", err)
+	}
+	if stats := c.t.Stats(); stats.BytesOut != int64(len(data)) || stats.BytesIn != 4 {
+		t.Fatalf("Unexpected stats. Expected: %d bytes out, 4 in, Actual: %+v", len(data), stats)
+	}
+
+	localPeer.Close()
+	select {
+	case redial := <-done:
+		if !redial {
+			t.Fatal("The connection should be re-dialed once the local server closes it")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The pipe should be done once the local server closes the connection")
+	}
+	c.workers.Wait()
+}
+
+func TestPipeOneWayTransfer(t *testing.T) {
+	remote, remotePeer := net.Pipe()
+	local, localPeer := net.Pipe()
+	defer remotePeer.Close()
+	defer localPeer.Close()
+
+	tunnel := NewTunnel("127.0.0.1", 8000, WithReadTimeout(100*time.Millisecond))
+	c := &conn{
+		t:          tunnel,
+		remoteConn: remote,
+		localConn:  local,
+		pool:       newPool(tunnel, 1),
+		closeCh:    make(chan struct{}),
+		workers:    &sync.WaitGroup{},
+	}
+	done := make(chan bool, 1)
+	go func() { done <- c.pipe() }()
+	go io.Copy(ioutil.Discard, remotePeer)
+
+	// the local server streams for longer than the timeout, the remote side is silent
+	for start := time.Now(); time.Since(start) < 500*time.Millisecond; {
+		select {
+		case <-done:
+			t.Fatal("The pipe should not time out while one side is sending")
+		case <-time.After(20 * time.Millisecond):
+		}
+		localPeer.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := localPeer.Write([]byte("data")); err != nil {
+			t.Fatalf("Cannot write to the tunnel: %s", err)
+		}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The pipe should time out once both sides are silent")
+	}
+	c.workers.Wait()
+}
@@ -0,0 +1,114 @@
+package localtunnel
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// PlaybackHeader is set on the responses served from recordings.
+const PlaybackHeader = "X-Lt-Playback"
+
+// WithPlayback answers with the responses recorded in store when the local server
+// cannot be reached, so the tunnel keeps serving what it served before. Requests are
+// matched by method and URL, or by method and path when no recording has the same
+// query; the most recent recording is used. Recordings whose response body was not
+// fully captured, being larger than the Capture's MaxBodySize or redacted, are never
+// replayed as if they were the real response. It implies WithHTTPProxy.
+//
+// Using the store of the tunnel's Capture replays the latest traffic, and a store
+// filled with ReadHAR replays an exported session.
+func WithPlayback(store CaptureStore) Option {
+	return func(t *Tunnel) {
+		t.proxy = true
+		t.fallback = &playback{store: store}
+	}
+}
+
+type playback struct {
+	store CaptureStore
+}
+
+func (p *playback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	records, err := p.store.Records()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	record := findRecording(records, r)
+	if record == nil {
+		http.Error(w, "Local server is unreachable and no response was recorded for this request", http.StatusBadGateway)
+		return
+	}
+
+	body, size, err := openBody(record.ResponseBody, record.ResponseBodyFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	for k, vs := range record.ResponseHeader {
+		if isHopHeader(k) {
+			continue
+		}
+		for _, v := range vs {
+			if v != Redacted {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set(PlaybackHeader, strconv.FormatInt(record.ID, 10))
+	w.WriteHeader(record.Status)
+	if r.Method != http.MethodHead {
+		io.Copy(w, body)
+	}
+}
+
+// findRecording returns the most recent recording matching the request.
+func findRecording(records []RequestRecord, r *http.Request) *RequestRecord {
+	var samePath *RequestRecord
+	for i := len(records) - 1; i >= 0; i-- {
+		record := &records[i]
+		if record.Method != r.Method || record.Status == 0 || record.Status == http.StatusSwitchingProtocols || !complete(record) {
+			continue
+		}
+
+		if record.URL == r.URL.RequestURI() {
+			return record
+		}
+
+		u, err := url.ParseRequestURI(record.URL)
+		if samePath == nil && err == nil && u.Path == r.URL.Path {
+			samePath = record
+		}
+	}
+	return samePath
+}
+
+// complete reports whether the recording holds the whole response body, neither
+// truncated nor changed in size by the redaction.
+func complete(record *RequestRecord) bool {
+	size := int64(len(record.ResponseBody))
+	if record.ResponseBodyFile != "" {
+		fi, err := os.Stat(record.ResponseBodyFile)
+		if err != nil {
+			return false
+		}
+		size = fi.Size()
+	}
+	return size == record.ResponseSize
+}
+
+// isHopHeader reports whether the header only applies to a single connection.
+func isHopHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade":
+		return true
+	}
+	return false
+}
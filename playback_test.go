@@ -0,0 +1,76 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlayback(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		echoHandler(w, r)
+	}))
+
+	c := NewCapture()
+	h := NewTunnel("127.0.0.1", getServerPort(t, local), WithCapture(c), WithPlayback(c.Store)).httpHandler()
+
+	serve(h, httptest.NewRequest("GET", "/page?id=1", nil))
+	serve(h, httptest.NewRequest("GET", "/page?id=2", nil))
+	local.Close()
+
+	tests := []struct {
+		method string
+		url    string
+		status int
+		body   string
+	}{
+		{"GET", "/page?id=1", 200, "GET /page "},
+		{"GET", "/page?id=3", 200, "GET /page "},
+		{"POST", "/page?id=1", 502, ""},
+		{"GET", "/other", 502, ""},
+	}
+
+	for _, test := range tests {
+		w := serve(h, httptest.NewRequest(test.method, test.url, nil))
+		if w.Code != test.status {
+			t.Fatalf("%s %s: unexpected status. Expected: %d, Actual: %d", test.method, test.url, test.status, w.Code)
+		}
+		if test.status != 200 {
+			continue
+		}
+		if w.Body.String() != test.body || w.Header().Get("Content-Type") != "text/plain" {
+			t.Fatalf("%s %s: unexpected playback: %s %s", test.method, test.url, w.Header().Get("Content-Type"), w.Body)
+		}
+		if w.Header().Get(PlaybackHeader) == "" {
+			t.Fatalf("%s %s: playback header should be set", test.method, test.url)
+		}
+	}
+}
+
+func TestNoPlaybackWhenLocalServerIsUp(t *testing.T) {
+	store := NewMemoryStore(Retention{})
+	store.Add(&RequestRecord{Method: "GET", URL: "/", Status: 200, ResponseBody: []byte("recorded")})
+
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithPlayback(store))
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "GET / " {
+		t.Fatalf("Local server should answer when reachable. Actual: %s", w.Body)
+	}
+}
+
+func TestNoPlaybackOfTruncatedRecordings(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+
+	c := NewCapture()
+	c.MaxBodySize = 4
+	h := NewTunnel("127.0.0.1", getServerPort(t, local), WithCapture(c), WithPlayback(c.Store)).httpHandler()
+
+	serve(h, httptest.NewRequest("GET", "/page", nil))
+	local.Close()
+
+	w := serve(h, httptest.NewRequest("GET", "/page", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Truncated recording should not be replayed. Expected: %d, Actual: %d %s", http.StatusBadGateway, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,117 @@
+package localtunnel
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventPoolDegraded is emitted when the pool supervisor could not bring the tunnel's
+// connections back to the size allowed by the server.
+const EventPoolDegraded EventType = "pool_degraded"
+
+// trickleInterval spaces the dials of the connections waiting for their turn, see
+// pool.turn.
+const trickleInterval = 50 * time.Millisecond
+
+// pool counts the connections of an open tunnel to the remote server.
+type pool struct {
+	members int64 // first fields to keep them 64-bit aligned
+	dialed  int64
+	up      int64 // connections established and not closed yet
+	waiting int64 // connections waiting for their turn to dial
+	lost    int64 // when the pool was left without connection up, in Unix nanoseconds
+	leading int32 // whether a connection is dialing first, no connection being up
+	target  int
+	t       *Tunnel // whose clock times the pool
+}
+
+func newPool(t *Tunnel, target int) *pool {
+	return &pool{target: target, t: t, lost: t.now().UnixNano()}
+}
+
+func (p *pool) join()     { atomic.AddInt64(&p.members, 1) }
+func (p *pool) leave()    { atomic.AddInt64(&p.members, -1) }
+func (p *pool) size() int { return int(atomic.LoadInt64(&p.members)) }
+
+// connected counts a connection established to the remote server, returning how many
+// were established since the tunnel was opened.
+func (p *pool) connected() int { return int(atomic.AddInt64(&p.dialed, 1)) }
+
+// turn waits for the turn of a connection to dial the remote server, reporting false
+// when closeCh is closed first. While connections are up, it is right away. Without
+// any, such as when the tunnel is opened or after a network blip, one connection
+// dials right away to make the tunnel usable, and the others follow
+// trickleInterval apart instead of all dialing at once. release must be called
+// once the dial is done.
+func (p *pool) turn(closeCh <-chan struct{}) (release func(), ok bool) {
+	if atomic.LoadInt64(&p.up) > 0 {
+		return func() {}, true
+	}
+	if atomic.CompareAndSwapInt32(&p.leading, 0, 1) {
+		return func() { atomic.StoreInt32(&p.leading, 0) }, true
+	}
+
+	n := atomic.AddInt64(&p.waiting, 1)
+	defer atomic.AddInt64(&p.waiting, -1)
+
+	select {
+	case <-p.t.after(time.Duration(n) * trickleInterval):
+		return func() {}, true
+	case <-closeCh:
+		return nil, false
+	}
+}
+
+// connUp counts a connection up, accounting in s how long the pool was left
+// without any.
+func (p *pool) connUp(s *Stats) {
+	s.addConns(1)
+	if atomic.AddInt64(&p.up, 1) == 1 {
+		lost := atomic.LoadInt64(&p.lost)
+		atomic.StoreInt64((*int64)(&s.FirstConn), p.t.now().UnixNano()-lost)
+	}
+}
+
+// connDown counts a connection closed.
+func (p *pool) connDown(s *Stats) {
+	s.addConns(-1)
+	if atomic.AddInt64(&p.up, -1) == 0 {
+		atomic.StoreInt64(&p.lost, p.t.now().UnixNano())
+	}
+}
+
+// WithPoolSupervisor audits the tunnel's connections every interval, dialing the
+// ones missing from the pool. Without it a connection which cannot be re-dialed
+// closes the tunnel; with it the connection is dropped and replaced at the next
+// audit, and an EventPoolDegraded is emitted when the replacements fail too.
+func WithPoolSupervisor(interval time.Duration) Option {
+	return func(t *Tunnel) { t.superviseInterval = interval }
+}
+
+// supervise keeps the pool at its target size until the tunnel is closed.
+func (t *Tunnel) supervise(p *pool, c *conn) {
+	short := false
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-t.after(t.superviseInterval):
+		}
+
+		size := p.size()
+		if size >= p.target {
+			short = false
+			continue
+		}
+
+		// the replacements of the previous audit did not hold
+		if short {
+			t.emit(Event{Type: EventPoolDegraded, Conns: size, Target: p.target})
+		}
+		short = true
+
+		for i := size; i < p.target; i++ {
+			c.replace()
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package localtunnel
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPoolSupervisor(t *testing.T) {
+	s := newFakeServer(t, 2)
+	addr := s.ln.Addr().String()
+
+	events := make(chan Event, 100)
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithPoolSupervisor(10*time.Millisecond), WithEvents(events))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	c := s.conn(t)
+	s.conn(t)
+
+	// the server goes away, so the dropped connection cannot be re-dialed
+	s.ln.Close()
+	c.Close()
+
+	timeout := time.After(5 * time.Second)
+	for degraded := false; !degraded; {
+		select {
+		case e := <-events:
+			if e.Type != EventPoolDegraded {
+				continue
+			}
+			if e.Conns != 1 || e.Target != 2 {
+				t.Fatalf("Unexpected event. Expected: pool_degraded 1/2, Actual: %s %d/%d", e.Type, e.Conns, e.Target)
+			}
+			degraded = true
+		case <-timeout:
+			t.Fatal("Timeout waiting for the pool to degrade")
+		}
+	}
+
+	select {
+	case <-tunnel.Done():
+		t.Fatal("The tunnel should stay open")
+	default:
+	}
+
+	// once the server is back the supervisor refills the pool
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Cannot listen again on %s: %s", addr, err)
+	}
+	defer ln.Close()
+
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	r, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Missing replacement connection: %s", err)
+	}
+	r.Close()
+}
+
+func TestOpenProgress(t *testing.T) {
+	s := newFakeServer(t, 3)
+
+	events := make(chan Event, 100)
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithEvents(events))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	e := <-events
+	if e.Type != EventRegistered || e.URL != tunnel.URL() || e.Target != 3 {
+		t.Fatalf("Unexpected event. Expected: registered %s 3, Actual: %s %s %d", tunnel.URL(), e.Type, e.URL, e.Target)
+	}
+
+	for i := 1; i <= 3; i++ {
+		s.conn(t)
+		select {
+		case e := <-events:
+			if e.Type != EventConnected || e.Conns != i || e.Target != 3 {
+				t.Fatalf("Unexpected event. Expected: connected %d/3, Actual: %s %d/%d", i, e.Type, e.Conns, e.Target)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for connection %d", i)
+		}
+	}
+}
+
+func TestPoolTrickle(t *testing.T) {
+	s := newFakeServer(t, 3)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+	start := time.Now()
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	conns := []net.Conn{s.conn(t), s.conn(t), s.conn(t)}
+	if elapsed := time.Since(start); elapsed < 2*trickleInterval {
+		t.Fatalf("The connections should trickle in. Expected: %s at least, Actual: %s", 2*trickleInterval, elapsed)
+	}
+	first := tunnel.Stats().FirstConn
+	if first <= 0 || first > time.Since(start) {
+		t.Fatalf("Unexpected time to first connection. Actual: %s", first)
+	}
+
+	// a network blip drops them all
+	for _, c := range conns {
+		c.Close()
+	}
+	start = time.Now()
+	s.conn(t)
+	if elapsed := time.Since(start); elapsed >= trickleInterval {
+		t.Fatalf("The first connection should be re-dialed right away. Actual: %s", elapsed)
+	}
+	s.conn(t)
+	s.conn(t)
+	if n := tunnel.Stats().FirstConn; n == first {
+		t.Fatal("The time to first connection should be accounted again after the blip")
+	}
+}
+
+func TestPoolTrickleClock(t *testing.T) {
+	s := newFakeServer(t, 3)
+	clock := newFakeClock()
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithClock(clock))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	s.conn(t)
+	for clock.pending() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-s.conns:
+		t.Fatal("The connections should wait for the clock to trickle in")
+	case <-time.After(3 * trickleInterval):
+	}
+	if first := tunnel.Stats().FirstConn; first != 0 {
+		t.Fatalf("Unexpected time to first connection on a stopped clock. Actual: %s", first)
+	}
+
+	clock.Advance(2 * trickleInterval)
+	s.conn(t)
+	s.conn(t)
+}
@@ -0,0 +1,180 @@
+package localtunnel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Provider registers tunnels on a tunnel service.
+type Provider interface {
+	// Register requests a tunnel with the given subdomain, or a random one when empty.
+	// Providers return a *RateLimitError when the service asks to try again later.
+	Register(ctx context.Context, subdomain string) (*Registration, error)
+}
+
+// A Registration describes a tunnel assigned by a Provider: its public URL and where
+// its connections must be dialed.
+type Registration struct {
+	Subdomain  string
+	URL        string
+	RemoteHost string
+	RemotePort int
+	MaxConn    int
+}
+
+// RateLimitError is returned by a Provider asking to retry the registration after a while.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("localtunnel: rate limited, retry after %s", e.RetryAfter)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]func(endpoint *url.URL) (Provider, error){
+		"http":  newLocaltunnelProvider,
+		"https": newLocaltunnelProvider,
+	}
+)
+
+// RegisterProvider makes NewClient use newProvider for the end points with the given
+// URL scheme. The localtunnel protocol is registered for http and https. The token
+// of the client, if any, is the user of the end point given to newProvider.
+func RegisterProvider(scheme string, newProvider func(endpoint *url.URL) (Provider, error)) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[strings.ToLower(scheme)] = newProvider
+}
+
+// NewProviderClient returns a client registering its tunnels with p.
+func NewProviderClient(p Provider) *Client {
+	return &Client{provider: p}
+}
+
+// getProvider returns the provider of the client's end point, or the one racing
+// its end points.
+func (c *Client) getProvider() (Provider, error) {
+	if c.provider != nil {
+		return c.provider, nil
+	}
+
+	if len(c.endPoints) == 0 {
+		return newProvider(c.endPoint, c.token)
+	}
+
+	providers := make(raceProvider, len(c.endPoints))
+	for i, endpoint := range c.endPoints {
+		p, err := newProvider(endpoint, c.token)
+		if err != nil {
+			return nil, err
+		}
+		providers[i] = p
+	}
+	return providers, nil
+}
+
+// newProvider returns the provider registered for the scheme of endpoint.
+func newProvider(endpoint, token string) (Provider, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		u.User = url.User(token)
+	}
+
+	providersMu.RLock()
+	create, ok := providers[strings.ToLower(u.Scheme)]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("localtunnel: no provider for %q", u.Scheme)
+	}
+	return create(u)
+}
+
+// localtunnelProvider implements the protocol of https://github.com/localtunnel/server.
+type localtunnelProvider struct {
+	endPoint string
+	token    string
+}
+
+func newLocaltunnelProvider(endpoint *url.URL) (Provider, error) {
+	u := *endpoint
+	u.User = nil
+	return &localtunnelProvider{endPoint: u.String(), token: endpoint.User.Username()}, nil
+}
+
+func (p *localtunnelProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	query := ""
+	if subdomain == "" {
+		query = "new"
+	}
+
+	u, err := joinEndpoint(p.endPoint, url.PathEscape(subdomain), query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	setToken(req, p.token)
+	req.Header.Set("User-Agent", userAgent())
+
+	resp, err := httpClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &RateLimitError{RetryAfter: retryAfter(resp.Header, clockNow(ctx))}
+	}
+
+	var i struct {
+		ID      string `json:"id,omitempty"`
+		URL     string `json:"url,omitempty"`
+		Port    int    `json:"port,omitempty"`
+		MaxConn int    `json:"max_conn_count,omitempty"`
+	}
+
+	d := json.NewDecoder(resp.Body)
+	err = d.Decode(&i)
+	if err != nil {
+		return nil, err
+	}
+
+	// older servers may only send one of them
+	if i.ID == "" {
+		i.ID, _ = SubdomainFromURL(i.URL)
+	}
+	if i.URL == "" && i.ID != "" {
+		i.URL, _ = URLForSubdomain(p.endPoint, i.ID)
+	}
+
+	return &Registration{
+		Subdomain:  i.ID,
+		URL:        i.URL,
+		RemoteHost: resp.Request.URL.Hostname(),
+		RemotePort: i.Port,
+		MaxConn:    i.MaxConn,
+	}, nil
+}
+
+// setToken authenticates req with a bearer token, unless empty.
+func setToken(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
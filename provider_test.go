@@ -0,0 +1,117 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type staticProvider struct {
+	r *Registration
+}
+
+func (p *staticProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	r := *p.r
+	if subdomain != "" {
+		r.Subdomain = subdomain
+	}
+	return &r, nil
+}
+
+func TestRegisterProvider(t *testing.T) {
+	s := newFakeServer(t, 1)
+	addr := s.ln.Addr().(*net.TCPAddr)
+
+	var endpoint string
+	RegisterProvider("static", func(u *url.URL) (Provider, error) {
+		endpoint = u.String()
+		return &staticProvider{&Registration{
+			Subdomain:  "random",
+			URL:        "https://" + u.Host,
+			RemoteHost: addr.IP.String(),
+			RemotePort: addr.Port,
+			MaxConn:    1,
+		}}, nil
+	})
+
+	tunnel := NewClient("static://tunnels.example.com").NewStreamTunnel()
+	err := tunnel.OpenAs("ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	if endpoint != "static://tunnels.example.com" {
+		t.Fatalf("Unexpected end point. Expected: static://tunnels.example.com, Actual: %s", endpoint)
+	}
+	if tunnel.Subdomain() != "ltdemo" || tunnel.URL() != "https://tunnels.example.com" {
+		t.Fatalf("Unexpected tunnel: %s %s", tunnel.Subdomain(), tunnel.URL())
+	}
+	s.conn(t)
+}
+
+func TestUnknownProvider(t *testing.T) {
+	err := NewClient("gopher://example.com").NewStreamTunnel().Open()
+	if err == nil {
+		t.Fatal("Unknown schemes should fail")
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	var auth []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL).WithToken("s3cr3t")
+	tunnel := c.NewStreamTunnel()
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	_, err = c.ServerStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range auth {
+		if a != "Bearer s3cr3t" {
+			t.Fatalf("Unexpected Authorization. Expected: 'Bearer s3cr3t'. Actual: '%s'", a)
+		}
+	}
+	if len(auth) != 2 {
+		t.Fatalf("Unexpected number of requests. Expected: 2, Actual: %d", len(auth))
+	}
+}
+
+func TestEndpointUnderPathPrefix(t *testing.T) {
+	s := newFakeServer(t, 1)
+	ts := httptest.NewServer(http.StripPrefix("/lt", s.Config.Handler))
+	defer ts.Close()
+
+	for _, endpoint := range []string{ts.URL + "/lt", ts.URL + "/lt/"} {
+		c := NewClient(endpoint)
+		tunnel := c.NewStreamTunnel()
+		err := tunnel.OpenAs("ltdemo")
+		if err != nil {
+			t.Fatalf("%s: cannot open tunnel: %s", endpoint, err)
+		}
+		tunnel.Close()
+
+		if _, err := c.ServerStatus(context.Background()); err != nil {
+			t.Fatalf("%s: cannot get server status: %s", endpoint, err)
+		}
+		if _, err := c.TunnelStatus(context.Background(), "ltdemo"); err != nil {
+			t.Fatalf("%s: cannot get tunnel status: %s", endpoint, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package localtunnel
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A QueuePolicy decides which of the requests waiting for the local server is
+// forwarded next, see WithFairQueueing.
+type QueuePolicy string
+
+const (
+	// QueueFIFO forwards the waiting requests in their order of arrival.
+	QueueFIFO QueuePolicy = "fifo"
+
+	// QueueByClient takes turns between the visitors, by IP address, so one of them
+	// downloading many large files does not hold up the others.
+	QueueByClient QueuePolicy = "client"
+
+	// QueueByPath takes turns between the first segments of the paths, so slow
+	// downloads under /assets do not hold up the requests to /api.
+	QueueByPath QueuePolicy = "path"
+)
+
+// WithFairQueueing forwards at most limit requests at once to the local server, or
+// as many as the connections of the tunnel when limit is 0. The other requests wait
+// their turn, as decided by policy, and are dropped when their visitor leaves. The
+// Queued counter of Stats tells how many are waiting. It implies WithHTTPProxy.
+func WithFairQueueing(limit int, policy QueuePolicy) Option {
+	return func(t *Tunnel) {
+		q := &fairQueue{t: t, limit: limit, policy: policy, queues: map[string][]chan struct{}{}}
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !q.acquire(r) {
+					return
+				}
+				defer q.release()
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+// fairQueue hands the slots of the requests forwarded at once over to the waiting
+// requests, taking turns between their keys.
+type fairQueue struct {
+	t      *Tunnel
+	limit  int
+	policy QueuePolicy
+
+	m       sync.Mutex
+	running int
+	queues  map[string][]chan struct{} // waiting requests by key
+	turns   []string                   // keys with waiting requests, next one first
+}
+
+func (q *fairQueue) key(r *http.Request) string {
+	switch q.policy {
+	case QueueByClient:
+		if ip := clientIP(r); ip != nil {
+			return ip.String()
+		}
+	case QueueByPath:
+		segment := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		return "/" + segment
+	}
+	return ""
+}
+
+// acquire waits for a slot for r, reporting false when its visitor left first.
+func (q *fairQueue) acquire(r *http.Request) bool {
+	limit := q.limit
+	if limit <= 0 {
+		limit = q.t.MaxConn()
+	}
+
+	q.m.Lock()
+	if limit <= 0 || q.running < limit && len(q.turns) == 0 {
+		q.running++
+		q.m.Unlock()
+		return true
+	}
+
+	key := q.key(r)
+	turn := make(chan struct{})
+	if len(q.queues[key]) == 0 {
+		q.turns = append(q.turns, key)
+	}
+	q.queues[key] = append(q.queues[key], turn)
+	q.t.stats.addQueued(1)
+	q.m.Unlock()
+
+	select {
+	case <-turn:
+		return true
+	case <-r.Context().Done():
+	}
+
+	q.m.Lock()
+	removed := q.remove(key, turn)
+	q.m.Unlock()
+	if !removed {
+		// handed a slot meanwhile
+		q.release()
+	}
+	return false
+}
+
+// release hands the slot of a request done over to the next one waiting.
+func (q *fairQueue) release() {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	if len(q.turns) == 0 {
+		q.running--
+		return
+	}
+
+	key := q.turns[0]
+	q.turns = q.turns[1:]
+	waiting := q.queues[key]
+	if len(waiting) > 1 {
+		q.queues[key] = waiting[1:]
+		q.turns = append(q.turns, key)
+	} else {
+		delete(q.queues, key)
+	}
+	q.t.stats.addQueued(-1)
+	close(waiting[0])
+}
+
+// remove drops a request waiting under key, reporting whether it was still waiting.
+func (q *fairQueue) remove(key string, turn chan struct{}) bool {
+	waiting := q.queues[key]
+	for i, ch := range waiting {
+		if ch != turn {
+			continue
+		}
+
+		waiting = append(waiting[:i:i], waiting[i+1:]...)
+		if len(waiting) > 0 {
+			q.queues[key] = waiting
+		} else {
+			delete(q.queues, key)
+			for j, k := range q.turns {
+				if k == key {
+					q.turns = append(q.turns[:j:j], q.turns[j+1:]...)
+					break
+				}
+			}
+		}
+		q.t.stats.addQueued(-1)
+		return true
+	}
+	return false
+}
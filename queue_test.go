@@ -0,0 +1,112 @@
+package localtunnel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queuedOrder serves a request of each of paths, as sent by the visitor of the same
+// index, the first one holding the only slot until the others are queued, and
+// returns the order in which the local server got them.
+func queuedOrder(t *testing.T, policy QueuePolicy, paths, visitors []string) []string {
+	var m sync.Mutex
+	var order []string
+	held, hold := make(chan struct{}), make(chan struct{})
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == paths[0] {
+			close(held)
+			<-hold
+			return
+		}
+		m.Lock()
+		order = append(order, r.URL.Path)
+		m.Unlock()
+	}))
+	defer local.Close()
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithFairQueueing(1, policy))
+	h := tunnel.httpHandler()
+
+	var done []<-chan *httptest.ResponseRecorder
+	for i, path := range paths {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-For", visitors[i])
+		done = append(done, serveAsync(h, req))
+		if i == 0 {
+			<-held
+		}
+		waitQueued(t, tunnel, int64(i))
+	}
+
+	close(hold)
+	for _, d := range done {
+		if w := <-d; w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusOK, w.Code)
+		}
+	}
+	if n := tunnel.Stats().Queued; n != 0 {
+		t.Fatalf("Unexpected queued requests. Expected: 0, Actual: %d", n)
+	}
+	return order
+}
+
+func waitQueued(t *testing.T, tunnel *Tunnel, n int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for tunnel.Stats().Queued != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Unexpected queued requests. Expected: %d, Actual: %d", n, tunnel.Stats().Queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFairQueueing(t *testing.T) {
+	paths := []string{"/download/1", "/download/2", "/download/3", "/api/1"}
+	visitors := []string{"198.51.100.1", "198.51.100.1", "198.51.100.1", "198.51.100.2"}
+
+	for policy, expected := range map[QueuePolicy]string{
+		QueueFIFO:     "/download/2 /download/3 /api/1",
+		QueueByClient: "/download/2 /api/1 /download/3",
+		QueueByPath:   "/download/2 /api/1 /download/3",
+	} {
+		if order := strings.Join(queuedOrder(t, policy, paths, visitors), " "); order != expected {
+			t.Fatalf("Unexpected order with %s. Expected: %s, Actual: %s", policy, expected, order)
+		}
+	}
+}
+
+func TestFairQueueingVisitorLeaving(t *testing.T) {
+	held, hold := make(chan struct{}), make(chan struct{})
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(held)
+			<-hold
+		}
+	}))
+	defer local.Close()
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithFairQueueing(1, QueueFIFO))
+	h := tunnel.httpHandler()
+
+	slow := serveAsync(h, httptest.NewRequest("GET", "/slow", nil))
+	<-held
+
+	req := httptest.NewRequest("GET", "/gone", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	gone := serveAsync(h, req.WithContext(ctx))
+	waitQueued(t, tunnel, 1)
+	cancel()
+	<-gone
+	waitQueued(t, tunnel, 0)
+
+	close(hold)
+	<-slow
+	if w := serve(h, httptest.NewRequest("GET", "/next", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Slot should be free once the requests are done. Actual status: %d", w.Code)
+	}
+}
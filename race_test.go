@@ -0,0 +1,66 @@
+package localtunnel
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentAccess is meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	s := newFakeServer(t, 2)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				tunnel.RemoteHost()
+				tunnel.RemotePort()
+				tunnel.Subdomain()
+				tunnel.URL()
+				tunnel.MaxConn()
+				tunnel.Closing()
+				tunnel.Done()
+				tunnel.Stats()
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		var opens sync.WaitGroup
+		for j := 0; j < 2; j++ {
+			opens.Add(1)
+			go func() {
+				defer opens.Done()
+				if err := tunnel.Open(); err != nil && err != ErrOpen {
+					t.Error(err)
+				}
+			}()
+		}
+		opens.Wait()
+
+		var closes sync.WaitGroup
+		for j := 0; j < 2; j++ {
+			closes.Add(1)
+			go func() {
+				defer closes.Done()
+				tunnel.Close()
+			}()
+		}
+		closes.Wait()
+	}
+
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package localtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// releaseTimeout bounds the release of the registrations losing a race.
+const releaseTimeout = 10 * time.Second
+
+// A Releaser is a Provider able to free a registration it made that goes unused,
+// such as one losing a race of RaceProviders. Providers whose service frees the
+// tunnels never connected to, like the localtunnel server does after a grace
+// period, do not need to implement it.
+type Releaser interface {
+	Release(ctx context.Context, r *Registration) error
+}
+
+// RaceProviders returns a Provider registering each tunnel with all of providers at
+// once, e.g. relays in several regions. The first registration to succeed is kept and
+// the others are canceled, so the tunnel gets its URL from the fastest provider up.
+// Registrations succeeding after the first one are released if their provider is a
+// Releaser.
+// When all of them fail, it returns a *RateLimitError if one of them asked to retry
+// later, with the shortest wait, or else an error listing their failures.
+func RaceProviders(providers ...Provider) Provider {
+	return raceProvider(providers)
+}
+
+// NewRaceClient returns a client racing the registration of its tunnels on all the
+// end points with RaceProviders. The status methods of the client, such as
+// ServerStatus, query the first end point.
+func NewRaceClient(endpoints ...string) *Client {
+	c := &Client{endPoints: endpoints}
+	if len(endpoints) > 0 {
+		c.endPoint = endpoints[0]
+	}
+	return c
+}
+
+type raceProvider []Provider
+
+type raceResult struct {
+	p   Provider
+	r   *Registration
+	err error
+}
+
+func (providers raceProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("localtunnel: no provider to race")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan raceResult, len(providers))
+	for _, p := range providers {
+		go func(p Provider) {
+			defer func() {
+				if v := recover(); v != nil {
+					results <- raceResult{p, nil, newPanicError(v)}
+				}
+			}()
+			r, err := p.Register(ctx, subdomain)
+			results <- raceResult{p, r, err}
+		}(p)
+	}
+
+	var limited *RateLimitError
+	var failures []string
+	for i := range providers {
+		res := <-results
+		if res.err == nil {
+			go releaseLosers(results, len(providers)-i-1)
+			return res.r, nil
+		}
+
+		var rl *RateLimitError
+		if errors.As(res.err, &rl) && (limited == nil || rl.RetryAfter < limited.RetryAfter) {
+			limited = rl
+		}
+		failures = append(failures, res.err.Error())
+	}
+
+	if limited != nil {
+		return nil, limited
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("localtunnel: no provider registered the tunnel: %s", strings.Join(failures, "; "))
+}
+
+// releaseLosers waits for the n registrations still racing, which were canceled, and
+// releases the ones that succeeded anyway.
+func releaseLosers(results <-chan raceResult, n int) {
+	for ; n > 0; n-- {
+		res := <-results
+		if res.err != nil {
+			continue
+		}
+		if rel, ok := res.p.(Releaser); ok {
+			release(rel, res.r)
+		}
+	}
+}
+
+// release releases r, ignoring a panic of rel as its errors.
+func release(rel Releaser, r *Registration) {
+	defer func() { recover() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+	rel.Release(ctx, r)
+}
@@ -0,0 +1,119 @@
+package localtunnel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// providerFunc is a Provider calling itself.
+type providerFunc func(ctx context.Context, subdomain string) (*Registration, error)
+
+func (f providerFunc) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	return f(ctx, subdomain)
+}
+
+func TestRaceProviders(t *testing.T) {
+	canceled := make(chan bool, 1)
+	slow := providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+		<-ctx.Done()
+		canceled <- true
+		return nil, ctx.Err()
+	})
+	failing := providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+		return nil, errors.New("down")
+	})
+	fast := &staticProvider{&Registration{URL: "https://fast.example.com"}}
+
+	r, err := RaceProviders(slow, failing, fast).Register(context.Background(), "")
+	if err != nil || r.URL != "https://fast.example.com" {
+		t.Fatalf("Unexpected registration. Expected: https://fast.example.com, Actual: %+v (%v)", r, err)
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The slower registration should be canceled")
+	}
+}
+
+// releasingProvider registers tunnels once allowed to, even when canceled, and
+// reports the registrations it releases.
+type releasingProvider struct {
+	proceed  chan bool
+	released chan *Registration
+}
+
+func (p *releasingProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	<-p.proceed
+	return &Registration{URL: "https://slow.example.com"}, nil
+}
+
+func (p *releasingProvider) Release(ctx context.Context, r *Registration) error {
+	p.released <- r
+	return nil
+}
+
+func TestRaceProvidersReleaseLosers(t *testing.T) {
+	slow := &releasingProvider{proceed: make(chan bool), released: make(chan *Registration, 1)}
+	fast := &staticProvider{&Registration{URL: "https://fast.example.com"}}
+
+	r, err := RaceProviders(slow, fast).Register(context.Background(), "")
+	if err != nil || r.URL != "https://fast.example.com" {
+		t.Fatalf("Unexpected registration. Expected: https://fast.example.com, Actual: %+v (%v)", r, err)
+	}
+
+	close(slow.proceed)
+	select {
+	case r := <-slow.released:
+		if r.URL != "https://slow.example.com" {
+			t.Fatalf("Unexpected released registration. Expected: https://slow.example.com, Actual: %s", r.URL)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The losing registration should be released")
+	}
+}
+
+func TestRaceProvidersFailing(t *testing.T) {
+	failing := providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+		return nil, errors.New("down")
+	})
+	limited := func(d time.Duration) Provider {
+		return providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+			return nil, &RateLimitError{RetryAfter: d}
+		})
+	}
+
+	_, err := RaceProviders(failing, failing).Register(context.Background(), "")
+	if err == nil {
+		t.Fatal("Registration should fail when all the providers fail")
+	}
+
+	_, err = RaceProviders(limited(time.Minute), failing, limited(time.Second)).Register(context.Background(), "")
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.RetryAfter != time.Second {
+		t.Fatalf("Unexpected error. Expected: rate limited for 1s, Actual: %v", err)
+	}
+}
+
+func TestRaceClient(t *testing.T) {
+	s := newFakeServer(t, 1)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	tunnel := NewRaceClient(down.URL, s.URL).NewStreamTunnel()
+	err := tunnel.OpenAs("ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	if tunnel.URL() != "https://ltdemo.loca.lt" {
+		t.Fatalf("Unexpected URL. Expected: https://ltdemo.loca.lt, Actual: %s", tunnel.URL())
+	}
+}
@@ -0,0 +1,52 @@
+package localtunnel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrRateLimited is returned when the server keeps rejecting the registration with
+// 429 Too Many Requests, or asks to wait beyond the context's deadline.
+var ErrRateLimited = errors.New("localtunnel: rate limited by the server")
+
+const (
+	// maxRateLimitRetries is how many times a rate limited registration is retried.
+	maxRateLimitRetries = 5
+
+	// defaultRetryAfter is the wait used when the server does not send a valid Retry-After.
+	defaultRetryAfter = 5 * time.Second
+)
+
+// retryAfter returns how long to wait according to a Retry-After header, given in
+// seconds or as an HTTP date.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if d, err := http.ParseTime(v); err == nil {
+		if wait := d.Sub(now); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return defaultRetryAfter
+}
+
+// wait sleeps for d, as timed by after, unless ctx is done first, or would be before
+// d elapses.
+func wait(ctx context.Context, after func(time.Duration) <-chan time.Time, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return ErrRateLimited
+	}
+
+	select {
+	case <-after(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
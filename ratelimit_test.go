@@ -0,0 +1,92 @@
+package localtunnel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rateLimitedServer answers the first n registrations with 429 and retryAfter.
+func rateLimitedServer(t *testing.T, n int32, retryAfter string) *httptest.Server {
+	s := newFakeServer(t, 1)
+
+	var calls int32
+	rl := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(rl.Close)
+	return rl
+}
+
+func TestOpenRetriesWhenRateLimited(t *testing.T) {
+	s := rateLimitedServer(t, 2, "0")
+	events := make(chan Event, 10)
+
+	tunnel := NewClient(s.URL).NewStreamTunnel(WithEvents(events))
+	err := tunnel.OpenAs("ltdemo")
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	if tunnel.Subdomain() != "ltdemo" {
+		t.Fatalf("Unexpected subdomain. Expected: ltdemo, Actual: %s", tunnel.Subdomain())
+	}
+
+	limited := 0
+	for len(events) > 0 {
+		e := <-events
+		if e.Type != EventRateLimited {
+			continue
+		}
+		if e.Retry != 0 {
+			t.Fatalf("Unexpected event: %+v", e)
+		}
+		limited++
+	}
+	if limited != 2 {
+		t.Fatalf("Unexpected rate limited events. Expected: %d, Actual: %d", 2, limited)
+	}
+}
+
+func TestOpenRateLimitedBeyondDeadline(t *testing.T) {
+	s := rateLimitedServer(t, 1, "60")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := NewClient(s.URL).NewStreamTunnel().OpenContext(ctx)
+	if err != ErrRateLimited {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrRateLimited, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("Must not wait when the deadline is too close")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Mon, 01 Jan 2024 00:00:30 GMT": 30 * time.Second,
+		"Sun, 31 Dec 2023 00:00:00 GMT": 0,
+		"":                              defaultRetryAfter,
+		"soon":                          defaultRetryAfter,
+	}
+
+	for v, expected := range tests {
+		h := http.Header{"Retry-After": {v}}
+		if d := retryAfter(h, now); d != expected {
+			t.Fatalf("%q: unexpected wait. Expected: %s, Actual: %s", v, expected, d)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package localtunnel
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WithResolver makes the tunnel look up host names with r instead of
+// net.DefaultResolver: when registering, dialing the remote and local servers,
+// proxying HTTP requests and diagnosing the tunnel. It allows DNS over HTTPS or
+// custom resolvers, and fake ones in tests.
+func WithResolver(r *net.Resolver) Option {
+	return func(t *Tunnel) { t.resolver = r }
+}
+
+// dialer returns a dialer using the tunnel's resolver.
+func (t *Tunnel) dialer() *net.Dialer {
+	return &net.Dialer{Resolver: t.resolver}
+}
+
+func (t *Tunnel) lookupHost(ctx context.Context, host string) ([]string, error) {
+	r := t.resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return r.LookupHost(ctx, host)
+}
+
+type resolverKey struct{}
+
+// withResolver passes the resolver of the tunnel, if any, to the requests made with ctx.
+func (t *Tunnel) withResolver(ctx context.Context) context.Context {
+	if t.resolver == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resolverKey{}, t.resolver)
+}
+
+// httpClient returns the client for the requests made with ctx, using the resolver
+// it carries.
+func httpClient(ctx context.Context) *http.Client {
+	r, _ := ctx.Value(resolverKey{}).(*net.Resolver)
+	if r == nil {
+		return http.DefaultClient
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = (&net.Dialer{Resolver: r, Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	tr.DisableKeepAlives = true
+	return &http.Client{Transport: tr}
+}
@@ -0,0 +1,124 @@
+package localtunnel
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeResolver answers 127.0.0.1 to every A query, recording the names looked up.
+type fakeResolver struct {
+	*net.Resolver
+
+	m     sync.Mutex
+	names map[string]bool
+}
+
+func newFakeResolver(t *testing.T) *fakeResolver {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	r := &fakeResolver{names: map[string]bool{}}
+	r.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", pc.LocalAddr().String())
+		},
+	}
+
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if resp := r.answer(b[:n]); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return r
+}
+
+// answer builds the response to a DNS query with a single question.
+func (r *fakeResolver) answer(q []byte) []byte {
+	if len(q) < 12 {
+		return nil
+	}
+
+	// the question is the name's labels followed by its type and class
+	i := 12
+	var name string
+	for i < len(q) && q[i] != 0 {
+		l := int(q[i])
+		if i+1+l > len(q) {
+			return nil
+		}
+		name += string(q[i+1:i+1+l]) + "."
+		i += 1 + l
+	}
+	end := i + 5
+	if end > len(q) {
+		return nil
+	}
+	isA := q[i+1] == 0 && q[i+2] == 1
+
+	r.m.Lock()
+	r.names[name] = true
+	r.m.Unlock()
+
+	resp := append([]byte{q[0], q[1], 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0}, q[12:end]...)
+	if isA {
+		resp[7] = 1
+		resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+	}
+	return resp
+}
+
+func (r *fakeResolver) resolved(name string) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.names[name+"."]
+}
+
+func TestWithResolver(t *testing.T) {
+	s := newFakeServer(t, 1)
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	r := newFakeResolver(t)
+	server := fmt.Sprintf("http://server.lt.test:%d", getServerPort(t, s.Server))
+	tunnel := NewClient(server).NewTunnel("local.lt.test", getServerPort(t, local), WithResolver(r.Resolver))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	remote := s.conn(t)
+	fmt.Fprint(remote, "GET /echo HTTP/1.1\r\nHost: demo.loca.lt\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(remote), nil)
+	if err != nil {
+		t.Fatalf("Cannot read response: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected status. Expected: 200, Actual: %d", resp.StatusCode)
+	}
+
+	for _, name := range []string{"server.lt.test", "local.lt.test"} {
+		if !r.resolved(name) {
+			t.Fatalf("%s should be resolved by the resolver", name)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package localtunnel
+
+import (
+	"net/http"
+	"time"
+)
+
+// retryDelay is how long a failed request waits before being retried, leaving the
+// local server some time to come back, e.g. from a hot reload. It follows the real
+// clock, like the deadlines of the connections.
+const retryDelay = 100 * time.Millisecond
+
+// retryTransport retries once the GET and HEAD requests without a body for which the
+// local server reset, closed or refused the connection before answering. Retrying
+// them is safe as they are idempotent and no response reached the visitor yet.
+type retryTransport struct {
+	next http.RoundTripper
+}
+
+func (rt *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(r)
+	if err == nil || !retryable(r, err) {
+		return resp, err
+	}
+
+	timer := time.NewTimer(retryDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		return nil, err
+	}
+	return rt.next.RoundTrip(r)
+}
+
+func retryable(r *http.Request, err error) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		return false
+	}
+
+	switch classify(err) {
+	case ClassEOF, ClassReset, ClassRefused:
+		return true
+	}
+	return false
+}
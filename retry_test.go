@@ -0,0 +1,82 @@
+package localtunnel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// flakyHandler drops the connection of its first request, as a local server
+// restarting for a hot reload would, and echoes the others.
+func flakyHandler(calls *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(calls, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		echoHandler(w, r)
+	})
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	for _, method := range []string{"GET", "HEAD"} {
+		var calls int64
+		h := tunnelHandler(t, flakyHandler(&calls))
+
+		w := serve(h, httptest.NewRequest(method, "/reload", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Unexpected status of %s. Expected: %d, Actual: %d", method, http.StatusOK, w.Code)
+		}
+		if n := atomic.LoadInt64(&calls); n != 2 {
+			t.Fatalf("Unexpected calls of %s. Expected: 2, Actual: %d", method, n)
+		}
+	}
+}
+
+func TestRetryOnlyOnce(t *testing.T) {
+	var calls int64
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusBadGateway {
+		t.Fatalf("Unexpected status. Expected: %d, Actual: %d", http.StatusBadGateway, w.Code)
+	}
+	if n := atomic.LoadInt64(&calls); n != 2 {
+		t.Fatalf("Unexpected calls. Expected: 2, Actual: %d", n)
+	}
+}
+
+func TestNoRetryOfOtherRequests(t *testing.T) {
+	for _, c := range []struct {
+		method string
+		body   string
+		err    error
+	}{
+		{"POST", "", syscall.ECONNRESET},
+		{"GET", "body", syscall.ECONNRESET},
+		{"GET", "", fmt.Errorf("malformed HTTP response")},
+	} {
+		calls := 0
+		rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return nil, c.err
+		})
+		h := NewTunnel("127.0.0.1", 8000, WithLocalBackend("http", rt)).httpHandler()
+
+		req := httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+		if w := serve(h, req); w.Code != http.StatusBadGateway {
+			t.Fatalf("Unexpected status of %s %q. Expected: %d, Actual: %d", c.method, c.body, http.StatusBadGateway, w.Code)
+		}
+		if calls != 1 {
+			t.Fatalf("Unexpected calls of %s %q (%v). Expected: 1, Actual: %d", c.method, c.body, c.err, calls)
+		}
+	}
+}
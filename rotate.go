@@ -0,0 +1,91 @@
+package localtunnel
+
+import "time"
+
+// EventRotated is emitted each time WithRotation moved the tunnel to a new
+// subdomain, with its URL, or with Err when the rotation failed.
+const EventRotated EventType = "rotated"
+
+// WithRotation moves the open tunnel to a new random subdomain every interval, for
+// short-lived URLs which are hard to guess. The new subdomain is registered before
+// the connections of the old one are closed, and the old registration is released
+// when the provider is a Releaser, so the old URL stops answering. A rotation which
+// fails leaves the tunnel on its subdomain until the next one.
+func WithRotation(interval time.Duration) Option {
+	return func(t *Tunnel) { t.rotateInterval = interval }
+}
+
+// rotate moves the tunnel to a new subdomain every rotateInterval until closeCh is
+// closed.
+func (t *Tunnel) rotate(closeCh <-chan struct{}) {
+	for {
+		select {
+		case <-closeCh:
+			return
+		case <-t.after(t.rotateInterval):
+		}
+
+		old, err := t.reregister(closeCh)
+		if err != nil {
+			t.emit(Event{Type: EventRotated, Err: err})
+			continue
+		}
+		if old == nil {
+			return
+		}
+
+		t.emit(Event{Type: EventRotated, URL: t.URL(), Target: t.MaxConn()})
+		t.release(old)
+	}
+}
+
+// reregister registers the tunnel on a new random subdomain, replacing its
+// connections, and returns the previous registration, or nil when the tunnel was
+// closed meanwhile. The registration runs without holding the tunnel's lock, under
+// the context of its connections, so Close cancels it instead of waiting for it.
+func (t *Tunnel) reregister(closeCh <-chan struct{}) (*Registration, error) {
+	t.sm.RLock()
+	ctx := t.ctx
+	t.sm.RUnlock()
+
+	r, err := t.setup(ctx, "")
+	if err != nil {
+		if !isOpen(closeCh) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if !isOpen(closeCh) {
+		t.release(r)
+		return nil, nil
+	}
+
+	t.sm.Lock()
+	old := &Registration{
+		Subdomain:  t.subdomain,
+		URL:        t.url,
+		RemoteHost: t.remoteHost,
+		RemotePort: t.remotePort,
+		MaxConn:    t.maxConn,
+	}
+	t.cancel()
+	close(t.retired)
+	t.register(r)
+	t.sm.Unlock()
+
+	t.establish()
+	return old, nil
+}
+
+// release releases the registration r when the provider of the tunnel is a Releaser.
+func (t *Tunnel) release(r *Registration) {
+	if p, err := t.c.getProvider(); err == nil {
+		if rel, ok := p.(Releaser); ok {
+			release(rel, r)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package localtunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// rotatingProvider registers each tunnel with the next of servers, on subdomains
+// sub0, sub1..., and reports the registrations it releases.
+type rotatingProvider struct {
+	servers  []*fakeServer
+	n        int
+	released chan *Registration
+}
+
+func (p *rotatingProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	if p.n == len(p.servers) {
+		return nil, fmt.Errorf("no server left")
+	}
+
+	s := p.servers[p.n]
+	r := &Registration{
+		Subdomain:  fmt.Sprintf("sub%d", p.n),
+		URL:        fmt.Sprintf("https://sub%d.loca.lt", p.n),
+		RemoteHost: "127.0.0.1",
+		RemotePort: s.ln.Addr().(*net.TCPAddr).Port,
+		MaxConn:    1,
+	}
+	p.n++
+	return r, nil
+}
+
+func (p *rotatingProvider) Release(ctx context.Context, r *Registration) error {
+	p.released <- r
+	return nil
+}
+
+func TestRotation(t *testing.T) {
+	first, second := newFakeServer(t, 1), newFakeServer(t, 1)
+	p := &rotatingProvider{servers: []*fakeServer{first, second}, released: make(chan *Registration, 2)}
+	clock := newFakeClock()
+	events := make(chan Event, 16)
+
+	tunnel := NewProviderClient(p).NewStreamTunnel(WithRotation(time.Hour), WithClock(clock), WithEvents(events))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	old := first.conn(t)
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+
+	for e := range events {
+		if e.Type == EventRotated {
+			if e.Err != nil || e.URL != "https://sub1.loca.lt" {
+				t.Fatalf("Unexpected rotation. Expected: https://sub1.loca.lt, Actual: %+v", e)
+			}
+			break
+		}
+	}
+	if tunnel.URL() != "https://sub1.loca.lt" || tunnel.Subdomain() != "sub1" {
+		t.Fatalf("Unexpected URL. Expected: https://sub1.loca.lt, Actual: %s", tunnel.URL())
+	}
+
+	second.conn(t)
+	old.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := old.Read(make([]byte, 1)); err == nil {
+		t.Fatal("The connection of the old subdomain should be closed")
+	}
+	select {
+	case r := <-p.released:
+		if r.Subdomain != "sub0" {
+			t.Fatalf("Unexpected released registration. Expected: sub0, Actual: %s", r.Subdomain)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The old registration should be released")
+	}
+
+	// the failed rotation keeps the current subdomain
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	for e := range events {
+		if e.Type == EventRotated {
+			if e.Err == nil {
+				t.Fatalf("Unexpected rotation. Expected an error, Actual: %+v", e)
+			}
+			break
+		}
+	}
+	if tunnel.URL() != "https://sub1.loca.lt" || tunnel.Err() != nil {
+		t.Fatalf("Tunnel should stay open on sub1 after a failed rotation. Actual: %s (%v)", tunnel.URL(), tunnel.Err())
+	}
+}
+
+// slowProvider registers the first tunnel right away, and blocks the next
+// registrations until their context is done.
+type slowProvider struct {
+	s       *fakeServer
+	n       int32
+	blocked chan struct{}
+}
+
+func (p *slowProvider) Register(ctx context.Context, subdomain string) (*Registration, error) {
+	if atomic.AddInt32(&p.n, 1) > 1 {
+		close(p.blocked)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return &Registration{
+		Subdomain:  "sub0",
+		URL:        "https://sub0.loca.lt",
+		RemoteHost: "127.0.0.1",
+		RemotePort: p.s.ln.Addr().(*net.TCPAddr).Port,
+		MaxConn:    1,
+	}, nil
+}
+
+func TestCloseDuringRotation(t *testing.T) {
+	p := &slowProvider{s: newFakeServer(t, 1), blocked: make(chan struct{})}
+	clock := newFakeClock()
+	events := make(chan Event, 16)
+
+	tunnel := NewProviderClient(p).NewStreamTunnel(WithRotation(time.Hour), WithClock(clock), WithEvents(events))
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	p.s.conn(t)
+	for clock.pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	<-p.blocked
+
+	done := make(chan struct{})
+	go func() {
+		tunnel.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should not wait for the rotation")
+	}
+	if tunnel.Err() != ErrClosed {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", ErrClosed, tunnel.Err())
+	}
+	for len(events) > 0 {
+		if e := <-events; e.Type == EventRotated {
+			t.Fatalf("Unexpected rotation event once closed: %+v", e)
+		}
+	}
+	checkNoGoroutines(t)
+}
@@ -0,0 +1,133 @@
+package localtunnel
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// An Action tells what to do with a request matched by a Rule.
+type Action string
+
+const (
+	Allow   Action = "allow"   // forward the request to the local server
+	Deny    Action = "deny"    // answer 403 Forbidden
+	Rewrite Action = "rewrite" // replace the matched path prefix and forward the request
+
+	// RewriteStatus forwards the request and replaces the statuses of the response
+	// listed in the rule's Statuses
+	RewriteStatus Action = "rewrite_status"
+)
+
+// A Rule matches requests by method, path and headers. Empty fields match any request.
+type Rule struct {
+	// Method matches the request method, case-insensitively.
+	Method string `json:"method,omitempty"`
+
+	// Path matches the requests whose path is Path or lies under it, e.g. "/admin"
+	// matches "/admin" and "/admin/users" but not "/administrator". The path of the
+	// request is cleaned first, so "//admin" and "/x/../admin" match "/admin" too.
+	Path string `json:"path,omitempty"`
+
+	// Header matches when every header has the given value. An empty value only
+	// requires the header to be present.
+	Header map[string]string `json:"header,omitempty"`
+
+	Action Action `json:"action"`
+
+	// To is the path replacing the matched prefix when Action is Rewrite.
+	To string `json:"to,omitempty"`
+
+	// Statuses maps the statuses of the local server to the ones sent instead when
+	// Action is RewriteStatus, e.g. 401 to 404 for public visitors.
+	Statuses map[int]int `json:"statuses,omitempty"`
+
+	// Body, when set, replaces the body of the responses whose status is rewritten,
+	// e.g. with a friendly error page. It is sent as HTML.
+	Body string `json:"body,omitempty"`
+}
+
+// Match reports whether the rule matches the request.
+func (r *Rule) Match(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+
+	if !hasPathPrefix(req.URL.Path, r.Path) {
+		return false
+	}
+
+	for k, v := range r.Header {
+		values, ok := req.Header[http.CanonicalHeaderKey(k)]
+		if !ok || (v != "" && !contains(values, v)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRules filters the requests through rules before they reach the local server.
+// The first matching rule decides the action; requests matching no rule are allowed.
+// It implies WithHTTPProxy.
+func WithRules(rules ...Rule) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				for i := range rules {
+					r := &rules[i]
+					if !r.Match(req) {
+						continue
+					}
+
+					switch r.Action {
+					case Deny:
+						http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+						return
+					case Rewrite:
+						req.URL.Path = r.To + strings.TrimPrefix(cleanPath(req.URL.Path), strings.TrimSuffix(r.Path, "/"))
+						req.URL.RawPath = ""
+					case RewriteStatus:
+						w = &statusWriter{ResponseWriter: w, rule: r}
+					}
+					break
+				}
+
+				next.ServeHTTP(w, req)
+			})
+		})
+	}
+}
+
+// hasPathPrefix reports whether the cleaned path is prefix or lies under it.
+func hasPathPrefix(p, prefix string) bool {
+	p = cleanPath(p)
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	return len(p) == len(prefix) || p[len(prefix)] == '/'
+}
+
+// cleanPath returns the canonical form of the request path p, resolving the "." and
+// ".." segments and the repeated slashes the local server may resolve as well, but
+// keeping the trailing slash.
+func cleanPath(p string) string {
+	if p == "" || p[0] != '/' {
+		p = "/" + p
+	}
+	c := path.Clean(p)
+	if c != "/" && strings.HasSuffix(p, "/") {
+		c += "/"
+	}
+	return c
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
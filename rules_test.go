@@ -0,0 +1,143 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithRules(
+		Rule{Path: "/admin", Action: Deny},
+		Rule{Method: "DELETE", Action: Deny},
+		Rule{Header: map[string]string{"X-Debug": ""}, Action: Deny},
+		Rule{Path: "/old/", Action: Rewrite, To: "/new"},
+	))
+
+	tests := []struct {
+		method string
+		path   string
+		header string
+		status int
+		body   string
+	}{
+		{"GET", "/", "", 200, "GET / "},
+		{"GET", "/admin", "", 403, ""},
+		{"GET", "/admin/users", "", 403, ""},
+		{"GET", "/administrator", "", 200, "GET /administrator "},
+		{"GET", "//admin", "", 403, ""},
+		{"GET", "/./admin", "", 403, ""},
+		{"GET", "/x/../admin", "", 403, ""},
+		{"GET", "/admin/", "", 403, ""},
+		{"GET", "/%61dmin", "", 403, ""},
+		{"GET", "/public/..//admin/users", "", 403, ""},
+		{"delete", "/posts/1", "", 403, ""},
+		{"GET", "/posts/1", "X-Debug", 403, ""},
+		{"GET", "/old/page", "", 200, "GET /new/page "},
+		{"GET", "/old", "", 200, "GET /new "},
+		{"GET", "//old/./page", "", 200, "GET /new/page "},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.header != "" {
+			req.Header.Set(test.header, "1")
+		}
+
+		w := serve(h, req)
+		if w.Code != test.status {
+			t.Fatalf("%s %s: unexpected status. Expected: %d, Actual: %d", test.method, test.path, test.status, w.Code)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Fatalf("%s %s: unexpected body. Expected: '%s', Actual: '%s'", test.method, test.path, test.body, w.Body)
+		}
+	}
+}
+
+func TestRuleMatchHeaderValue(t *testing.T) {
+	r := Rule{Header: map[string]string{"x-env": "prod"}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if r.Match(req) {
+		t.Fatal("Rule should not match a request without the header")
+	}
+
+	req.Header.Set("X-Env", "dev")
+	if r.Match(req) {
+		t.Fatal("Rule should not match a different header value")
+	}
+
+	req.Header.Set("X-Env", "prod")
+	if !r.Match(req) {
+		t.Fatal("Rule should match the header value")
+	}
+}
+
+func TestRewriteStatusRule(t *testing.T) {
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private", "/api/private":
+			http.Error(w, "login required", http.StatusUnauthorized)
+		case "/broken":
+			http.Error(w, "panic: nil pointer", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}), WithRules(
+		Rule{Path: "/api", Action: Allow},
+		Rule{Action: RewriteStatus, Statuses: map[int]int{401: 404}},
+		Rule{Action: RewriteStatus, Statuses: map[int]int{500: 503}, Body: "<h1>Back soon</h1>"},
+	))
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", 200, "ok"},
+		{"/private", 404, "login required\n"},
+		{"/api/private", 401, "login required\n"},
+		// only the first matching rule applies
+		{"/broken", 500, "panic: nil pointer\n"},
+	}
+
+	for _, test := range tests {
+		w := serve(h, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Fatalf("%s: unexpected response. Expected: %d '%s', Actual: %d '%s'", test.path, test.status, test.body, w.Code, w.Body)
+		}
+	}
+
+	h = tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "panic: nil pointer", http.StatusInternalServerError)
+	}), WithRules(Rule{Action: RewriteStatus, Statuses: map[int]int{500: 503}, Body: "<h1>Back soon</h1>"}))
+
+	w := serve(h, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 503 || w.Body.String() != "<h1>Back soon</h1>" || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("Unexpected response. Expected: 503 <h1>Back soon</h1>, Actual: %d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+// codesWriter records the statuses written to it.
+type codesWriter struct {
+	*httptest.ResponseRecorder
+	codes []int
+}
+
+func (w *codesWriter) WriteHeader(code int) {
+	w.codes = append(w.codes, code)
+	if code >= 200 {
+		w.ResponseRecorder.WriteHeader(code)
+	}
+}
+
+func TestRewriteStatusAfterEarlyHints(t *testing.T) {
+	w := &codesWriter{ResponseRecorder: httptest.NewRecorder()}
+	sw := &statusWriter{ResponseWriter: w, rule: &Rule{Action: RewriteStatus, Statuses: map[int]int{401: 404}}}
+
+	sw.WriteHeader(http.StatusEarlyHints)
+	sw.WriteHeader(http.StatusUnauthorized)
+	if len(w.codes) != 2 || w.codes[0] != http.StatusEarlyHints || w.codes[1] != http.StatusNotFound {
+		t.Fatalf("Unexpected statuses. Expected: [103 404], Actual: %v", w.codes)
+	}
+}
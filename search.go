@@ -0,0 +1,86 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A Query selects captured requests. Zero fields match any request.
+type Query struct {
+	// Path matches the requests whose path is Path or lies under it.
+	Path string
+
+	// Method matches the request method, case-insensitively.
+	Method string
+
+	// Status matches the response status.
+	Status int
+
+	// Since and Until bound the time the requests were received.
+	Since time.Time
+	Until time.Time
+
+	// Header matches when every request header has the given value. An empty
+	// value only requires the header to be present.
+	Header map[string]string
+
+	// Limit is the maximum number of requests returned, the most recent ones.
+	Limit int
+}
+
+// Match reports whether the query selects the record.
+func (q *Query) Match(r *RequestRecord) bool {
+	if q.Method != "" && !strings.EqualFold(q.Method, r.Method) {
+		return false
+	}
+
+	if q.Status != 0 && q.Status != r.Status {
+		return false
+	}
+
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && r.Time.After(q.Until) {
+		return false
+	}
+
+	if q.Path != "" {
+		u, err := url.ParseRequestURI(r.URL)
+		if err != nil || !hasPathPrefix(u.Path, q.Path) {
+			return false
+		}
+	}
+
+	for k, v := range q.Header {
+		values, ok := r.RequestHeader[http.CanonicalHeaderKey(k)]
+		if !ok || (v != "" && !contains(values, v)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Search returns the captured requests selected by q, oldest first.
+func (c *Capture) Search(q Query) ([]RequestRecord, error) {
+	records, err := c.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []RequestRecord
+	for i := range records {
+		if q.Match(&records[i]) {
+			found = append(found, records[i])
+		}
+	}
+
+	if q.Limit > 0 && len(found) > q.Limit {
+		found = found[len(found)-q.Limit:]
+	}
+	return found, nil
+}
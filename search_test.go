@@ -0,0 +1,55 @@
+package localtunnel
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	now := time.Now()
+	c := &Capture{Store: NewMemoryStore(Retention{})}
+	for _, r := range []RequestRecord{
+		{Time: now.Add(-3 * time.Hour), Method: "POST", URL: "/hooks/github", Status: 200, RequestHeader: http.Header{"X-Github-Event": {"push"}}},
+		{Time: now.Add(-2 * time.Hour), Method: "POST", URL: "/hooks/github?retry=1", Status: 500, RequestHeader: http.Header{"X-Github-Event": {"issues"}}},
+		{Time: now.Add(-1 * time.Hour), Method: "GET", URL: "/hooks", Status: 200},
+		{Time: now, Method: "GET", URL: "/hooksy", Status: 404},
+	} {
+		r := r
+		c.Store.Add(&r)
+	}
+
+	tests := []struct {
+		query Query
+		ids   []int64
+	}{
+		{Query{}, []int64{1, 2, 3, 4}},
+		{Query{Path: "/hooks"}, []int64{1, 2, 3}},
+		{Query{Path: "/hooks/github", Method: "post"}, []int64{1, 2}},
+		{Query{Status: 200}, []int64{1, 3}},
+		{Query{Since: now.Add(-150 * time.Minute), Until: now.Add(-30 * time.Minute)}, []int64{2, 3}},
+		{Query{Header: map[string]string{"x-github-event": "push"}}, []int64{1}},
+		{Query{Header: map[string]string{"X-Github-Event": ""}}, []int64{1, 2}},
+		{Query{Limit: 2}, []int64{3, 4}},
+	}
+
+	for _, test := range tests {
+		found, err := c.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int64
+		for _, r := range found {
+			ids = append(ids, r.ID)
+		}
+		if len(ids) != len(test.ids) {
+			t.Fatalf("%+v: unexpected results. Expected: %v, Actual: %v", test.query, test.ids, ids)
+		}
+		for i := range ids {
+			if ids[i] != test.ids[i] {
+				t.Fatalf("%+v: unexpected results. Expected: %v, Actual: %v", test.query, test.ids, ids)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package localtunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer mimics a localtunnel server: an HTTP API for registering tunnels
+// and a TCP listener receiving the tunnel's connections.
+type fakeServer struct {
+	*httptest.Server
+	ln      net.Listener
+	maxConn int
+	conns   chan net.Conn
+
+	m       sync.Mutex
+	tunnels map[string]bool
+}
+
+func newFakeServer(t testing.TB, maxConn int) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{ln: ln, maxConn: maxConn, conns: make(chan net.Conn, 100), tunnels: map[string]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", s.status)
+	mux.HandleFunc("/api/tunnels/", s.tunnelStatus)
+	mux.HandleFunc("/", s.register)
+	s.Server = httptest.NewServer(mux)
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- c
+		}
+	}()
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) register(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/")
+	if id == "" {
+		id = "fakesubdomain"
+	}
+
+	s.m.Lock()
+	s.tunnels[id] = true
+	s.m.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             id,
+		"url":            fmt.Sprintf("https://%s.loca.lt", id),
+		"port":           s.ln.Addr().(*net.TCPAddr).Port,
+		"max_conn_count": s.maxConn,
+	})
+}
+
+func (s *fakeServer) status(w http.ResponseWriter, r *http.Request) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tunnels": len(s.tunnels),
+		"mem":     map[string]int64{"rss": 1024, "heapTotal": 512, "heapUsed": 256, "external": 128},
+	})
+}
+
+func (s *fakeServer) tunnelStatus(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/tunnels/"), "/status")
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if !s.tunnels[id] {
+		http.Error(w, "404", http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int{"connected_sockets": s.maxConn})
+}
+
+func (s *fakeServer) Close() {
+	s.Server.Close()
+	s.ln.Close()
+}
+
+// conn waits for the next connection opened by the tunnel.
+func (s *fakeServer) conn(t testing.TB) net.Conn {
+	select {
+	case c := <-s.conns:
+		t.Cleanup(func() { c.Close() })
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for a tunnel connection")
+		return nil
+	}
+}
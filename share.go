@@ -0,0 +1,68 @@
+package localtunnel
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// TokenParam is the query parameter carrying a share token.
+	TokenParam = "lt_token"
+
+	// TokenHeader is the header carrying a share token.
+	TokenHeader = "X-Lt-Token"
+)
+
+var errNoSignedAccess = errors.New("localtunnel: tunnel has no signed access")
+
+// WithSignedAccess only lets in requests carrying a token generated by ShareURL and
+// signed with secret, either in the lt_token query parameter or in the X-Lt-Token
+// header. A random secret is used when secret is empty. It implies WithHTTPProxy.
+func WithSignedAccess(secret []byte) Option {
+	return func(t *Tunnel) {
+		t.shareSecret = secret
+		t.signedAccess = true
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token := r.Header.Get(TokenHeader)
+				if q := r.URL.Query(); q.Get(TokenParam) != "" {
+					token = q.Get(TokenParam)
+					q.Del(TokenParam)
+					r.URL.RawQuery = q.Encode()
+
+					// browsers keep the token to fetch the page's resources
+					http.SetCookie(w, &http.Cookie{Name: TokenParam, Value: token, Path: "/", HttpOnly: true})
+				} else if c, err := r.Cookie(TokenParam); token == "" && err == nil {
+					token = c.Value
+				}
+
+				if _, ok := verifyValue(t.shareSecret, sharePurpose, token, t.now()); !ok {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+
+				// the token grants access to the tunnel, the local server has no use for it
+				r.Header.Del(TokenHeader)
+				removeCookie(r, TokenParam)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+// ShareURL returns the tunnel's URL with a token granting access for ttl.
+// The tunnel must be open and configured with WithSignedAccess.
+func (t *Tunnel) ShareURL(ttl time.Duration) (string, error) {
+	if !t.signedAccess {
+		return "", errNoSignedAccess
+	}
+
+	if t.URL() == "" {
+		return "", ErrClosed
+	}
+
+	token := signValue(t.shareSecret, sharePurpose, "share", t.now().Add(ttl))
+	return t.URL() + "/?" + TokenParam + "=" + url.QueryEscape(token), nil
+}
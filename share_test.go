@@ -0,0 +1,145 @@
+package localtunnel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestShareURL(t *testing.T) {
+	s := newFakeServer(t, 1)
+	tunnel := NewClient(s.URL).NewLocalTunnel(8000, WithSignedAccess([]byte("secret")))
+
+	if _, err := tunnel.ShareURL(time.Hour); err != ErrClosed {
+		t.Fatalf("Unexpected error before open. Expected: %s, Actual: %v", ErrClosed, err)
+	}
+
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	share, err := tunnel.ShareURL(time.Hour)
+	if err != nil {
+		t.Fatalf("Cannot create share URL: %s", err)
+	}
+
+	u, err := url.Parse(share)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "fakesubdomain.loca.lt" || u.Query().Get(TokenParam) == "" {
+		t.Fatalf("Unexpected share URL: %s", share)
+	}
+
+	if _, err := NewLocalTunnel(8000).ShareURL(time.Hour); err != errNoSignedAccess {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", errNoSignedAccess, err)
+	}
+}
+
+func TestSignedAccess(t *testing.T) {
+	secret := []byte("secret")
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}), WithSignedAccess(secret))
+
+	valid := url.QueryEscape(signValue(secret, sharePurpose, "share", time.Now().Add(time.Hour)))
+	expired := url.QueryEscape(signValue(secret, sharePurpose, "share", time.Now().Add(-time.Hour)))
+
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("Request without token should be forbidden. Actual: %d", w.Code)
+	}
+
+	if w := serve(h, httptest.NewRequest("GET", "/?lt_token="+expired, nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("Request with expired token should be forbidden. Actual: %d", w.Code)
+	}
+
+	w := serve(h, httptest.NewRequest("GET", "/?a=1&lt_token="+valid, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Request with token should be allowed. Actual: %d", w.Code)
+	}
+	if w.Body.String() != "a=1" {
+		t.Fatalf("Token should be removed from the query. Actual: %s", w.Body)
+	}
+
+	req := httptest.NewRequest("GET", "/style.css", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	if w := serve(h, req); w.Code != http.StatusOK {
+		t.Fatalf("Request with token cookie should be allowed. Actual: %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set(TokenHeader, signValue(secret, sharePurpose, "share", time.Now().Add(time.Hour)))
+	if w := serve(h, req); w.Code != http.StatusOK {
+		t.Fatalf("Request with token header should be allowed. Actual: %d", w.Code)
+	}
+}
+
+func TestSignedAccessTokenNotForwarded(t *testing.T) {
+	secret := []byte("secret")
+	h := tunnelHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s", r.Header.Get(TokenHeader), r.Header.Get("Cookie"))
+	}), WithSignedAccess(secret))
+	token := signValue(secret, sharePurpose, "share", time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(TokenHeader, token)
+	req.AddCookie(&http.Cookie{Name: TokenParam, Value: token})
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	if w := serve(h, req); w.Code != http.StatusOK || w.Body.String() != "|theme=dark" {
+		t.Fatalf("Unexpected token for the local server. Expected: |theme=dark, Actual: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestTemporaryAccess(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer local.Close()
+
+	clock := newFakeClock()
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithClock(clock))
+	err := tunnel.TemporaryAccess(time.Hour)
+	if err != nil {
+		t.Fatalf("Cannot set access window: %s", err)
+	}
+	if !tunnel.proxy {
+		t.Fatalf("Temporary access should switch the tunnel to the HTTP proxy mode")
+	}
+	h := tunnel.httpHandler()
+
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Request within the window should be allowed. Actual: %d", w.Code)
+	}
+
+	clock.Advance(time.Hour)
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("Request after the window should be forbidden. Actual: %d", w.Code)
+	}
+
+	tunnel.TemporaryAccess(time.Minute)
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusOK {
+		t.Fatalf("Request within the reopened window should be allowed. Actual: %d", w.Code)
+	}
+
+	tunnel.TemporaryAccess(0)
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusForbidden {
+		t.Fatalf("Request after revoking the access should be forbidden. Actual: %d", w.Code)
+	}
+}
+
+func TestTemporaryAccessAfterOpen(t *testing.T) {
+	s := newFakeServer(t, 1)
+	tunnel := NewClient(s.URL).NewLocalTunnel(8000)
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	if err := tunnel.TemporaryAccess(time.Hour); err != errWindowAfterOpen {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", errWindowAfterOpen, err)
+	}
+}
@@ -0,0 +1,159 @@
+package localtunnel
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// finish returns the captured body, redacted, either in memory or as the name of
+// the file it was spooled to.
+func (c *Capture) finish(b *bodyCapture) ([]byte, string) {
+	if b.f == nil {
+		return c.redactBody(b.buf.Bytes()), ""
+	}
+
+	name := b.f.Name()
+	err := b.f.Close()
+	if err == nil && len(c.RedactBody) > 0 {
+		err = c.redactFile(name)
+	}
+	if err != nil {
+		os.Remove(name)
+		return nil, ""
+	}
+	return nil, name
+}
+
+const (
+	// redactChunkSize bounds the memory used to redact a spooled body: longer lines,
+	// as in minified JSON, are redacted a chunk at a time.
+	redactChunkSize = 64 << 10
+	// redactOverlap is kept from a chunk to the next, so the matches spanning them
+	// are found unless longer than it.
+	redactOverlap = 4 << 10
+)
+
+// redactFile redacts a spooled body line by line, or chunk by chunk for the lines
+// longer than redactChunkSize, so it is never fully loaded in memory.
+func (c *Capture) redactFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(c.SpoolDir, "lt-body-")
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReaderSize(in, redactChunkSize)
+	w := bufio.NewWriter(out)
+	var pending []byte
+	for {
+		line, err := r.ReadSlice('\n')
+		pending = append(pending, line...)
+		if err == bufio.ErrBufferFull {
+			if len(pending) >= redactChunkSize+redactOverlap {
+				pending = c.redactChunk(w, pending)
+			}
+			continue
+		}
+
+		w.Write(c.redactBody(pending))
+		pending = pending[:0]
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+			return err
+		}
+	}
+
+	err = w.Flush()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Rename(out.Name(), name)
+}
+
+// redactChunk writes the redacted start of b, keeping its last redactOverlap bytes,
+// or more for a match spanning them, and returns what was kept.
+func (c *Capture) redactChunk(w io.Writer, b []byte) []byte {
+	cut := len(b) - redactOverlap
+	for moved := true; moved; {
+		moved = false
+		for _, re := range c.RedactBody {
+			for _, m := range re.FindAllIndex(b, -1) {
+				if m[0] < cut && m[1] > cut {
+					cut, moved = m[1], true
+				}
+			}
+		}
+	}
+
+	w.Write(c.redactBody(b[:cut]))
+	return append(b[:0], b[cut:]...)
+}
+
+// OpenRequestBody returns the captured request body, whether kept in memory or spooled to disk.
+func (r *RequestRecord) OpenRequestBody() (io.ReadCloser, error) {
+	body, _, err := openBody(r.RequestBody, r.RequestBodyFile)
+	return body, err
+}
+
+// OpenResponseBody returns the captured response body, whether kept in memory or spooled to disk.
+func (r *RequestRecord) OpenResponseBody() (io.ReadCloser, error) {
+	body, _, err := openBody(r.ResponseBody, r.ResponseBodyFile)
+	return body, err
+}
+
+// openBody returns a captured body and its size.
+func openBody(b []byte, file string) (io.ReadCloser, int64, error) {
+	if file == "" {
+		return ioutil.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// readBody returns a captured body fully loaded in memory.
+func readBody(b []byte, file string) []byte {
+	if file == "" {
+		return b
+	}
+
+	b, _ = ioutil.ReadFile(file)
+	return b
+}
+
+// removeBodies removes the spooled bodies of records no longer stored.
+func removeBodies(records []RequestRecord) {
+	for _, r := range records {
+		if r.RequestBodyFile != "" {
+			os.Remove(r.RequestBodyFile)
+		}
+		if r.ResponseBodyFile != "" {
+			os.Remove(r.ResponseBodyFile)
+		}
+	}
+}
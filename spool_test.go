@@ -0,0 +1,87 @@
+package localtunnel
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCaptureSpool(t *testing.T) {
+	dir := t.TempDir()
+	c := &Capture{
+		MaxBodySize:    -1,
+		SpoolThreshold: 16,
+		SpoolDir:       dir,
+		RedactBody:     []*regexp.Regexp{CardNumbers},
+		Store:          NewMemoryStore(Retention{MaxRecords: 1}),
+	}
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithCapture(c))
+
+	upload := strings.Repeat("x", 100) + "\ncard=4111 1111 1111 1111\n"
+	serve(h, httptest.NewRequest("POST", "/upload", strings.NewReader(upload)))
+
+	records, _ := c.Records()
+	r := records[0]
+	if r.RequestBody != nil || filepath.Dir(r.RequestBodyFile) != dir {
+		t.Fatalf("Request body should be spooled to %s. Actual: %q", dir, r.RequestBodyFile)
+	}
+	if r.ResponseBodyFile == "" {
+		t.Fatal("Response body should be spooled")
+	}
+
+	body, err := r.OpenRequestBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(body)
+	body.Close()
+	expected := strings.Repeat("x", 100) + "\ncard=[REDACTED]\n"
+	if string(b) != expected {
+		t.Fatalf("Unexpected request body. Expected: %q, Actual: %q", expected, b)
+	}
+
+	serve(h, httptest.NewRequest("POST", "/small", strings.NewReader("tiny")))
+	if _, err := os.Stat(r.RequestBodyFile); !os.IsNotExist(err) {
+		t.Fatal("Spooled bodies of dropped records should be removed")
+	}
+
+	records, _ = c.Records()
+	if string(records[0].RequestBody) != "tiny" || records[0].RequestBodyFile != "" {
+		t.Fatalf("Small bodies should stay in memory. Actual: %q %q", records[0].RequestBody, records[0].RequestBodyFile)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Fatalf("Unexpected spooled files: %d", len(files))
+	}
+}
+
+func TestRedactLongLines(t *testing.T) {
+	c := &Capture{RedactBody: []*regexp.Regexp{CardNumbers}, SpoolDir: t.TempDir()}
+
+	// a single line of several chunks, with a card number across the end of the first
+	body := strings.Repeat("x", 2*redactChunkSize-redactOverlap-8) + ",4111 1111 1111 1111," +
+		strings.Repeat("x", 2*redactChunkSize) + ",4111 1111 1111 1111"
+	name := filepath.Join(c.SpoolDir, "body")
+	err := ioutil.WriteFile(name, []byte(body), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.redactFile(name)
+	if err != nil {
+		t.Fatalf("Cannot redact the body: %s", err)
+	}
+
+	b, _ := ioutil.ReadFile(name)
+	expected := string(c.redactBody([]byte(body)))
+	if string(b) != expected {
+		t.Fatalf("Unexpected redacted body. Expected %d bytes with %d redactions, Actual: %d bytes with %d",
+			len(expected), strings.Count(expected, Redacted), len(b), strings.Count(string(b), Redacted))
+	}
+}
@@ -0,0 +1,68 @@
+package localtunnel
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore keeps the values in a table of a SQLite database, opened with the
+// database/sql driver of the embedder's choice, such as modernc.org/sqlite or
+// github.com/mattn/go-sqlite3, which this package does not depend on.
+type SQLStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLStore returns the store kept in the named table of db, creating the table
+// when missing.
+func NewSQLStore(db *sql.DB, table string) (*SQLStore, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("localtunnel: invalid table name %q", table)
+	}
+
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS " + table + " (name TEXT PRIMARY KEY, value BLOB NOT NULL)")
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, table: table}, nil
+}
+
+func (s *SQLStore) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow("SELECT value FROM "+s.table+" WHERE name = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *SQLStore) Put(key string, value []byte) error {
+	_, err := s.db.Exec("INSERT OR REPLACE INTO "+s.table+" (name, value) VALUES (?, ?)", key, value)
+	return err
+}
+
+func (s *SQLStore) Delete(key string) error {
+	_, err := s.db.Exec("DELETE FROM "+s.table+" WHERE name = ?", key)
+	return err
+}
+
+func (s *SQLStore) List(prefix string) ([]string, error) {
+	rows, err := s.db.Query("SELECT name FROM "+s.table+" WHERE substr(name, 1, ?) = ? ORDER BY name", len([]rune(prefix)), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
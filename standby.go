@@ -0,0 +1,99 @@
+package localtunnel
+
+import (
+	"errors"
+	"time"
+)
+
+// EventSwitched is emitted by a Manager when a standby tunnel takes over from its
+// primary.
+const EventSwitched EventType = "switched"
+
+var (
+	// ErrNotManaged is returned when a tunnel is not managed by the Manager.
+	ErrNotManaged = errors.New("localtunnel: tunnel not managed")
+
+	// ErrNoStandby is returned when switching to a standby which is not open.
+	ErrNoStandby = errors.New("localtunnel: no standby tunnel open")
+)
+
+// OpenStandby opens standby as a warm replacement for the managed tunnel primary,
+// typically forwarding to the same local server through a second server or
+// subdomain. A previous standby of primary is closed.
+func (m *Manager) OpenStandby(primary, standby *Tunnel, subdomain string) error {
+	m.m.Lock()
+	i := m.index(primary)
+	m.m.Unlock()
+	if i < 0 {
+		return ErrNotManaged
+	}
+
+	s := managed{t: standby, subdomain: subdomain}
+	err := s.open()
+	if err != nil {
+		return err
+	}
+
+	// primary may have been switched meanwhile
+	m.m.Lock()
+	i = m.index(primary)
+	if i < 0 {
+		m.m.Unlock()
+		standby.Close()
+		return ErrNotManaged
+	}
+	previous := m.entries[i].standby
+	m.entries[i].standby = &s
+	m.m.Unlock()
+
+	if previous != nil {
+		previous.t.Close()
+	}
+	return nil
+}
+
+// Switch makes the standby of primary take its place among the managed tunnels,
+// then closes primary. Events, when set, receives an EventSwitched with the URL of
+// the standby, so listeners can retarget to it.
+func (m *Manager) Switch(primary *Tunnel) (*Tunnel, error) {
+	m.m.Lock()
+	i := m.index(primary)
+	if i < 0 {
+		m.m.Unlock()
+		return nil, ErrNotManaged
+	}
+
+	s := m.entries[i].standby
+	if s == nil || s.t.URL() == "" {
+		m.m.Unlock()
+		return nil, ErrNoStandby
+	}
+	m.entries[i] = *s
+	m.m.Unlock()
+
+	m.emit(Event{Type: EventSwitched, URL: s.t.URL()})
+	primary.Close()
+	return s.t, nil
+}
+
+// index returns the position of the entry of t, or -1. It is called with m.m held.
+func (m *Manager) index(t *Tunnel) int {
+	for i, e := range m.entries {
+		if e.t == t {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Manager) emit(e Event) {
+	if m.Events == nil {
+		return
+	}
+
+	e.Time = time.Now()
+	select {
+	case m.Events <- e:
+	default:
+	}
+}
@@ -0,0 +1,351 @@
+package localtunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Store for a missing key.
+var ErrNotFound = errors.New("localtunnel: key not found")
+
+// A Store keeps state as values under keys: the requests recorded by CapturesIn,
+// the subdomains remembered by WithSubdomainStore and the account and certificates
+// of ACME. Keys are made of segments separated by "/". MapStore, DirStore and
+// SQLStore are provided, and embedders may supply their own, e.g. backed by Redis.
+// Its methods may be called concurrently.
+type Store interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(key string) ([]byte, error)
+
+	// Put sets the value of key.
+	Put(key string, value []byte) error
+
+	// Delete removes key, if present.
+	Delete(key string) error
+
+	// List returns the keys starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+}
+
+// MapStore keeps the values in memory.
+type MapStore struct {
+	m      sync.Mutex
+	values map[string][]byte
+}
+
+// NewMapStore returns an empty MapStore.
+func NewMapStore() *MapStore {
+	return &MapStore{values: map[string][]byte{}}
+}
+
+func (s *MapStore) Get(key string) ([]byte, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	v, ok := s.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (s *MapStore) Put(key string, value []byte) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (s *MapStore) Delete(key string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.values, key)
+	return nil
+}
+
+func (s *MapStore) List(prefix string) ([]string, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var keys []string
+	for k := range s.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// DirStore keeps each value in a file of a directory, the segments of its key
+// being subdirectories, escaped so any key makes a valid file name within the
+// directory: "." and ".." segments are escaped too, never leading out of it.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns the store kept in dir, created when needed.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+func (s *DirStore) path(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+		if seg == "." || seg == ".." {
+			segments[i] = strings.Replace(seg, ".", "%2E", -1)
+		}
+	}
+	return filepath.Join(append([]string{s.dir}, segments...)...)
+}
+
+func (s *DirStore) Get(key string) ([]byte, error) {
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// Put writes the value to a temporary file first, so a crash never leaves it half
+// written.
+func (s *DirStore) Put(key string, value []byte) error {
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, value, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *DirStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *DirStore) List(prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		segments := strings.Split(filepath.ToSlash(rel), "/")
+		for i, seg := range segments {
+			if segments[i], err = url.PathUnescape(seg); err != nil {
+				return nil // not written by the store
+			}
+		}
+		if key := strings.Join(segments, "/"); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// capturesPrefix is the prefix of the keys of the requests stored by CapturesIn.
+const capturesPrefix = "captures/"
+
+// storeCaptures is a CaptureStore keeping each request under a key of a Store.
+type storeCaptures struct {
+	s         Store
+	retention Retention
+
+	m      sync.Mutex
+	lastID int64
+	ids    []int64 // of the requests stored, oldest first
+}
+
+// CapturesIn returns a CaptureStore keeping the recorded requests in s, under
+// "captures/", so they survive restarts when s does.
+func CapturesIn(s Store, r Retention) (CaptureStore, error) {
+	keys, err := s.List(capturesPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &storeCaptures{s: s, retention: r}
+	for _, key := range keys {
+		var id int64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(key, capturesPrefix), "%d", &id); err == nil {
+			c.ids = append(c.ids, id)
+		}
+	}
+	if len(c.ids) > 0 {
+		c.lastID = c.ids[len(c.ids)-1]
+	}
+	return c, nil
+}
+
+// captureKey sorts the keys of the requests by ID.
+func captureKey(id int64) string {
+	return fmt.Sprintf("%s%020d", capturesPrefix, id)
+}
+
+func (c *storeCaptures) Add(r *RequestRecord) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.lastID++
+	r.ID = c.lastID
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	err = c.s.Put(captureKey(r.ID), b)
+	if err != nil {
+		return err
+	}
+
+	c.ids = append(c.ids, r.ID)
+	return c.trim()
+}
+
+// trim deletes the oldest requests until the retention is met, only reading the ones
+// whose age decides it. It is called with m held.
+func (c *storeCaptures) trim() error {
+	for len(c.ids) > 0 {
+		over := c.retention.MaxRecords > 0 && len(c.ids) > c.retention.MaxRecords
+		if !over && c.retention.MaxAge <= 0 {
+			return nil
+		}
+
+		r, err := c.get(c.ids[0])
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if !over && err == nil && !r.Time.Before(time.Now().Add(-c.retention.MaxAge)) {
+			return nil
+		}
+
+		if err := c.s.Delete(captureKey(c.ids[0])); err != nil {
+			return err
+		}
+		if r != nil {
+			removeBodies([]RequestRecord{*r})
+		}
+		c.ids = c.ids[1:]
+	}
+	return nil
+}
+
+// get returns the request stored with id, or ErrNotFound.
+func (c *storeCaptures) get(id int64) (*RequestRecord, error) {
+	b, err := c.s.Get(captureKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var r RequestRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (c *storeCaptures) Records() ([]RequestRecord, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.records()
+}
+
+// records returns the retained requests, deleting the others. It is called with m
+// held.
+func (c *storeCaptures) records() ([]RequestRecord, error) {
+	if err := c.trim(); err != nil {
+		return nil, err
+	}
+
+	records := make([]RequestRecord, 0, len(c.ids))
+	for _, id := range c.ids {
+		r, err := c.get(id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *r)
+	}
+	return records, nil
+}
+
+func (c *storeCaptures) Reset() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	records, err := c.records()
+	if err != nil {
+		return err
+	}
+	for _, id := range c.ids {
+		if err := c.s.Delete(captureKey(id)); err != nil {
+			return err
+		}
+	}
+	removeBodies(records)
+	c.ids = nil
+	return nil
+}
+
+// WithSubdomainStore remembers the subdomain assigned to the tunnel in s, under
+// "subdomains/" followed by key, and requests it again when the tunnel is opened
+// without a subdomain, so its URL survives restarts while the server keeps it free.
+func WithSubdomainStore(s Store, key string) Option {
+	return func(t *Tunnel) {
+		t.subdomainStore = s
+		t.subdomainKey = "subdomains/" + key
+	}
+}
+
+// rememberedSubdomain returns subdomain, or else the one remembered by
+// WithSubdomainStore.
+func (t *Tunnel) rememberedSubdomain(subdomain string) string {
+	if subdomain != "" || t.subdomainStore == nil {
+		return subdomain
+	}
+	b, _ := t.subdomainStore.Get(t.subdomainKey)
+	return string(b)
+}
+
+// rememberSubdomain keeps the subdomain assigned to the tunnel, when remembered by
+// WithSubdomainStore.
+func (t *Tunnel) rememberSubdomain(subdomain string) {
+	if t.subdomainStore != nil && subdomain != "" {
+		t.subdomainStore.Put(t.subdomainKey, []byte(subdomain))
+	}
+}
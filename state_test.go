@@ -0,0 +1,284 @@
+package localtunnel
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore checks the behavior common to all the stores.
+func testStore(t *testing.T, s Store) {
+	if _, err := s.Get("missing"); err != ErrNotFound {
+		t.Fatalf("Unexpected error of a missing key. Expected: %v, Actual: %v", ErrNotFound, err)
+	}
+
+	for _, key := range []string{"a/1", "a/2", "b", "a/sub/3", "odd:key?/x y"} {
+		if err := s.Put(key, []byte("value of "+key)); err != nil {
+			t.Fatalf("Cannot put %s: %s", key, err)
+		}
+	}
+	if err := s.Put("b", []byte("new")); err != nil {
+		t.Fatalf("Cannot replace b: %s", err)
+	}
+
+	if v, err := s.Get("b"); err != nil || string(v) != "new" {
+		t.Fatalf("Unexpected value of b. Expected: new, Actual: %q (%v)", v, err)
+	}
+	if v, err := s.Get("odd:key?/x y"); err != nil || string(v) != "value of odd:key?/x y" {
+		t.Fatalf("Unexpected value of an odd key. Actual: %q (%v)", v, err)
+	}
+
+	keys, err := s.List("a/")
+	if err != nil || !reflect.DeepEqual(keys, []string{"a/1", "a/2", "a/sub/3"}) {
+		t.Fatalf("Unexpected keys. Expected: [a/1 a/2 a/sub/3], Actual: %v (%v)", keys, err)
+	}
+
+	if err := s.Delete("a/1"); err != nil {
+		t.Fatalf("Cannot delete a/1: %s", err)
+	}
+	if err := s.Delete("a/1"); err != nil {
+		t.Fatalf("Deleting a missing key should not fail: %s", err)
+	}
+	if _, err := s.Get("a/1"); err != ErrNotFound {
+		t.Fatalf("Unexpected error of a deleted key. Expected: %v, Actual: %v", ErrNotFound, err)
+	}
+	if keys, _ := s.List(""); len(keys) != 4 {
+		t.Fatalf("Unexpected keys. Expected: 4, Actual: %v", keys)
+	}
+}
+
+func TestMapStore(t *testing.T) {
+	testStore(t, NewMapStore())
+}
+
+func TestDirStore(t *testing.T) {
+	testStore(t, NewDirStore(t.TempDir()))
+
+	// dot segments stay within the directory
+	parent := t.TempDir()
+	s := NewDirStore(filepath.Join(parent, "state"))
+	for _, key := range []string{"..", "x/../escaped", "a/../../escaped", "./b", "a/."} {
+		if err := s.Put(key, []byte(key)); err != nil {
+			t.Fatalf("Cannot put %s: %s", key, err)
+		}
+		if v, err := s.Get(key); err != nil || string(v) != key {
+			t.Fatalf("Unexpected value of %s. Expected: %s, Actual: %q (%v)", key, key, v, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("A key should not lead out of the directory: %v", err)
+	}
+	if keys, _ := s.List(""); len(keys) != 5 {
+		t.Fatalf("Unexpected keys. Expected: 5, Actual: %v", keys)
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+	db := sql.OpenDB(fakeSQLConnector{&fakeTable{rows: map[string][]byte{}}})
+	defer db.Close()
+
+	if _, err := NewSQLStore(db, "state; DROP TABLE x"); err == nil {
+		t.Fatal("Invalid table names should be rejected")
+	}
+	s, err := NewSQLStore(db, "lt_state")
+	if err != nil {
+		t.Fatalf("Cannot create the store: %s", err)
+	}
+	testStore(t, s)
+}
+
+func TestCapturesIn(t *testing.T) {
+	db := sql.OpenDB(fakeSQLConnector{&fakeTable{rows: map[string][]byte{}}})
+	defer db.Close()
+	sqlStore, err := NewSQLStore(db, "lt_state")
+	if err != nil {
+		t.Fatalf("Cannot create the store: %s", err)
+	}
+
+	for name, s := range map[string]Store{"map": NewMapStore(), "dir": NewDirStore(t.TempDir()), "sql": sqlStore} {
+		t.Run(name, func(t *testing.T) { testCapturesIn(t, s) })
+	}
+}
+
+func testCapturesIn(t *testing.T, s Store) {
+	c, err := CapturesIn(s, Retention{MaxRecords: 2})
+	if err != nil {
+		t.Fatalf("Cannot create the captures: %s", err)
+	}
+
+	for _, path := range []string{"/1", "/2", "/3"} {
+		if err := c.Add(&RequestRecord{Time: time.Now(), URL: path}); err != nil {
+			t.Fatalf("Cannot add %s: %s", path, err)
+		}
+	}
+
+	// reopened, as after a restart
+	c, err = CapturesIn(s, Retention{MaxRecords: 2})
+	if err != nil {
+		t.Fatalf("Cannot reopen the captures: %s", err)
+	}
+	c.Add(&RequestRecord{Time: time.Now(), URL: "/4"})
+
+	records, err := c.Records()
+	if err != nil || len(records) != 2 || records[0].URL != "/3" || records[1].URL != "/4" || records[1].ID != 4 {
+		t.Fatalf("Unexpected records. Expected: /3 and /4, Actual: %+v (%v)", records, err)
+	}
+
+	if err := c.Reset(); err != nil {
+		t.Fatalf("Cannot reset: %s", err)
+	}
+	if keys, _ := s.List(""); len(keys) != 0 {
+		t.Fatalf("Unexpected keys after reset. Actual: %v", keys)
+	}
+}
+
+// countingStore counts the values read from a Store.
+type countingStore struct {
+	Store
+	gets int
+}
+
+func (s *countingStore) Get(key string) ([]byte, error) {
+	s.gets++
+	return s.Store.Get(key)
+}
+
+func TestCapturesRetentionReads(t *testing.T) {
+	s := &countingStore{Store: NewMapStore()}
+	c, err := CapturesIn(s, Retention{MaxRecords: 10, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Cannot create the captures: %s", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := c.Add(&RequestRecord{Time: time.Now(), URL: "/"}); err != nil {
+			t.Fatalf("Cannot add a request: %s", err)
+		}
+	}
+	// each request added reads the oldest one only, and those over MaxRecords
+	if s.gets > 2*100 {
+		t.Fatalf("Unexpected reads to keep the retention. Expected: at most 200, Actual: %d", s.gets)
+	}
+
+	c.Add(&RequestRecord{Time: time.Now().Add(-2 * time.Hour), URL: "/old"})
+	if records, err := c.Records(); err != nil || len(records) != 10 || records[9].URL != "/old" {
+		t.Fatalf("Unexpected records. Expected: 10 ending with /old, Actual: %d (%v)", len(records), err)
+	}
+}
+
+func TestSubdomainStore(t *testing.T) {
+	s := NewMapStore()
+	var requested []string
+	p := providerFunc(func(ctx context.Context, subdomain string) (*Registration, error) {
+		requested = append(requested, subdomain)
+		if subdomain == "" {
+			subdomain = "random"
+		}
+		return &Registration{Subdomain: subdomain, URL: "https://" + subdomain + ".loca.lt"}, nil
+	})
+
+	tunnel := NewProviderClient(p).NewStreamTunnel(WithSubdomainStore(s, "demo"))
+	for i := 0; i < 2; i++ {
+		if err := tunnel.Open(); err != nil {
+			t.Fatalf("Cannot open tunnel: %s", err)
+		}
+		tunnel.Close()
+	}
+
+	if !reflect.DeepEqual(requested, []string{"", "random"}) {
+		t.Fatalf("Unexpected subdomains requested. Expected: [ random], Actual: %q", requested)
+	}
+	if v, _ := s.Get("subdomains/demo"); string(v) != "random" {
+		t.Fatalf("Unexpected subdomain remembered. Expected: random, Actual: %s", v)
+	}
+}
+
+// fakeTable is the table of a fake SQL database, answering the statements of
+// SQLStore only.
+type fakeTable struct {
+	m    sync.Mutex
+	rows map[string][]byte
+}
+
+type fakeSQLConnector struct{ table *fakeTable }
+
+func (c fakeSQLConnector) Connect(context.Context) (driver.Conn, error) { return fakeSQLConn(c), nil }
+func (c fakeSQLConnector) Driver() driver.Driver                        { return nil }
+
+type fakeSQLConn struct{ table *fakeTable }
+
+func (c fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeSQLStmt{c.table, query}, nil
+}
+func (c fakeSQLConn) Close() error              { return nil }
+func (c fakeSQLConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type fakeSQLStmt struct {
+	table *fakeTable
+	query string
+}
+
+func (s fakeSQLStmt) Close() error  { return nil }
+func (s fakeSQLStmt) NumInput() int { return -1 }
+
+func (s fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.table.m.Lock()
+	defer s.table.m.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE"):
+		s.table.rows[args[0].(string)] = args[1].([]byte)
+	case strings.HasPrefix(s.query, "DELETE"):
+		delete(s.table.rows, args[0].(string))
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.table.m.Lock()
+	defer s.table.m.Unlock()
+
+	rows := &fakeSQLRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT value"):
+		if v, ok := s.table.rows[args[0].(string)]; ok {
+			rows.values = append(rows.values, v)
+		}
+	case strings.HasPrefix(s.query, "SELECT name"):
+		var names []string
+		for name := range s.table.rows {
+			if strings.HasPrefix(name, args[1].(string)) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rows.values = append(rows.values, name)
+		}
+	}
+	return rows, nil
+}
+
+type fakeSQLRows struct {
+	values []driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"column"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
@@ -0,0 +1,81 @@
+package localtunnel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds the traffic counters of a tunnel.
+type Stats struct {
+	Conns    int64 `json:"conns"`     // open connections to the remote server
+	BytesIn  int64 `json:"bytes_in"`  // bytes received from the remote server
+	BytesOut int64 `json:"bytes_out"` // bytes sent to the remote server
+	Queued   int64 `json:"queued"`    // requests waiting for the local server, see WithFairQueueing
+	Bans     int64 `json:"bans"`      // visitors banned by WithBanning
+
+	// FirstConn is how long the connections to the remote server were all down
+	// the last time, from the opening of the tunnel or the failure of the last
+	// connection up until the first one is up again.
+	FirstConn time.Duration `json:"first_conn"`
+
+	// Errors counts the failed connections by side and class, e.g.
+	// Errors[LocalSide][ClassRefused].
+	Errors map[Side]map[ErrorClass]int64 `json:"errors,omitempty"`
+}
+
+// Stats returns a snapshot of the tunnel's traffic counters.
+func (t *Tunnel) Stats() Stats {
+	return Stats{
+		Conns:    atomic.LoadInt64(&t.stats.Conns),
+		BytesIn:  atomic.LoadInt64(&t.stats.BytesIn),
+		BytesOut: atomic.LoadInt64(&t.stats.BytesOut),
+		Queued:   atomic.LoadInt64(&t.stats.Queued),
+		Bans:     atomic.LoadInt64(&t.stats.Bans),
+		Errors:   t.connErrors.snapshot(),
+
+		FirstConn: time.Duration(atomic.LoadInt64((*int64)(&t.stats.FirstConn))),
+	}
+}
+
+func (s *Stats) addConns(n int64)  { atomic.AddInt64(&s.Conns, n) }
+func (s *Stats) addBytesIn(n int)  { atomic.AddInt64(&s.BytesIn, int64(n)) }
+func (s *Stats) addBytesOut(n int) { atomic.AddInt64(&s.BytesOut, int64(n)) }
+func (s *Stats) addQueued(n int64) { atomic.AddInt64(&s.Queued, n) }
+func (s *Stats) addBans(n int64)   { atomic.AddInt64(&s.Bans, n) }
+
+// errorCounts counts the failed connections of a tunnel.
+type errorCounts struct {
+	m      sync.Mutex
+	counts map[Side]map[ErrorClass]int64
+}
+
+func (e *errorCounts) add(side Side, class ErrorClass) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if e.counts == nil {
+		e.counts = map[Side]map[ErrorClass]int64{}
+	}
+	if e.counts[side] == nil {
+		e.counts[side] = map[ErrorClass]int64{}
+	}
+	e.counts[side][class]++
+}
+
+func (e *errorCounts) snapshot() map[Side]map[ErrorClass]int64 {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	if len(e.counts) == 0 {
+		return nil
+	}
+	s := make(map[Side]map[ErrorClass]int64, len(e.counts))
+	for side, classes := range e.counts {
+		s[side] = make(map[ErrorClass]int64, len(classes))
+		for class, n := range classes {
+			s[side][class] = n
+		}
+	}
+	return s
+}
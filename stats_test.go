@@ -0,0 +1,41 @@
+package localtunnel
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s := newFakeServer(t, 1)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	remote := s.conn(t)
+	remote.Write([]byte("hello"))
+
+	st, err := tunnel.AcceptStream()
+	if err != nil {
+		t.Fatalf("Cannot accept stream: %s", err)
+	}
+
+	b := make([]byte, 5)
+	io.ReadFull(st, b)
+	st.Write([]byte("hi"))
+	io.ReadFull(remote, b[:2])
+
+	stats := tunnel.Stats()
+	if stats.Conns != 1 {
+		t.Fatalf("Unexpected connections. Expected: 1, Actual: %d", stats.Conns)
+	}
+	if stats.BytesIn != 5 {
+		t.Fatalf("Unexpected bytes in. Expected: 5, Actual: %d", stats.BytesIn)
+	}
+	if stats.BytesOut != 2 {
+		t.Fatalf("Unexpected bytes out. Expected: 2, Actual: %d", stats.BytesOut)
+	}
+}
@@ -0,0 +1,75 @@
+package localtunnel
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// statusWriter rewrites the status of the local server's response as told by a
+// RewriteStatus rule, replacing its body when the rule gives one.
+type statusWriter struct {
+	http.ResponseWriter
+	rule *Rule
+
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		// informational, e.g. 103 Early Hints, the final status is still to come
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+
+	to, ok := w.rule.Statuses[code]
+	if !ok {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.rule.Body == "" {
+		w.ResponseWriter.WriteHeader(to)
+		return
+	}
+
+	h := w.Header()
+	for _, k := range []string{"Content-Encoding", "Content-Length", "Content-Range", "Etag", "Last-Modified"} {
+		h.Del(k)
+	}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(w.rule.Body)))
+	w.ResponseWriter.WriteHeader(to)
+	w.ResponseWriter.Write([]byte(w.rule.Body))
+	w.replaced = true
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		// the local server's body is dropped
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
@@ -0,0 +1,233 @@
+package localtunnel
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// A CaptureStore keeps the requests recorded by a Capture.
+type CaptureStore interface {
+	// Add stores r, assigning its ID.
+	Add(r *RequestRecord) error
+
+	// Records returns the stored requests, oldest first.
+	Records() ([]RequestRecord, error)
+
+	// Reset removes all stored requests.
+	Reset() error
+}
+
+// Retention limits the requests kept by a store, the oldest ones being dropped first.
+// Zero values mean no limit.
+type Retention struct {
+	MaxRecords int
+	MaxAge     time.Duration
+}
+
+// apply returns the records kept by the retention.
+func (r Retention) apply(records []RequestRecord) []RequestRecord {
+	if r.MaxRecords > 0 && len(records) > r.MaxRecords {
+		records = records[len(records)-r.MaxRecords:]
+	}
+
+	if r.MaxAge > 0 {
+		since := time.Now().Add(-r.MaxAge)
+		i := 0
+		for i < len(records) && records[i].Time.Before(since) {
+			i++
+		}
+		records = records[i:]
+	}
+
+	return records
+}
+
+// MemoryStore keeps captured requests in memory.
+type MemoryStore struct {
+	retention Retention
+
+	m       sync.Mutex
+	lastID  int64
+	records []RequestRecord
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore(r Retention) *MemoryStore {
+	return &MemoryStore{retention: r}
+}
+
+// Add stores r, assigning its ID.
+func (s *MemoryStore) Add(r *RequestRecord) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.lastID++
+	r.ID = s.lastID
+	s.retain(append(s.records, *r))
+	return nil
+}
+
+// retain keeps the records allowed by the retention, removing the bodies of the others.
+func (s *MemoryStore) retain(records []RequestRecord) {
+	kept := s.retention.apply(records)
+	removeBodies(records[:len(records)-len(kept)])
+	s.records = kept
+}
+
+// Records returns the stored requests, oldest first.
+func (s *MemoryStore) Records() ([]RequestRecord, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.retain(s.records)
+	records := make([]RequestRecord, len(s.records))
+	copy(records, s.records)
+	return records, nil
+}
+
+// Reset removes all stored requests.
+func (s *MemoryStore) Reset() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	removeBodies(s.records)
+	s.records = nil
+	return nil
+}
+
+// FileStore keeps captured requests in a file, one JSON record per line, so they
+// survive restarts. The retained requests are also kept in memory. It needs no
+// database driver, the standard library having none; to keep the captures in a
+// SQLite file instead, give CapturesIn a SQLStore.
+type FileStore struct {
+	mem  *MemoryStore
+	path string
+
+	m     sync.Mutex
+	f     *os.File
+	lines int // records in the file, retained or not
+}
+
+// OpenFileStore opens the store kept in the file at path, creating it if needed.
+func OpenFileStore(path string, r Retention) (*FileStore, error) {
+	s := &FileStore{mem: NewMemoryStore(r), path: path}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []RequestRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		var record RequestRecord
+		if json.Unmarshal(sc.Bytes(), &record) == nil {
+			records = append(records, record)
+		}
+	}
+	f.Close()
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 {
+		s.mem.lastID = records[len(records)-1].ID
+	}
+	s.mem.retain(records)
+
+	err = s.compact()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add stores r, assigning its ID.
+func (s *FileStore) Add(r *RequestRecord) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.mem.Add(r)
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.f.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	s.lines++
+
+	// rewrite the file once most of its records are no longer retained
+	if s.lines > 2*len(s.mem.records)+100 {
+		return s.compact()
+	}
+	return nil
+}
+
+// Records returns the stored requests, oldest first.
+func (s *FileStore) Records() ([]RequestRecord, error) {
+	return s.mem.Records()
+}
+
+// Reset removes all stored requests.
+func (s *FileStore) Reset() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.mem.Reset()
+	return s.compact()
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.f.Close()
+}
+
+// compact rewrites the file with the retained records only.
+func (s *FileStore) compact() error {
+	records, _ := s.mem.Records()
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	e := json.NewEncoder(w)
+	for i := range records {
+		if err := e.Encode(&records[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	if s.f != nil {
+		s.f.Close()
+	}
+
+	err = os.Rename(tmp, s.path)
+	if err != nil {
+		return err
+	}
+
+	s.f, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	s.lines = len(records)
+	return err
+}
@@ -0,0 +1,78 @@
+package localtunnel
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreRetention(t *testing.T) {
+	s := NewMemoryStore(Retention{MaxRecords: 3, MaxAge: time.Hour})
+
+	s.Add(&RequestRecord{Time: time.Now().Add(-2 * time.Hour)})
+	for i := 0; i < 4; i++ {
+		s.Add(&RequestRecord{Time: time.Now()})
+	}
+
+	records, _ := s.Records()
+	if len(records) != 3 || records[0].ID != 3 || records[2].ID != 5 {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+
+	s = NewMemoryStore(Retention{MaxAge: time.Hour})
+	s.Add(&RequestRecord{Time: time.Now().Add(-2 * time.Hour)})
+	s.Add(&RequestRecord{Time: time.Now()})
+
+	records, _ = s.Records()
+	if len(records) != 1 || records[0].ID != 2 {
+		t.Fatalf("Expired records should be dropped: %v", records)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+
+	s, err := OpenFileStore(path, Retention{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{"/a", "/b", "/c"} {
+		if err := s.Add(&RequestRecord{Time: time.Now(), URL: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	s, err = OpenFileStore(path, Retention{MaxRecords: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	records, _ := s.Records()
+	if len(records) != 2 || records[0].URL != "/b" || records[1].URL != "/c" {
+		t.Fatalf("Unexpected records after reopening: %v", records)
+	}
+
+	s.Add(&RequestRecord{Time: time.Now(), URL: "/d"})
+	records, _ = s.Records()
+	if records[1].ID != 4 {
+		t.Fatalf("IDs should continue after reopening. Expected: 4, Actual: %d", records[1].ID)
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	if len(b) == 0 {
+		t.Fatal("Records should be written to the file")
+	}
+
+	s.Reset()
+	s.Close()
+
+	s, _ = OpenFileStore(path, Retention{})
+	defer s.Close()
+	if records, _ := s.Records(); len(records) != 0 {
+		t.Fatalf("Store should be empty after reset. Actual: %d records", len(records))
+	}
+}
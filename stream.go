@@ -0,0 +1,150 @@
+package localtunnel
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrClosed is returned by AcceptStream when the tunnel is not open.
+	ErrClosed = errors.New("localtunnel: tunnel closed")
+
+	// ErrNotStreamTunnel is returned by AcceptStream when the tunnel forwards to a local server.
+	ErrNotStreamTunnel = errors.New("localtunnel: not a stream tunnel")
+)
+
+// NewStreamTunnel create a tunnel whose remote connections are handed over by AcceptStream
+// instead of being forwarded to a local server.
+func (c *Client) NewStreamTunnel(opts ...Option) *Tunnel {
+	t := &Tunnel{c: c, streams: make(chan net.Conn)}
+	t.apply(opts)
+	return t
+}
+
+// NewStreamTunnel create a stream tunnel using the DefaultClient.
+func NewStreamTunnel(opts ...Option) *Tunnel {
+	return DefaultClient.NewStreamTunnel(opts...)
+}
+
+// AcceptStream waits for and returns the next remote connection of a stream tunnel.
+// The caller owns the returned stream and must close it once done; closing it gives
+// its slot back to the tunnel.
+func (t *Tunnel) AcceptStream() (net.Conn, error) {
+	if t.streams == nil || t.proxy {
+		return nil, ErrNotStreamTunnel
+	}
+	return t.nextStream()
+}
+
+func (t *Tunnel) nextStream() (net.Conn, error) {
+	closeCh := t.closing()
+	if closeCh == nil {
+		return nil, ErrClosed
+	}
+
+	select {
+	case s := <-t.streams:
+		return s, nil
+	case <-closeCh:
+		return nil, ErrClosed
+	}
+}
+
+// Listener returns a net.Listener accepting the remote connections of a stream tunnel,
+// e.g. to serve them with http.Serve.
+func (t *Tunnel) Listener() net.Listener {
+	return &listener{t: t, accept: t.AcceptStream}
+}
+
+type listener struct {
+	t      *Tunnel
+	accept func() (net.Conn, error)
+}
+
+func (l *listener) Accept() (net.Conn, error) { return l.accept() }
+func (l *listener) Close() error              { return nil }
+func (l *listener) Addr() net.Addr            { return tunnelAddr{l.t} }
+
+// tunnelAddr is the public address of a tunnel.
+type tunnelAddr struct{ t *Tunnel }
+
+func (a tunnelAddr) Network() string { return "tcp" }
+func (a tunnelAddr) String() string {
+	return net.JoinHostPort(a.t.RemoteHost(), strconv.Itoa(a.t.RemotePort()))
+}
+
+// stream is a remote connection which already received data.
+type stream struct {
+	net.Conn
+	r     *bufio.Reader
+	stats *Stats
+	once  sync.Once
+	done  chan struct{}
+}
+
+func newStream(c net.Conn, stats *Stats) *stream {
+	return &stream{Conn: c, r: bufio.NewReader(c), stats: stats, done: make(chan struct{})}
+}
+
+func (s *stream) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	s.stats.addBytesIn(n)
+	return n, err
+}
+
+func (s *stream) Write(b []byte) (int, error) {
+	n, err := s.Conn.Write(b)
+	s.stats.addBytesOut(n)
+	return n, err
+}
+
+func (s *stream) Close() error {
+	err := ErrClosed
+	s.once.Do(func() {
+		err = s.Conn.Close()
+		close(s.done)
+	})
+	return err
+}
+
+// accept waits until the remote server uses the connection and hands it over to AcceptStream.
+// It reports whether the connection must be re-dialed afterwards.
+func (c *conn) accept() bool {
+	closeCh := c.closeCh
+	s := newStream(c.remoteConn, &c.t.stats)
+	c.spawn(func() {
+		select {
+		case <-closeCh:
+			s.Close()
+		case <-s.done:
+		}
+		c.pool.connDown(&c.t.stats)
+	})
+
+	if _, err := s.r.Peek(1); err != nil {
+		s.Close()
+		return isOpen(closeCh)
+	}
+
+	select {
+	case c.t.streams <- s:
+	case <-s.done:
+		return false
+	}
+
+	<-s.done
+	return isOpen(closeCh)
+}
+
+// isOpen reports whether closeCh is still open.
+func isOpen(closeCh <-chan struct{}) bool {
+	select {
+	case <-closeCh:
+		return false
+	default:
+		return true
+	}
+}
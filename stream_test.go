@@ -0,0 +1,65 @@
+package localtunnel
+
+import (
+	"io"
+	"testing"
+)
+
+func TestAcceptStream(t *testing.T) {
+	s := newFakeServer(t, 2)
+	tunnel := NewClient(s.URL).NewStreamTunnel()
+
+	if _, err := tunnel.AcceptStream(); err != ErrClosed {
+		t.Fatalf("Unexpected error before open. Expected: %s, Actual: %v", ErrClosed, err)
+	}
+
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+
+	remote := s.conn(t)
+	s.conn(t)
+
+	if _, err := remote.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := tunnel.AcceptStream()
+	if err != nil {
+		t.Fatalf("Cannot accept stream: %s", err)
+	}
+
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(st, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ping" {
+		t.Fatalf("Unexpected stream data. Expected: 'ping'. Actual: '%s'", b)
+	}
+
+	if _, err := st.Write([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(remote, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "pong" {
+		t.Fatalf("Unexpected remote data. Expected: 'pong'. Actual: '%s'", b)
+	}
+
+	st.Close()
+	s.conn(t) // closed streams are replaced by a new connection
+
+	tunnel.Close()
+	if _, err := tunnel.AcceptStream(); err != ErrClosed {
+		t.Fatalf("Unexpected error after close. Expected: %s, Actual: %v", ErrClosed, err)
+	}
+}
+
+func TestAcceptStreamOnLocalTunnel(t *testing.T) {
+	tunnel := NewLocalTunnel(8000)
+	if _, err := tunnel.AcceptStream(); err != ErrNotStreamTunnel {
+		t.Fatalf("Unexpected error. Expected: %s, Actual: %v", ErrNotStreamTunnel, err)
+	}
+}
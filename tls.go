@@ -0,0 +1,15 @@
+package localtunnel
+
+import "crypto/tls"
+
+// WithTLS terminates the TLS of the connections arriving through the tunnel with
+// config, for servers passing TLS through instead of terminating it themselves. A
+// custom domain pointed at such a server is then served with a certificate of its
+// own, while the local server speaks plain HTTP. See WithACME to have the
+// certificate issued by Let's Encrypt. It implies WithHTTPProxy.
+func WithTLS(config *tls.Config) Option {
+	return func(t *Tunnel) {
+		t.proxy = true
+		t.tlsConfig = config
+	}
+}
@@ -0,0 +1,45 @@
+package localtunnel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTLSTermination(t *testing.T) {
+	certs := httptest.NewTLSServer(http.NotFoundHandler())
+	defer certs.Close()
+
+	_, remote := openHTTPTunnel(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			t.Errorf("The local server should be reached with plain HTTP")
+		}
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto") + " " + r.Host))
+	}), WithTLS(&tls.Config{Certificates: certs.TLS.Certificates}))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certs.Certificate())
+	c := tls.Client(remote, &tls.Config{RootCAs: roots, ServerName: "example.com"})
+	err := c.Handshake()
+	if err != nil {
+		t.Fatalf("Cannot handshake with the tunnel: %s", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialTLS: func(network, addr string) (net.Conn, error) { return c, nil },
+	}}
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(b) != "https example.com" {
+		t.Fatalf("Unexpected response. Expected: 200 https example.com, Actual: %d %s", resp.StatusCode, b)
+	}
+}
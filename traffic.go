@@ -0,0 +1,184 @@
+package localtunnel
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxTrafficPaths bounds the paths accounted separately, the others being accounted
+// under OtherPaths.
+const maxTrafficPaths = 100
+
+// OtherPaths accounts the requests whose path prefix, or response content type, did
+// not fit in the traffic stats.
+const OtherPaths = "*"
+
+// UnknownContentType accounts the responses without a Content-Type.
+const UnknownContentType = "unknown"
+
+// sizeClasses are the response body sizes the traffic is accounted by, each class
+// holding the sizes under its limit and above the previous one.
+var sizeClasses = []struct {
+	limit int64
+	name  string
+}{
+	{1 << 10, "<1KB"},
+	{10 << 10, "<10KB"},
+	{100 << 10, "<100KB"},
+	{1 << 20, "<1MB"},
+	{10 << 20, "<10MB"},
+}
+
+// LargestSize is the size class of the response bodies of 10MB or more.
+const LargestSize = ">=10MB"
+
+// Traffic counts the requests served by an HTTP tunnel and their bytes.
+type Traffic struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`  // request body bytes
+	BytesOut int64 `json:"bytes_out"` // response body bytes
+}
+
+// TrafficStats breaks down the requests of an HTTP tunnel by path prefix, by status
+// class, such as "2xx", by response content type, such as "image/png", and by
+// response body size class, such as "<100KB".
+type TrafficStats struct {
+	Paths        map[string]Traffic `json:"paths"`
+	Statuses     map[string]Traffic `json:"statuses"`
+	ContentTypes map[string]Traffic `json:"content_types"`
+	Sizes        map[string]Traffic `json:"sizes"`
+}
+
+type trafficStats struct {
+	depth int
+
+	m        sync.Mutex
+	paths    map[string]*Traffic
+	statuses map[string]*Traffic
+	types    map[string]*Traffic
+	sizes    map[string]*Traffic
+}
+
+// WithTrafficStats accounts the requests served by the tunnel by status class and by
+// path prefix of up to depth segments, e.g. "/api/users" with a depth of 2. The stats
+// are read with TrafficStats. It implies WithHTTPProxy.
+func WithTrafficStats(depth int) Option {
+	if depth < 1 {
+		depth = 1
+	}
+
+	return func(t *Tunnel) {
+		t.traffic = &trafficStats{
+			depth:    depth,
+			paths:    map[string]*Traffic{},
+			statuses: map[string]*Traffic{},
+			types:    map[string]*Traffic{},
+			sizes:    map[string]*Traffic{},
+		}
+		t.use(t.traffic.middleware)
+	}
+}
+
+// TrafficStats returns a snapshot of the traffic stats of a tunnel created with
+// WithTrafficStats, or nil.
+func (t *Tunnel) TrafficStats() *TrafficStats {
+	if t.traffic == nil {
+		return nil
+	}
+	return t.traffic.snapshot()
+}
+
+func (s *trafficStats) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in := &bodyCapture{}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &teeReadCloser{r: io.TeeReader(r.Body, in), c: r.Body}
+		}
+
+		cw := &captureWriter{ResponseWriter: w, body: &bodyCapture{}}
+		next.ServeHTTP(cw, r)
+
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.add(pathPrefix(r.URL.Path, s.depth), strconv.Itoa(status/100)+"xx", responseType(cw.Header()), in.size, cw.body.size)
+	})
+}
+
+func (s *trafficStats) add(path, class, typ string, in, out int64) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.paths[path]; !ok && len(s.paths) >= maxTrafficPaths {
+		path = OtherPaths
+	}
+	if _, ok := s.types[typ]; !ok && len(s.types) >= maxTrafficPaths {
+		typ = OtherPaths
+	}
+
+	for _, tr := range []*Traffic{entry(s.paths, path), entry(s.statuses, class), entry(s.types, typ), entry(s.sizes, sizeClass(out))} {
+		tr.Requests++
+		tr.BytesIn += in
+		tr.BytesOut += out
+	}
+}
+
+func (s *trafficStats) snapshot() *TrafficStats {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return &TrafficStats{
+		Paths:        copyTraffic(s.paths),
+		Statuses:     copyTraffic(s.statuses),
+		ContentTypes: copyTraffic(s.types),
+		Sizes:        copyTraffic(s.sizes),
+	}
+}
+
+func copyTraffic(m map[string]*Traffic) map[string]Traffic {
+	c := make(map[string]Traffic, len(m))
+	for k, v := range m {
+		c[k] = *v
+	}
+	return c
+}
+
+func entry(m map[string]*Traffic, key string) *Traffic {
+	tr, ok := m[key]
+	if !ok {
+		tr = &Traffic{}
+		m[key] = tr
+	}
+	return tr
+}
+
+// pathPrefix returns the first depth segments of path.
+func pathPrefix(path string, depth int) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(segments) > depth {
+		segments = segments[:depth]
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// responseType returns the media type a response is accounted by.
+func responseType(h http.Header) string {
+	if t := contentType(h); t != "" {
+		return strings.ToLower(t)
+	}
+	return UnknownContentType
+}
+
+// sizeClass returns the size class of a response body of n bytes.
+func sizeClass(n int64) string {
+	for _, c := range sizeClasses {
+		if n < c.limit {
+			return c.name
+		}
+	}
+	return LargestSize
+}
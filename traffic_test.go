@@ -0,0 +1,120 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrafficStats(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		echoHandler(w, r)
+	}))
+	defer local.Close()
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithTrafficStats(2))
+	h := tunnel.httpHandler()
+
+	serve(h, httptest.NewRequest("POST", "/api/users/1", strings.NewReader("hello")))
+	serve(h, httptest.NewRequest("GET", "/api/users", nil))
+	serve(h, httptest.NewRequest("GET", "/missing", nil))
+
+	stats := tunnel.TrafficStats()
+	expected := map[string]Traffic{
+		"/api/users": {Requests: 2, BytesIn: 5, BytesOut: int64(len("POST /api/users/1 hello") + len("GET /api/users "))},
+		"/missing":   {Requests: 1, BytesOut: int64(len("404 page not found\n"))},
+	}
+	for path, tr := range expected {
+		if stats.Paths[path] != tr {
+			t.Fatalf("%s: unexpected traffic. Expected: %+v, Actual: %+v", path, tr, stats.Paths[path])
+		}
+	}
+
+	if stats.Statuses["2xx"].Requests != 2 || stats.Statuses["4xx"].Requests != 1 {
+		t.Fatalf("Unexpected status classes: %+v", stats.Statuses)
+	}
+}
+
+func TestTrafficStatsByResponse(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/PNG")
+			w.Write(make([]byte, 2048))
+		case "/":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<p>hello</p>"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer local.Close()
+
+	tunnel := NewTunnel("127.0.0.1", getServerPort(t, local), WithTrafficStats(1))
+	h := tunnel.httpHandler()
+
+	serve(h, httptest.NewRequest("GET", "/logo.png", nil))
+	serve(h, httptest.NewRequest("GET", "/logo.png", nil))
+	serve(h, httptest.NewRequest("GET", "/", nil))
+	serve(h, httptest.NewRequest("DELETE", "/session", nil))
+
+	stats := tunnel.TrafficStats()
+	types := map[string]Traffic{
+		"image/png":        {Requests: 2, BytesOut: 4096},
+		"text/html":        {Requests: 1, BytesOut: int64(len("<p>hello</p>"))},
+		UnknownContentType: {Requests: 1},
+	}
+	for typ, tr := range types {
+		if stats.ContentTypes[typ] != tr {
+			t.Fatalf("%s: unexpected traffic. Expected: %+v, Actual: %+v", typ, tr, stats.ContentTypes[typ])
+		}
+	}
+
+	if stats.Sizes["<10KB"].BytesOut != 4096 || stats.Sizes["<1KB"].Requests != 2 || len(stats.Sizes) != 2 {
+		t.Fatalf("Unexpected size classes: %+v", stats.Sizes)
+	}
+}
+
+func TestSizeClass(t *testing.T) {
+	for n, expected := range map[int64]string{
+		0:         "<1KB",
+		1023:      "<1KB",
+		1024:      "<10KB",
+		500 << 10: "<1MB",
+		10 << 20:  LargestSize,
+	} {
+		if actual := sizeClass(n); actual != expected {
+			t.Fatalf("Unexpected size class of %d. Expected: %s, Actual: %s", n, expected, actual)
+		}
+	}
+}
+
+func TestTrafficStatsDisabled(t *testing.T) {
+	if NewTunnel("127.0.0.1", 8000).TrafficStats() != nil {
+		t.Fatal("Traffic stats should be nil when disabled")
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	tests := []struct {
+		path     string
+		depth    int
+		expected string
+	}{
+		{"/", 1, "/"},
+		{"/api/users/1", 1, "/api"},
+		{"/api/users/1", 2, "/api/users"},
+		{"/api", 3, "/api"},
+	}
+
+	for _, test := range tests {
+		if p := pathPrefix(test.path, test.depth); p != test.expected {
+			t.Fatalf("Unexpected prefix of %s. Expected: %s, Actual: %s", test.path, test.expected, p)
+		}
+	}
+}
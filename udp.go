@@ -0,0 +1,102 @@
+package localtunnel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// Datagrams travel through the tunnel's connections framed by their length, as a
+// 16-bit big-endian integer. The remote server must support this encapsulation.
+
+// NewUDPTunnel create a tunnel forwarding datagrams to a UDP server in a given host and port.
+func (c *Client) NewUDPTunnel(host string, port int, opts ...Option) *Tunnel {
+	t := &Tunnel{c: c, localHost: host, localPort: port, udp: true}
+	t.apply(opts)
+	return t
+}
+
+// NewUDPTunnel create a UDP tunnel using the DefaultClient.
+func NewUDPTunnel(host string, port int, opts ...Option) *Tunnel {
+	return DefaultClient.NewUDPTunnel(host, port, opts...)
+}
+
+// serveUDP forwards the datagrams received on the remote connection to the local
+// server and its replies back. It reports whether the connection must be re-dialed.
+func (c *conn) serveUDP() bool {
+	var err error
+	c.localConn, err = c.dial("udp", c.t.LocalHost(), c.t.LocalPort())
+	if err != nil {
+		c.close()
+		c.t.close(fmt.Errorf("%w: %v", ErrLocalUnreachable, err))
+		return false
+	}
+
+	errorCh := make(chan error, 2)
+
+	c.spawn(func() {
+		r := bufio.NewReader(c.remoteConn)
+		for {
+			p, err := readDatagram(r)
+			if err != nil {
+				errorCh <- err
+				return
+			}
+			c.t.stats.addBytesIn(len(p))
+			c.localConn.Write(p)
+		}
+	})
+
+	c.spawn(func() {
+		b := make([]byte, 0xffff)
+		for {
+			n, err := c.localConn.Read(b)
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				// nobody listening yet, the datagram is lost as it would be without a tunnel
+				continue
+			}
+			if err != nil {
+				errorCh <- err
+				return
+			}
+
+			err = c.write(c.remoteConn, frameDatagram(b[:n]))
+			if err != nil {
+				errorCh <- err
+				return
+			}
+			c.t.stats.addBytesOut(n)
+		}
+	})
+
+	select {
+	case <-errorCh:
+		c.close()
+		return true
+	case <-c.closeCh:
+		c.close()
+		return false
+	}
+}
+
+func readDatagram(r io.Reader) ([]byte, error) {
+	var size uint16
+	err := binary.Read(r, binary.BigEndian, &size)
+	if err != nil {
+		return nil, err
+	}
+
+	p := make([]byte, size)
+	_, err = io.ReadFull(r, p)
+	return p, err
+}
+
+func frameDatagram(p []byte) []byte {
+	b := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(b, uint16(len(p)))
+	copy(b[2:], p)
+	return b
+}
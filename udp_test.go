@@ -0,0 +1,55 @@
+package localtunnel
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUDPTunnel(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	local, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, addr, err := local.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			local.WriteTo([]byte(strings.ToUpper(string(b[:n]))), addr)
+		}
+	}()
+
+	tunnel := NewClient(s.URL).NewUDPTunnel("127.0.0.1", local.LocalAddr().(*net.UDPAddr).Port)
+	err = tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	remote := s.conn(t)
+	remote.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(remote)
+
+	for _, msg := range []string{"ping", "hello"} {
+		if _, err := remote.Write(frameDatagram([]byte(msg))); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := readDatagram(r)
+		if err != nil {
+			t.Fatalf("Cannot read datagram: %s", err)
+		}
+		if string(p) != strings.ToUpper(msg) {
+			t.Fatalf("Unexpected datagram. Expected: '%s'. Actual: '%s'", strings.ToUpper(msg), p)
+		}
+	}
+}
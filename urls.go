@@ -0,0 +1,58 @@
+package localtunnel
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidTunnelURL is returned when a URL does not have the form scheme://subdomain.domain.
+var ErrInvalidTunnelURL = errors.New("localtunnel: invalid tunnel URL")
+
+// SubdomainFromURL returns the subdomain of a tunnel URL, e.g. "ltdemo" for
+// https://ltdemo.loca.lt.
+func SubdomainFromURL(tunnelURL string) (string, error) {
+	u, err := url.Parse(tunnelURL)
+	if err != nil || u.Hostname() == "" || net.ParseIP(u.Hostname()) != nil {
+		return "", ErrInvalidTunnelURL
+	}
+
+	labels := strings.Split(u.Hostname(), ".")
+	if len(labels) < 3 || labels[0] == "" {
+		return "", ErrInvalidTunnelURL
+	}
+	return strings.ToLower(labels[0]), nil
+}
+
+// URLForSubdomain returns the URL of the tunnel with the given subdomain on a server
+// which exposes its tunnels under its own domain, e.g. https://ltdemo.example.com for
+// the end point https://example.com.
+func URLForSubdomain(endpoint, subdomain string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || subdomain == "" || strings.Contains(subdomain, ".") {
+		return "", ErrInvalidTunnelURL
+	}
+
+	return (&url.URL{Scheme: u.Scheme, Host: subdomain + "." + u.Host}).String(), nil
+}
+
+// joinEndpoint returns the URL of path, already escaped, under the end point of a
+// server, which may be hosted under a path prefix and end with a slash: for
+// https://example.com/lt/ and "/api/status" it is https://example.com/lt/api/status.
+func joinEndpoint(endpoint, path, query string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	raw := strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.TrimPrefix(path, "/")
+	u.Path, err = url.PathUnescape(raw)
+	if err != nil {
+		return "", err
+	}
+	u.RawPath = raw
+	u.RawQuery = query
+	u.Fragment = ""
+	return u.String(), nil
+}
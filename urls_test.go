@@ -0,0 +1,62 @@
+package localtunnel
+
+import "testing"
+
+func TestSubdomainFromURL(t *testing.T) {
+	tests := map[string]string{
+		"https://ltdemo.loca.lt":          "ltdemo",
+		"https://LtDemo.loca.lt/path?q=1": "ltdemo",
+		"http://ltdemo.example.com:8080":  "ltdemo",
+		"https://loca.lt":                 "",
+		"https://127.0.0.1":               "",
+		"ltdemo":                          "",
+		"://bad":                          "",
+	}
+
+	for u, expected := range tests {
+		subdomain, err := SubdomainFromURL(u)
+		if expected == "" {
+			if err != ErrInvalidTunnelURL {
+				t.Fatalf("%s: unexpected error. Expected: %v, Actual: %v", u, ErrInvalidTunnelURL, err)
+			}
+			continue
+		}
+		if subdomain != expected {
+			t.Fatalf("%s: unexpected subdomain. Expected: %s, Actual: %s", u, expected, subdomain)
+		}
+	}
+}
+
+func TestURLForSubdomain(t *testing.T) {
+	u, err := URLForSubdomain("https://example.com:8443", "ltdemo")
+	if err != nil || u != "https://ltdemo.example.com:8443" {
+		t.Fatalf("Unexpected URL. Expected: https://ltdemo.example.com:8443, Actual: %s (%v)", u, err)
+	}
+
+	for _, name := range []string{"", "a.b"} {
+		if _, err := URLForSubdomain("https://example.com", name); err != ErrInvalidTunnelURL {
+			t.Fatalf("%q: unexpected error. Expected: %v, Actual: %v", name, ErrInvalidTunnelURL, err)
+		}
+	}
+}
+
+func TestJoinEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, path, query, expected string
+	}{
+		{"https://example.com", "/api/status", "", "https://example.com/api/status"},
+		{"https://example.com/", "/api/status", "", "https://example.com/api/status"},
+		{"https://example.com/lt", "/api/status", "", "https://example.com/lt/api/status"},
+		{"https://example.com/lt/", "api/status", "", "https://example.com/lt/api/status"},
+		{"https://example.com/lt", "", "new", "https://example.com/lt/?new"},
+		{"https://example.com/lt", "ltdemo", "", "https://example.com/lt/ltdemo"},
+		{"https://example.com/my%20lt/", "/api/tunnels/a%2Fb/status", "", "https://example.com/my%20lt/api/tunnels/a%2Fb/status"},
+	}
+
+	for _, test := range tests {
+		u, err := joinEndpoint(test.endpoint, test.path, test.query)
+		if err != nil || u != test.expected {
+			t.Fatalf("%s + %s: unexpected URL. Expected: %s, Actual: %s (%v)", test.endpoint, test.path, test.expected, u, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package localtunnel
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/jweslley/localtunnel"
+
+// version may be set at build time with
+// -ldflags "-X github.com/jweslley/localtunnel.version=1.2.3"
+var version string
+
+var versionOnce sync.Once
+
+// Version returns the version of the package: the one set at build time, or else
+// the module version recorded in the binary's build info, or "dev".
+func Version() string {
+	versionOnce.Do(func() {
+		if version != "" {
+			return
+		}
+		version = "dev"
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		m := &info.Main
+		for _, d := range info.Deps {
+			if d.Path == modulePath {
+				m = d
+			}
+		}
+		if m.Path == modulePath && m.Version != "" && m.Version != "(devel)" {
+			version = m.Version
+		}
+	})
+	return version
+}
+
+// userAgent identifies the client on the requests sent to the server.
+func userAgent() string {
+	return "localtunnel-go/" + Version()
+}
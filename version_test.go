@@ -0,0 +1,35 @@
+package localtunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if Version() != "dev" {
+		t.Fatalf("Unexpected version. Expected: dev, Actual: %s", Version())
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	s := newFakeServer(t, 1)
+
+	var agent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+		s.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	tunnel := NewClient(ts.URL).NewStreamTunnel()
+	err := tunnel.Open()
+	if err != nil {
+		t.Fatalf("Cannot open tunnel: %s", err)
+	}
+	defer tunnel.Close()
+
+	if agent != "localtunnel-go/dev" {
+		t.Fatalf("Unexpected User-Agent. Expected: localtunnel-go/dev, Actual: %s", agent)
+	}
+}
@@ -0,0 +1,179 @@
+package localtunnel
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventWebhook is emitted for every webhook request verified, with its Path and the
+// verification error in Err, nil when the signature is valid.
+const EventWebhook EventType = "webhook"
+
+var (
+	// ErrInvalidSignature is returned by the verifiers when the signature is missing
+	// or does not match.
+	ErrInvalidSignature = errors.New("localtunnel: invalid webhook signature")
+
+	// ErrWebhookExpired is returned by the verifiers when the signed timestamp is out
+	// of the tolerance, which protects against replays.
+	ErrWebhookExpired = errors.New("localtunnel: webhook timestamp out of tolerance")
+
+	// ErrWebhookPath is reported for the requests under the path of a webhook whose
+	// path is not canonical, e.g. "//hooks/github", which are rejected unverified as
+	// the local server may not route them as the tunnel does.
+	ErrWebhookPath = errors.New("localtunnel: webhook path not canonical")
+)
+
+// DefaultWebhookTolerance is how old a signed timestamp may be by default.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// maxWebhookBody limits the bodies read to verify their signature.
+const maxWebhookBody = 10 << 20
+
+// A WebhookVerifier checks the signature of a webhook request given its body, at now.
+type WebhookVerifier func(r *http.Request, body []byte, now time.Time) error
+
+// A Webhook tells how to verify the requests under Path.
+type Webhook struct {
+	Path     string
+	Verifier WebhookVerifier
+}
+
+// WithWebhooks verifies the signature of the requests under the path of a webhook
+// before they reach the local server, answering 401 Unauthorized to the forged ones.
+// The first webhook matching the cleaned path applies, and the requests whose path is
+// not canonical are rejected with ErrWebhookPath. Every verification is reported as
+// an EventWebhook. It implies WithHTTPProxy.
+func WithWebhooks(hooks ...Webhook) Option {
+	return func(t *Tunnel) {
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for _, hook := range hooks {
+					if !hasPathPrefix(r.URL.Path, hook.Path) {
+						continue
+					}
+
+					err := ErrWebhookPath
+					if r.URL.Path == cleanPath(r.URL.Path) {
+						err = verifyWebhook(r, hook.Verifier, t.now())
+					}
+					t.emit(Event{Type: EventWebhook, Path: r.URL.Path, Err: err})
+					if err != nil {
+						http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+						return
+					}
+					break
+				}
+
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+}
+
+// verifyWebhook reads the body of r to verify it, leaving it to be read again.
+func verifyWebhook(r *http.Request, verify WebhookVerifier, now time.Time) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxWebhookBody))
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return verify(r, body, now)
+}
+
+// GitHubSignature verifies the X-Hub-Signature-256 header of GitHub webhooks.
+func GitHubSignature(secret string) WebhookVerifier {
+	return func(r *http.Request, body []byte, now time.Time) error {
+		sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+		return checkHMAC(secret, body, sig)
+	}
+}
+
+// StripeSignature verifies the Stripe-Signature header of Stripe webhooks, rejecting
+// the ones signed more than tolerance ago, or DefaultWebhookTolerance when zero.
+func StripeSignature(secret string, tolerance time.Duration) WebhookVerifier {
+	return func(r *http.Request, body []byte, now time.Time) error {
+		var ts string
+		var sigs []string
+		for _, kv := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+			parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "t":
+				ts = parts[1]
+			case "v1":
+				sigs = append(sigs, parts[1])
+			}
+		}
+
+		err := checkTimestamp(ts, tolerance, now)
+		if err != nil {
+			return err
+		}
+
+		payload := append([]byte(ts+"."), body...)
+		for _, sig := range sigs {
+			if checkHMAC(secret, payload, sig) == nil {
+				return nil
+			}
+		}
+		return ErrInvalidSignature
+	}
+}
+
+// SlackSignature verifies the X-Slack-Signature header of Slack requests, rejecting
+// the ones signed more than tolerance ago, or DefaultWebhookTolerance when zero.
+func SlackSignature(secret string, tolerance time.Duration) WebhookVerifier {
+	return func(r *http.Request, body []byte, now time.Time) error {
+		ts := r.Header.Get("X-Slack-Request-Timestamp")
+		err := checkTimestamp(ts, tolerance, now)
+		if err != nil {
+			return err
+		}
+
+		sig := strings.TrimPrefix(r.Header.Get("X-Slack-Signature"), "v0=")
+		return checkHMAC(secret, append([]byte("v0:"+ts+":"), body...), sig)
+	}
+}
+
+// checkHMAC checks sig is the hex encoded HMAC-SHA256 of payload.
+func checkHMAC(secret string, payload []byte, sig string) error {
+	expected, err := hex.DecodeString(sig)
+	if err != nil || sig == "" {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// checkTimestamp checks the Unix time ts is within tolerance of now.
+func checkTimestamp(ts string, tolerance time.Duration, now time.Time) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if tolerance == 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	d := now.Sub(time.Unix(sec, 0))
+	if d > tolerance || d < -tolerance {
+		return ErrWebhookExpired
+	}
+	return nil
+}
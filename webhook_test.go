@@ -0,0 +1,95 @@
+package localtunnel
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hmacHex(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhooks(t *testing.T) {
+	clock := newFakeClock()
+	events := make(chan Event, 10)
+	h := tunnelHandler(t, http.HandlerFunc(echoHandler), WithClock(clock), WithEvents(events), WithWebhooks(
+		Webhook{Path: "/hooks/github", Verifier: GitHubSignature("gh")},
+		Webhook{Path: "/hooks/stripe", Verifier: StripeSignature("st", 0)},
+		Webhook{Path: "/hooks/slack", Verifier: SlackSignature("sl", time.Minute)},
+	))
+
+	body := `{"action":"opened"}`
+	now := strconv.FormatInt(clock.Now().Unix(), 10)
+	old := strconv.FormatInt(clock.Now().Add(-10*time.Minute).Unix(), 10)
+
+	tests := []struct {
+		path   string
+		header http.Header
+		status int
+	}{
+		{"/", nil, 200},
+		{"/hooks/github", nil, 401},
+		{"/hooks/github", http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("gh", body)}}, 200},
+		{"/hooks/github", http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("forged", body)}}, 401},
+		{"/hooks/stripe", http.Header{"Stripe-Signature": {"t=" + now + ",v1=bad,v1=" + hmacHex("st", now+"."+body)}}, 200},
+		{"/hooks/stripe", http.Header{"Stripe-Signature": {"t=" + old + ",v1=" + hmacHex("st", old+"."+body)}}, 401},
+		{"/hooks/slack", http.Header{"X-Slack-Request-Timestamp": {now}, "X-Slack-Signature": {"v0=" + hmacHex("sl", "v0:"+now+":"+body)}}, 200},
+		{"/hooks/slack", http.Header{"X-Slack-Request-Timestamp": {old}, "X-Slack-Signature": {"v0=" + hmacHex("sl", "v0:"+old+":"+body)}}, 401},
+		{"//hooks/github", nil, 401},
+		{"/hooks/./github", nil, 401},
+		{"/x/../hooks/github", http.Header{"X-Hub-Signature-256": {"sha256=" + hmacHex("gh", body)}}, 401},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.path, strings.NewReader(body))
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+
+		w := serve(h, req)
+		if w.Code != test.status {
+			t.Fatalf("%s %v: unexpected status. Expected: %d, Actual: %d", test.path, test.header, test.status, w.Code)
+		}
+		if w.Code == 200 && w.Body.String() != "POST "+test.path+" "+body {
+			t.Fatalf("%s: the body should reach the local server. Actual: %s", test.path, w.Body)
+		}
+	}
+
+	if len(events) != len(tests)-1 {
+		t.Fatalf("Unexpected events. Expected: %d, Actual: %d", len(tests)-1, len(events))
+	}
+	if e := <-events; e.Type != EventWebhook || e.Path != "/hooks/github" || e.Err != ErrInvalidSignature {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+	for len(events) > 1 {
+		<-events
+	}
+	if e := <-events; e.Path != "/x/../hooks/github" || e.Err != ErrWebhookPath {
+		t.Fatalf("Unexpected event of a non-canonical path: %+v", e)
+	}
+}
+
+func TestWebhookExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hmacHex("sl", "v0:"+ts+":"))
+
+	verify := SlackSignature("sl", 0)
+	if err := verify(req, nil, now.Add(4*time.Minute)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := verify(req, nil, now.Add(6*time.Minute)); err != ErrWebhookExpired {
+		t.Fatalf("Unexpected error. Expected: %v, Actual: %v", ErrWebhookExpired, err)
+	}
+}
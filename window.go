@@ -0,0 +1,52 @@
+package localtunnel
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+var errWindowAfterOpen = errors.New("localtunnel: access window must be set before the tunnel is opened")
+
+// TemporaryAccess lets the requests in for ttl from now only, after which they are
+// answered 403 Forbidden. Calling it again moves the end of the window, a zero ttl
+// revoking the access at once. It implies WithHTTPProxy, so the first call must
+// happen before the tunnel is opened.
+func (t *Tunnel) TemporaryAccess(ttl time.Duration) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	if !t.windowed {
+		if t.closeCh != nil && isOpen(t.closeCh) {
+			return errWindowAfterOpen
+		}
+
+		t.windowed = true
+		t.use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !t.now().Before(t.AccessUntil()) {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		if t.streams == nil {
+			t.streams = make(chan net.Conn)
+		}
+	}
+
+	t.sm.Lock()
+	t.accessUntil = t.now().Add(ttl)
+	t.sm.Unlock()
+	return nil
+}
+
+// AccessUntil returns the end of the window set by TemporaryAccess, or the zero
+// time when there is none.
+func (t *Tunnel) AccessUntil() time.Time {
+	t.sm.RLock()
+	defer t.sm.RUnlock()
+	return t.accessUntil
+}